@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/bench"
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/pkg/errors"
+)
+
+// RunBench is the function of the "bench" command.
+//
+// It requests all the providers repeatedly and prints the
+// latency, error rate, bytes transferred and whether the answers matched the
+// majority. As a table or JSON.
+func RunBench(args []string) error {
+	var isJSON bool
+
+	benchmark := bench.New(getProviders()...)
+
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.IntVar(&benchmark.Rounds, "rounds", bench.RoundsDefault, "number of requests per provider")
+	flags.DurationVar(&benchmark.Interval, "interval", bench.IntervalDefault,
+		"interval between the requests to the same provider. to respect the rate limits")
+	flags.DurationVar(&benchmark.Timeout, "timeout", bench.TimeoutDefault, "timeout of each request")
+	flags.BoolVar(&isJSON, "json", false, "prints the result in JSON")
+
+	if err := flags.Parse(args); err != nil {
+		return newUsageError(errors.Wrap(err, "failed to parse bench options"))
+	}
+
+	client, err := sourceClient()
+	if err != nil {
+		return err
+	}
+
+	benchmark.Client = client
+
+	ctx, stop := notifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := benchmark.Run(info.NewContext(ctx, info.Default()))
+	if err != nil {
+		return errors.Wrap(err, "failed to benchmark the providers")
+	}
+
+	if isJSON {
+		return errors.Wrap(json.NewEncoder(os.Stdout).Encode(result), "failed to encode the result")
+	}
+
+	printBench(os.Stdout, result)
+
+	return nil
+}
+
+// Prints the result of the benchmark as a table.
+func printBench(out io.Writer, result *bench.Result) {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "PROVIDER\tREQUESTS\tERROR RATE\tMIN\tMEDIAN\tP95\tBYTES\tMATCH MAJORITY")
+
+	for _, report := range result.Reports {
+		match := "no"
+		if report.MatchMajority {
+			match = "yes"
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v (%v/%v)\n",
+			report.Provider,
+			report.Requests,
+			strconv.FormatFloat(report.ErrorRate*100, 'f', 1, 64)+"%",
+			report.Min.Round(time.Millisecond),
+			report.Median.Round(time.Millisecond),
+			report.P95.Round(time.Millisecond),
+			report.Bytes,
+			match, report.Matched, report.Requests-report.Errors,
+		)
+	}
+
+	majority := "none"
+	if result.Majority != nil {
+		majority = result.Majority.String()
+	}
+
+	fmt.Fprintf(writer, "\nMajority: %v\n", majority)
+
+	writer.Flush()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/bench"
+	"github.com/KEINOS/whereami/pkg/provider/providers/ipifyorg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zenizh/go-capturer"
+)
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunBench(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"ip": "123.123.123.123"}`)
+	}))
+	defer dummySrv.Close()
+
+	prov := ipifyorg.New()
+	prov.SetURL(dummySrv.URL)
+
+	mockProviders(prov)
+
+	// Table
+	out := capturer.CaptureStdout(func() {
+		require.NoError(t, RunBench([]string{"--rounds", "2", "--interval", "1ms"}))
+	})
+
+	assert.Contains(t, out, "PROVIDER")
+	assert.Regexp(t, dummySrv.URL+`\s+2\s+0.0%\s+\S+\s+\S+\s+\S+\s+50\s+yes \(2/2\)`, out)
+	assert.Contains(t, out, "Majority: 123.123.123.123")
+
+	// JSON
+	out = capturer.CaptureStdout(func() {
+		require.NoError(t, RunBench([]string{"--rounds", "2", "--interval", "1ms", "--json"}))
+	})
+
+	var result bench.Result
+
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	require.Len(t, result.Reports, 1)
+	assert.Equal(t, 2, result.Reports[0].Requests)
+	assert.True(t, result.Reports[0].MatchMajority)
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunBench_error(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	err := RunBench([]string{"--rounds", "0"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "number of rounds must be 1 or more")
+
+	capturer.CaptureStderr(func() {
+		err = RunBench([]string{"--unknown"})
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse bench options")
+}
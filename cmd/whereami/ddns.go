@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"math"
+	"net"
+	"os"
+
+	"github.com/KEINOS/whereami/pkg/ddns"
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/pkg/errors"
+)
+
+// envNameSecret is the name of the environment variable to set the TSIG
+// secret. It is used if the "--key-secret" option is not set, to avoid the
+// secret being visible in the process list.
+const envNameSecret = "WHEREAMI_TSIG_SECRET"
+
+// RunDDNS is the function of the "ddns" command.
+//
+// It detects the current global/public IP address and replaces the A (or AAAA)
+// record of the given name with it, via RFC 2136 dynamic update.
+func RunDDNS(args []string) error {
+	updater := ddns.New("", "", "")
+
+	var isTCP bool
+
+	flags := flag.NewFlagSet("ddns", flag.ContinueOnError)
+	flags.StringVar(&updater.Server, "server", "", "address of the primary DNS server of the zone. such as 192.0.2.53:53")
+	flags.StringVar(&updater.Zone, "zone", "", "zone to update. such as example.com")
+	flags.StringVar(&updater.Name, "name", "", "name of the record to replace. such as home.example.com")
+	flags.StringVar(&updater.KeyName, "key-name", "", "name of the TSIG key. if empty, the update will not be signed")
+	flags.StringVar(&updater.Secret, "key-secret", os.Getenv(envNameSecret),
+		"base64 encoded TSIG secret. defaults to the env variable "+envNameSecret)
+	flags.StringVar(&updater.Algorithm, "key-algorithm", updater.Algorithm, "TSIG algorithm")
+	flags.BoolVar(&isTCP, "tcp", false, "use TCP instead of UDP")
+
+	ttl := flags.Uint("ttl", uint(updater.TTL), "TTL in seconds of the new record")
+
+	if err := flags.Parse(args); err != nil {
+		return newUsageError(errors.Wrap(err, "failed to parse ddns options"))
+	}
+
+	if *ttl > math.MaxUint32 {
+		return newUsageError(errors.Errorf("invalid --ttl: must be %v or less. given: %v", uint32(math.MaxUint32), *ttl))
+	}
+
+	updater.TTL = uint32(*ttl)
+
+	if isTCP {
+		updater.Network = "tcp"
+	}
+
+	// Before requesting the providers
+	if err := updater.Validate(); err != nil {
+		return newUsageError(errors.Wrap(err, "invalid ddns options"))
+	}
+
+	ctx := info.NewContext(context.Background(), info.Default())
+
+	// Do not update the record with the IP address told by a captive portal
+	network, err := checkNetwork()
+	if err != nil {
+		return err
+	}
+
+	resolver, tracker, err := newResolver()
+	if err != nil {
+		return err
+	}
+
+	ipAddress, err := resolveIP(resolver, tracker)
+	if err != nil {
+		return err
+	}
+
+	if err := updater.UpdateContext(ctx, net.ParseIP(ipAddress)); err != nil {
+		return errors.Wrap(err, "failed to update DNS record")
+	}
+
+	info.FromContext(ctx).Info("updated the record", "name", updater.Name, "ip", ipAddress, "server", updater.Server)
+
+	out := output{Status: statusOK, IP: ipAddress, Proxy: proxyReport()}
+	if network.Status != "" {
+		out.Network = &network
+	}
+
+	return printResult(out)
+}
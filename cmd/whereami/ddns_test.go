@@ -0,0 +1,164 @@
+package main
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zenizh/go-capturer"
+)
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunDDNS_golden(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	dummyIP := "123.123.123.123"
+	received := make(chan *dns.Msg, 1)
+	addrDNS := startDummyDNSServer(t, received)
+
+	// Mock the providers and os.Args with dummy values.
+	// This values will be recovered by restoreFn.
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP(dummyIP), nil
+		}},
+	)
+	os.Args = []string{
+		t.Name(), "--verbose", "ddns",
+		"--server", addrDNS,
+		"--zone", "example.com",
+		"--name", "home.example.com",
+		"--key-name", "whereami-key",
+		"--key-secret", "c2VjcmV0LWtleS1mb3ItdGVzdGluZy1wdXJwb3Nl",
+	}
+
+	var out string
+
+	outStderr := capturer.CaptureStderr(func() {
+		out = capturer.CaptureStdout(func() {
+			main()
+		})
+	})
+
+	require.Contains(t, out, dummyIP, "it should print the detected IP address")
+	require.Contains(t, outStderr, "updated the record name=home.example.com", "verbose logs should be in STDERR")
+	require.Contains(t, info.Get(), "updated the record name=home.example.com ip=123.123.123.123")
+
+	msg := <-received
+	require.Len(t, msg.Ns, 2, "the update section should have the deletion and the addition")
+
+	record, ok := msg.Ns[1].(*dns.A)
+	require.True(t, ok, "it should add an A record")
+	assert.Equal(t, dummyIP, record.A.String())
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunDDNS_invalid_options(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	isRequested := false
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			isRequested = true
+
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+
+	for _, test := range []struct {
+		args   []string
+		expect string
+	}{
+		{args: []string{"--zone", "example.com", "--name", "home.example.com"}, expect: "DNS server is not set"},
+		{args: []string{"--server", "192.0.2.53", "--zone", "example.com", "--name", "home.example.org"},
+			expect: "name home.example.org is not in the zone example.com"},
+		{args: []string{"--server", "192.0.2.53", "--zone", "example.com", "--name", "home.example.com",
+			"--key-name", "whereami-key", "--key-secret", "not base64"}, expect: "TSIG secret is empty or not base64"},
+		{args: []string{"--ttl", "4294967296"}, expect: "invalid --ttl: must be 4294967295 or less"},
+	} {
+		err := RunDDNS(test.args)
+
+		require.Error(t, err, test.args)
+		assert.Equal(t, ExitUsage, exitCode(err), test.args)
+		assert.Contains(t, err.Error(), test.expect)
+	}
+
+	assert.False(t, isRequested, "the providers should not be requested on invalid options")
+
+	// Negative TTL is refused on parse
+	capturer.CaptureStderr(func() {
+		err := RunDDNS([]string{"--ttl", "-1"})
+
+		require.Error(t, err)
+		assert.Equal(t, ExitUsage, exitCode(err))
+	})
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunDDNS_unknown_option(t *testing.T) {
+	out := capturer.CaptureStderr(func() {
+		err := RunDDNS([]string{"--unknown"})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse ddns options")
+	})
+
+	assert.Contains(t, out, "flag provided but not defined: -unknown")
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+// Starts a DNS server stand-in which accepts any UPDATE message signed with
+// the "whereami-key." and sends it to the received channel.
+func startDummyDNSServer(t *testing.T, received chan<- *dns.Msg) string {
+	t.Helper()
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	srv := &dns.Server{
+		PacketConn:        packetConn,
+		TsigSecret:        map[string]string{"whereami-key.": "c2VjcmV0LWtleS1mb3ItdGVzdGluZy1wdXJwb3Nl"},
+		NotifyStartedFunc: func() { close(started) },
+		MsgAcceptFunc:     func(dh dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			resp := new(dns.Msg)
+			resp.SetReply(req)
+
+			if tsig := req.IsTsig(); tsig != nil && w.TsigStatus() == nil {
+				resp.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, int64(tsig.TimeSigned))
+				received <- req
+			} else {
+				resp.Rcode = dns.RcodeNotAuth
+			}
+
+			if err := w.WriteMsg(resp); err != nil {
+				t.Log(err)
+			}
+		}),
+	}
+
+	go func() {
+		if err := srv.ActivateAndServe(); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	<-started
+
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+
+	return packetConn.LocalAddr().String()
+}
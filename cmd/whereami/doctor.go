@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/KEINOS/whereami/pkg/doctor"
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/pkg/errors"
+)
+
+// newDoctor is a copy of doctor.New to ease mock its behavior during test.
+var newDoctor = doctor.New
+
+// RunDoctor is the function of the "doctor" command.
+//
+// It diagnoses the connectivity to the providers step by step
+// and prints the result of each stage with the verdict. It returns an error if
+// any stage failed.
+func RunDoctor(args []string) error {
+	var isJSON bool
+
+	doc := newDoctor(getProviders()...)
+
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	flags.DurationVar(&doc.Timeout, "timeout", doctor.TimeoutDefault, "timeout of each stage")
+	flags.BoolVar(&isJSON, "json", false, "prints the result in JSON")
+
+	if err := flags.Parse(args); err != nil {
+		return newUsageError(errors.Wrap(err, "failed to parse doctor options"))
+	}
+
+	// Diagnose the path of the --interface, --source and --proxy flags
+	config, err := netConfig()
+	if err != nil {
+		return err
+	}
+
+	if client := clientOf(config); client != nil {
+		doc.Client = client
+		doc.DialContext = config.DialContext
+		doc.Proxy = proxyReport()
+	}
+
+	checks := doc.Run(info.NewContext(context.Background(), info.Default()))
+	verdict, errVerdict := doctor.Verdict(checks)
+
+	if isJSON {
+		err := json.NewEncoder(os.Stdout).Encode(struct {
+			Verdict string         `json:"verdict"`
+			Checks  []doctor.Check `json:"checks"`
+		}{Verdict: verdict, Checks: checks})
+		if err != nil {
+			return errors.Wrap(err, "failed to encode the result")
+		}
+
+		return errVerdict
+	}
+
+	//nolint:forbidigo // Allow fmt.Println due to the main function
+	fmt.Printf("whereami doctor (%v/%v, %v)\n\n", runtime.GOOS, runtime.GOARCH, runtime.Version())
+
+	for _, check := range checks {
+		//nolint:forbidigo // Allow fmt.Println due to the main function
+		fmt.Println(check.String())
+	}
+
+	//nolint:forbidigo // Allow fmt.Println due to the main function
+	fmt.Printf("\nVerdict: %v\n", verdict)
+
+	return errVerdict
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/doctor"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/provider/providers/ipifyorg"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zenizh/go-capturer"
+)
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunDoctor(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	status := http.StatusOK
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"ip": "123.123.123.123"}`)
+	}))
+	defer dummySrv.Close()
+
+	prov := ipifyorg.New()
+	prov.SetURL(dummySrv.URL)
+
+	mockProviders(prov)
+
+	oldNewDoctor := newDoctor
+	defer func() { newDoctor = oldNewDoctor }()
+
+	newDoctor = func(providers ...provider.Provider) *doctor.Doctor {
+		doc := oldNewDoctor(providers...)
+		doc.LookupEnv = func(key string) (string, bool) { return "", false }
+		doc.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if network != "tcp" {
+				return nil, errors.New("forced error")
+			}
+
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		}
+
+		return doc
+	}
+
+	// Table
+	out := capturer.CaptureStdout(func() {
+		require.NoError(t, RunDoctor([]string{}))
+	})
+
+	assert.Contains(t, out, "whereami doctor (")
+	assert.Contains(t, out, "[OK  ] parse "+dummySrv.URL+"  IP address: 123.123.123.123")
+	assert.Contains(t, out, "[WARN] ipv6")
+	assert.Contains(t, out, "Verdict: All checks passed with 2 warning(s).")
+
+	// JSON with failure
+	status = http.StatusInternalServerError
+
+	var err error
+
+	out = capturer.CaptureStdout(func() {
+		err = RunDoctor([]string{"--json"})
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 check(s) failed")
+
+	var result struct {
+		Verdict string         `json:"verdict"`
+		Checks  []doctor.Check `json:"checks"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Contains(t, result.Verdict, "1 check(s) failed")
+	assert.NotEmpty(t, result.Checks)
+}
+
+func TestRunDoctor_unknown_option(t *testing.T) {
+	t.Parallel()
+
+	var err error
+
+	capturer.CaptureStderr(func() {
+		err = RunDoctor([]string{"--unknown"})
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse doctor options")
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunDoctor_proxy(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	// The proxy receives the absolute URL of the provider
+	proxySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"ip": "123.123.123.123"}`)
+	}))
+	defer proxySrv.Close()
+
+	prov := ipifyorg.New()
+	prov.SetURL("http://192.0.2.1/")
+
+	mockProviders(prov)
+	proxyURL = "http://user:pass@" + proxySrv.Listener.Addr().String()
+
+	oldNewDoctor := newDoctor
+	defer func() { newDoctor = oldNewDoctor }()
+
+	var doc *doctor.Doctor
+
+	newDoctor = func(providers ...provider.Provider) *doctor.Doctor {
+		doc = oldNewDoctor(providers...)
+		doc.LookupEnv = func(key string) (string, bool) { return "", false }
+
+		return doc
+	}
+
+	out := capturer.CaptureStdout(func() {
+		_ = RunDoctor([]string{})
+	})
+
+	require.NotNil(t, doc)
+	assert.NotNil(t, doc.Client, "the client of --proxy should be used")
+	assert.Equal(t, "http://user:xxxxx@"+proxySrv.Listener.Addr().String(), doc.Proxy)
+	assert.Contains(t, out, "connecting via http://user:xxxxx@")
+	assert.Contains(t, out, "[OK  ] parse http://192.0.2.1/  IP address: 123.123.123.123")
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/KEINOS/go-utiles/util"
+	"github.com/KEINOS/whereami/pkg/portal"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/whereami"
+	"github.com/pkg/errors"
+)
+
+// Exit codes of the command. See the "Exit status" section of README.md.
+const (
+	// ExitOK means the command succeeded.
+	ExitOK = 0
+	// ExitFailure means an error not categorized below.
+	ExitFailure = 1
+	// ExitUsage means an invalid command, option or option value.
+	ExitUsage = 2
+	// ExitNoConsensus means some providers answered but did not reach the
+	// quorum.
+	ExitNoConsensus = 3
+	// ExitNoProviders means there was no provider to request.
+	ExitNoProviders = 4
+	// ExitNetwork means no provider answered due to the network. Such as the
+	// timeout, DNS failure or no route to the providers.
+	ExitNetwork = 5
+	// ExitRateLimited means the providers refused the requests due to the
+	// rate limit.
+	ExitRateLimited = 6
+	// ExitProvider means the providers were reachable but the responses were
+	// errors, could not be parsed or non-global IP addresses.
+	ExitProvider = 7
+	// ExitCaptivePortal means the network is behind a captive portal. The
+	// providers were not requested.
+	ExitCaptivePortal = 8
+	// ExitIntercepted means HTTPS is intercepted by a proxy or a security
+	// software. The providers were not requested.
+	ExitIntercepted = 9
+)
+
+// ============================================================================
+//  Type: usageError
+// ============================================================================
+
+// usageError is the error due to the invalid command, option or option value.
+// Which exits with ExitUsage.
+type usageError struct {
+	error
+}
+
+// Returns the err as a usage error. It returns nil if err is nil.
+func newUsageError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return usageError{error: err}
+}
+
+// Format implements fmt.Formatter to print the stack trace with "%+v".
+func (e usageError) Format(state fmt.State, verb rune) {
+	if verb == 'v' && state.Flag('+') {
+		fmt.Fprintf(state, "%+v", e.error)
+
+		return
+	}
+
+	fmt.Fprint(state, e.Error())
+}
+
+// Unwrap returns the original error.
+func (e usageError) Unwrap() error {
+	return e.error
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Returns the exit code of the err. See the constants of ExitXxxx.
+func exitCode(err error) int {
+	var (
+		errUsage    usageError
+		errDisagree *whereami.DisagreementError
+	)
+
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &errUsage):
+		return ExitUsage
+	case errors.Is(err, whereami.ErrNoProviders):
+		return ExitNoProviders
+	case errors.Is(err, portal.ErrCaptivePortal):
+		return ExitCaptivePortal
+	case errors.Is(err, portal.ErrIntercepted):
+		return ExitIntercepted
+	case errors.As(err, &errDisagree):
+		if len(errDisagree.Result.Votes()) > 0 {
+			return ExitNoConsensus
+		}
+
+		// None answered. Tell why from the errors of the providers
+		return exitCodeAnswers(errDisagree.Result.Answers)
+	}
+
+	return exitCodeProvider(err)
+}
+
+// Returns the exit code of the answers which all failed. The rate limit comes
+// first, then the errors of the reachable providers, and the network errors.
+func exitCodeAnswers(answers []whereami.Answer) int {
+	code := ExitFailure
+
+	for _, answer := range answers {
+		codeAnswer := exitCodeProvider(answer.Err)
+
+		switch {
+		case codeAnswer == ExitRateLimited:
+			return ExitRateLimited
+		case codeAnswer == ExitProvider:
+			code = ExitProvider
+		case code != ExitProvider:
+			code = ExitNetwork
+		}
+	}
+
+	return code
+}
+
+// Returns the exit code of the error of a provider.
+func exitCodeProvider(err error) int {
+	switch {
+	case errors.Is(err, provider.ErrRateLimited):
+		return ExitRateLimited
+	case errors.Is(err, provider.ErrHTTPStatus), errors.Is(err, provider.ErrParse),
+		errors.Is(err, whereami.ErrNonGlobal):
+		return ExitProvider
+	case errors.Is(err, provider.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ExitNetwork
+	}
+
+	return ExitFailure
+}
+
+// Prints the err with the stack trace to STDERR and exits with its exit code.
+// It does nothing if err is nil.
+func exitOnErr(err error) {
+	if err == nil {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%+v\n", err)
+
+	util.OsExit(exitCode(err))
+}
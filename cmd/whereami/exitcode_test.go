@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/KEINOS/go-utiles/util"
+	"github.com/KEINOS/whereami/pkg/portal"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/provider/base"
+	"github.com/KEINOS/whereami/pkg/whereami"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zenizh/go-capturer"
+)
+
+// ----------------------------------------------------------------------------
+//  exitCode()
+// ----------------------------------------------------------------------------
+
+func Test_exitCode(t *testing.T) {
+	t.Parallel()
+
+	errRateLimited := base.NewHTTPStatusError("http://dummy.com/",
+		&http.Response{Status: "429 Too Many Requests", StatusCode: http.StatusTooManyRequests}, nil)
+	errNotFound := base.NewHTTPStatusError("http://dummy.com/",
+		&http.Response{Status: "404 Not Found", StatusCode: http.StatusNotFound}, nil)
+	errTimeout := base.Mark(errors.New("dummy timeout"), provider.ErrTimeout)
+	errRefused := errors.New("connection refused")
+
+	// Returns the disagreement error of the given answers
+	disagree := func(answers ...whereami.Answer) error {
+		return errors.Wrap(&whereami.DisagreementError{Result: &whereami.Result{Answers: answers}}, "failed")
+	}
+
+	for _, test := range []struct {
+		err    error
+		name   string
+		expect int
+	}{
+		{name: "nil", err: nil, expect: ExitOK},
+		{name: "unknown", err: errors.New("unknown error"), expect: ExitFailure},
+		{name: "usage", err: errors.Wrap(newUsageError(errors.New("invalid --order")), "failed"), expect: ExitUsage},
+		{name: "no providers", err: errors.WithStack(whereami.ErrNoProviders), expect: ExitNoProviders},
+		{name: "rate limited", err: errors.WithStack(errRateLimited), expect: ExitRateLimited},
+		{name: "http status", err: errors.WithStack(errNotFound), expect: ExitProvider},
+		{name: "timeout", err: errors.Wrap(context.DeadlineExceeded, "failed"), expect: ExitNetwork},
+		{
+			name:   "captive portal",
+			err:    errors.WithStack(portal.Result{Status: portal.StatusCaptivePortal}.Err()),
+			expect: ExitCaptivePortal,
+		},
+		{
+			name:   "tls intercepted",
+			err:    errors.WithStack(portal.Result{Status: portal.StatusIntercepted}.Err()),
+			expect: ExitIntercepted,
+		},
+		{
+			name: "no consensus",
+			err: disagree(
+				whereami.Answer{IP: net.ParseIP("127.0.0.1")},
+				whereami.Answer{IP: net.ParseIP("127.0.0.2")},
+				whereami.Answer{Err: errTimeout},
+			),
+			expect: ExitNoConsensus,
+		},
+		{
+			name:   "all failed by network",
+			err:    disagree(whereami.Answer{Err: errTimeout}, whereami.Answer{Err: errRefused}),
+			expect: ExitNetwork,
+		},
+		{
+			name:   "all failed with non-global IP addresses",
+			err:    disagree(whereami.Answer{Err: base.Mark(errors.New("private"), whereami.ErrNonGlobal)}),
+			expect: ExitProvider,
+		},
+		{
+			name:   "all failed with a bad response",
+			err:    disagree(whereami.Answer{Err: errTimeout}, whereami.Answer{Err: errNotFound}),
+			expect: ExitProvider,
+		},
+		{
+			name: "all failed with a rate limit",
+			err: disagree(
+				whereami.Answer{Err: errNotFound},
+				whereami.Answer{Err: errRateLimited},
+				whereami.Answer{Err: errTimeout},
+			),
+			expect: ExitRateLimited,
+		},
+	} {
+		assert.Equal(t, test.expect, exitCode(test.err), test.name)
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  exitOnErr()
+// ----------------------------------------------------------------------------
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_exitOnErr(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	capturedCode := -1
+
+	util.OsExit = func(code int) {
+		capturedCode = code
+	}
+
+	out := capturer.CaptureStderr(func() {
+		exitOnErr(nil)
+	})
+
+	require.Equal(t, -1, capturedCode, "it should not exit on nil")
+	require.Empty(t, out)
+
+	out = capturer.CaptureStderr(func() {
+		exitOnErr(newUsageError(errors.New("invalid --order")))
+	})
+
+	require.Equal(t, ExitUsage, capturedCode)
+	assert.Contains(t, out, "invalid --order")
+	assert.Contains(t, out, "Test_exitOnErr", "it should print the stack trace")
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_all_rate_limited(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	var capturedCode int
+
+	util.OsExit = func(code int) {
+		capturedCode = code
+	}
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return nil, errors.WithStack(base.NewHTTPStatusError("http://dummy.com/",
+				&http.Response{Status: "429 Too Many Requests", StatusCode: http.StatusTooManyRequests}, nil))
+		}},
+	)
+	os.Args = []string{t.Name()}
+
+	out := capturer.CaptureStderr(func() {
+		main()
+	})
+
+	require.Equal(t, ExitRateLimited, capturedCode)
+	assert.Contains(t, out, "fail to GET response from: http://dummy.com/")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/pkg/errors"
+)
+
+// listInterfaces is a copy of netutil.Interfaces to ease mock its behavior
+// during test.
+var listInterfaces = netutil.Interfaces
+
+// egress is the global/public IP address detected via a network interface.
+type egress struct {
+	// Interface is the name of the network interface.
+	Interface string `json:"interface"`
+	// Source is the address of the interface bound.
+	Source string `json:"source"`
+	// IP is the global/public IP address detected. Empty on error.
+	IP string `json:"ip,omitempty"`
+	// Error is the error message if failed.
+	Error string `json:"error,omitempty"`
+}
+
+// RunInterfaces is the function of the "interfaces" command.
+//
+// It detects the global/public IP address once per network interface which is
+// up, binding the address of the interface. Then prints the interfaces and the
+// IP addresses as a table or JSON. It returns the first error if any interface
+// failed.
+func RunInterfaces(args []string) error {
+	var isJSON bool
+
+	flags := flag.NewFlagSet("interfaces", flag.ContinueOnError)
+	flags.BoolVar(&isJSON, "json", false, "prints the result in JSON")
+
+	if err := flags.Parse(args); err != nil {
+		return newUsageError(errors.Wrap(err, "failed to parse interfaces options"))
+	}
+
+	// Each interface is bound in turn
+	if netInterface != "" || sourceAddr != "" {
+		return newUsageError(errors.New("--interface and --source can not be used with the interfaces command"))
+	}
+
+	config, err := netConfig()
+	if err != nil {
+		return err
+	}
+
+	ifaces, err := listInterfaces()
+	if err != nil {
+		return err
+	}
+
+	if len(ifaces) == 0 {
+		return errors.New("no network interface is up")
+	}
+
+	var (
+		result   = make([]egress, 0, len(ifaces))
+		errFirst error
+		numError int
+	)
+
+	for _, iface := range ifaces {
+		config.Source = iface.Addr
+		row := egress{Interface: iface.Name, Source: iface.Addr.String()}
+
+		_, err := probeNetwork(config.Client())
+		if err == nil {
+			row.IP, err = resolveVia(config)
+		}
+
+		if err != nil {
+			row.Error = strings.SplitN(err.Error(), "\n", 2)[0]
+			numError++
+
+			if errFirst == nil {
+				errFirst = errors.Wrapf(err, "failed on %v", iface.Name)
+			}
+		}
+
+		result = append(result, row)
+	}
+
+	if isJSON {
+		if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+			return errors.Wrap(err, "failed to encode the result")
+		}
+	} else {
+		printInterfaces(os.Stdout, result)
+	}
+
+	if errFirst != nil {
+		return errors.Wrapf(errFirst, "%v of %v interfaces failed", numError, len(result))
+	}
+
+	return nil
+}
+
+// Returns the global/public IP address detected via the config.
+func resolveVia(config netutil.Config) (string, error) {
+	resolver, tracker, err := newResolverOf(config)
+	if err != nil {
+		return "", err
+	}
+
+	return resolveIP(resolver, tracker)
+}
+
+// Prints the IP addresses per network interface as a table.
+func printInterfaces(out io.Writer, result []egress) {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "INTERFACE\tSOURCE\tPUBLIC IP\tERROR")
+
+	for _, row := range result {
+		ip, errMsg := row.IP, row.Error
+
+		if ip == "" {
+			ip = "-"
+		}
+
+		if errMsg == "" {
+			errMsg = "-"
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", row.Interface, row.Source, ip, errMsg)
+	}
+
+	writer.Flush()
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KEINOS/go-utiles/util"
+	"github.com/KEINOS/whereami/pkg/health"
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/provider/providers/ipifyorg"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zenizh/go-capturer"
+)
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunInterfaces(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	requireLoopbackRange(t)
+
+	mockProviders(newEchoProvider(t))
+	listInterfaces = func() ([]netutil.Interface, error) {
+		return []netutil.Interface{
+			{Name: "lte0", Addr: net.ParseIP("127.0.0.2")},
+			{Name: "eth1", Addr: net.ParseIP("127.0.0.3")},
+		}, nil
+	}
+
+	// The echoed addresses are loopback
+	os.Args = []string{t.Name(), "--allow-private", "interfaces"}
+
+	out := capturer.CaptureStdout(func() {
+		main()
+	})
+
+	assert.Regexp(t, `INTERFACE\s+SOURCE\s+PUBLIC IP\s+ERROR`, out)
+	assert.Regexp(t, `lte0\s+127.0.0.2\s+127.0.0.2\s+-`, out)
+	assert.Regexp(t, `eth1\s+127.0.0.3\s+127.0.0.3\s+-`, out)
+
+	// JSON
+	os.Args = []string{t.Name(), "--allow-private", "interfaces", "--json"}
+
+	out = capturer.CaptureStdout(func() {
+		main()
+	})
+
+	var result []egress
+
+	require.NoError(t, json.Unmarshal([]byte(out), &result), "output should be a JSON array. got: %v", out)
+	assert.Equal(t, []egress{
+		{Interface: "lte0", Source: "127.0.0.2", IP: "127.0.0.2"},
+		{Interface: "eth1", Source: "127.0.0.3", IP: "127.0.0.3"},
+	}, result)
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunInterfaces_error(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	requireLoopbackRange(t)
+
+	mockProviders(newEchoProvider(t))
+	listInterfaces = func() ([]netutil.Interface, error) {
+		return []netutil.Interface{
+			{Name: "lte0", Addr: net.ParseIP("127.0.0.2")},
+			// Not an address of this host
+			{Name: "eth1", Addr: net.ParseIP("192.0.2.10")},
+		}, nil
+	}
+
+	os.Args = []string{t.Name(), "--allow-private", "interfaces"}
+
+	capturedStatus := 0
+	util.OsExit = func(code int) {
+		capturedStatus = code
+	}
+
+	var out string
+
+	outStderr := capturer.CaptureStderr(func() {
+		out = capturer.CaptureStdout(func() {
+			main()
+		})
+	})
+
+	assert.NotEqual(t, ExitOK, capturedStatus)
+	assert.Regexp(t, `lte0\s+127.0.0.2\s+127.0.0.2\s+-`, out)
+	assert.Regexp(t, `eth1\s+192.0.2.10\s+-\s+failed`, out)
+	assert.Contains(t, outStderr, "failed on eth1")
+	assert.Contains(t, outStderr, "1 of 2 interfaces failed")
+
+	// No interface
+	listInterfaces = func() ([]netutil.Interface, error) { return nil, nil }
+
+	err := RunInterfaces(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no network interface is up")
+
+	listInterfaces = func() ([]netutil.Interface, error) { return nil, errors.New("forced error") }
+
+	err = RunInterfaces(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "forced error")
+
+	// Not to overwrite the source of the flags silently
+	for _, flags := range [][]string{{"--interface", "lo"}, {"--source", "127.0.0.1"}} {
+		os.Args = append([]string{t.Name()}, append(flags, "interfaces")...)
+		netInterface, sourceAddr = "", "" // reset the flags of the previous case
+		capturedStatus = 0
+
+		out := capturer.CaptureOutput(func() {
+			main()
+		})
+
+		assert.Equal(t, ExitUsage, capturedStatus, "flags: %v", flags)
+		assert.Contains(t, out, "can not be used with the interfaces command")
+	}
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunInterfaces_health_per_source(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	requireLoopbackRange(t)
+
+	prov := newEchoProvider(t)
+
+	mockProviders(prov)
+	listInterfaces = func() ([]netutil.Interface, error) {
+		return []netutil.Interface{
+			{Name: "lte0", Addr: net.ParseIP("127.0.0.2")},
+			{Name: "eth1", Addr: net.ParseIP("192.0.2.10")}, // dead uplink
+		}, nil
+	}
+
+	pathDir := t.TempDir()
+	os.Args = []string{
+		t.Name(), "--allow-private", "--health-file", filepath.Join(pathDir, "health.json"), "interfaces",
+	}
+	util.OsExit = func(code int) {}
+
+	_ = capturer.CaptureOutput(func() {
+		main()
+	})
+
+	assert.NoFileExists(t, filepath.Join(pathDir, "health.json"),
+		"the health of the default route should not be touched")
+
+	alive, err := health.Load(filepath.Join(pathDir, "health.127.0.0.2.json"))
+	require.NoError(t, err)
+
+	stats, ok := alive.Get(prov.Name())
+	require.True(t, ok)
+	assert.Equal(t, 1, stats.Successes)
+	assert.Equal(t, 0, stats.Failures, "the failures of the other uplink should not be counted")
+
+	dead, err := health.Load(filepath.Join(pathDir, "health.192.0.2.10.json"))
+	require.NoError(t, err)
+
+	stats, ok = dead.Get(prov.Name())
+	require.True(t, ok)
+	assert.Equal(t, 0, stats.Successes)
+	assert.Positive(t, stats.Failures)
+}
+
+func Test_healthPathOf(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, filepath.Join("cache", "health.192.0.2.10.json"),
+		healthPathOf(filepath.Join("cache", "health.json"), net.ParseIP("192.0.2.10")))
+	assert.Equal(t, "health.2001-db8--1",
+		healthPathOf("health", net.ParseIP("2001:db8::1")), "the colons should be replaced")
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_source(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	requireLoopbackRange(t)
+
+	mockProviders(newEchoProvider(t))
+
+	for _, args := range [][]string{
+		{"--allow-private", "--source", "127.0.0.2"},
+		{"--allow-private", "--source", "127.000.000.002"},
+	} {
+		os.Args = append([]string{t.Name()}, args...)
+
+		out := capturer.CaptureStdout(func() {
+			main()
+		})
+
+		assert.Equal(t, "127.0.0.2", out, "the request should come from the source address. args: %v", args)
+	}
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_source_invalid(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	mockProviders(newEchoProvider(t))
+
+	for _, test := range []struct {
+		expect string
+		args   []string
+	}{
+		{args: []string{"--source", "127.0.0"}, expect: "invalid --source"},
+		{args: []string{"--interface", "unknown0"}, expect: "network interface not found: unknown0"},
+		{args: []string{"--interface", "lo", "--source", "127.0.0.1"}, expect: "can not be used together"},
+	} {
+		os.Args = append([]string{t.Name()}, test.args...)
+		netInterface, sourceAddr = "", "" // reset the flags of the previous case
+
+		capturedStatus := 0
+		util.OsExit = func(code int) {
+			capturedStatus = code
+		}
+
+		out := capturer.CaptureOutput(func() {
+			main()
+		})
+
+		assert.Equal(t, ExitUsage, capturedStatus, "args: %v", test.args)
+		assert.Contains(t, out, test.expect)
+	}
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+// Returns a provider which answers the address of the caller.
+func newEchoProvider(t *testing.T) provider.Provider {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		require.NoError(t, err)
+
+		fmt.Fprintf(w, `{"ip":%q}`, host)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	prov := ipifyorg.New()
+	prov.SetURL(srv.URL)
+
+	return prov
+}
+
+// Skips the test if the addresses in 127.0.0.0/8 other than 127.0.0.1 are not
+// available. Such as macOS.
+func requireLoopbackRange(t *testing.T) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("127.0.0.2 is not available: %v", err)
+	}
+
+	listener.Close()
+}
@@ -1,44 +1,124 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"math/rand"
+	"io"
 	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
-	"github.com/KEINOS/go-utiles/util"
+	"github.com/KEINOS/whereami/pkg/geo"
+	"github.com/KEINOS/whereami/pkg/health"
 	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/ipaddr"
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/KEINOS/whereami/pkg/portal"
 	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/rdns"
+	"github.com/KEINOS/whereami/pkg/whereami"
+	"github.com/KEINOS/whereami/pkg/whois"
 	"github.com/pkg/errors"
 )
 
 const sleepTime = 1
 
 var (
-	// Max number of providers to use to fetch the public IP.
-	maxNumUseDefault = 3
-	// List of public IP address detector service providers.
-	listProvider []provider.Provider
+	// getProviders is a copy of provider.GetAll to ease mock its behavior during
+	// test.
+	getProviders = provider.GetAll
+	// getPathHealth is a copy of health.DefaultPath to ease mock its behavior
+	// during test.
+	getPathHealth = health.DefaultPath
+	// newDetector is a copy of portal.New to ease mock its behavior during test.
+	newDetector = portal.New
 )
 
-var (
-	// This infoLog is a copy of info.Log to ease mock its behavior during test.
-	infoLog = info.Log
-
-	// InfoLog is a function that wraps info.Log, but panics immediately if it
-	// fails to log.
-	InfoLog = func(logs ...string) {
-		if _, err := infoLog(logs...); err != nil {
-			panic(err)
-		}
-	}
+// Statuses of the JSON output other than the ones of portal.Status.
+const (
+	statusOK    = "ok"
+	statusError = "error"
 )
 
+// List of sub commands.
+var listCommand = []struct {
+	run  func(args []string) error
+	name string
+	desc string
+}{
+	{name: "bench", desc: "requests all the providers repeatedly and reports their latency and accuracy", run: RunBench},
+	{name: "ddns", desc: "updates the A/AAAA record of the given name via RFC 2136 dynamic update", run: RunDDNS},
+	{name: "doctor", desc: "diagnoses the connectivity to the providers step by step", run: RunDoctor},
+	{name: "interfaces", desc: "detects the global/public IP address per network interface which is up", run: RunInterfaces},
+	{name: "providers", desc: "shows the health of the providers. Usage: providers status", run: RunProviders},
+	{name: "serve", desc: "runs an HTTP server which responds the IP address of the caller", run: RunServe},
+	{name: "whois", desc: "shows the owner and the network block of the IP address. Usage: whois [IP address]", run: RunWhois},
+}
+
 /* Flag variables */
 
-// Variable of --verbose option flag.
-var isVerbose bool
+var (
+	// Variable of --verbose option flag.
+	isVerbose bool
+	// Variable of --log-format option flag.
+	logFormat string
+	// Variable of --log-file option flag.
+	logFile string
+	// Variable of --health-file option flag.
+	healthFile string
+	// Variable of --order option flag.
+	orderPolicy string
+	// Variable of --retry option flag.
+	retryNum int
+	// Variable of --retry-delay option flag.
+	retryDelay time.Duration
+	// Variable of --retry-jitter option flag.
+	retryJitter float64
+	// Variable of --retry-on option flag.
+	retryOn string
+	// Variable of --probe option flag.
+	isProbe bool
+	// Variable of --probe-url option flag.
+	probeURL string
+	// Variable of --probe-tls-url option flag.
+	probeTLSURL string
+	// Variable of --probe-pin option flag.
+	probePins string
+	// Variable of --json option flag.
+	isJSON bool
+	// Variable of --secure-only option flag.
+	isSecureOnly bool
+	// Variable of --secure-upgrade option flag.
+	isSecureUpgrade bool
+	// Variable of --tls-min option flag.
+	tlsMin string
+	// Variable of --pin option flag.
+	pins string
+	// Variable of --allow-private option flag.
+	isAllowPrivate bool
+	// Variable of --geo option flag.
+	isGeo bool
+	// Variable of --geo-db option flag.
+	geoDB string
+	// Variable of --rdns option flag.
+	isRDNS bool
+	// Variable of --rdns-server option flag.
+	rdnsServer string
+	// Variable of --interface option flag.
+	netInterface string
+	// Variable of --source option flag.
+	sourceAddr string
+	// Variable of --proxy option flag.
+	proxyURL string
+)
 
 // ----------------------------------------------------------------------------
 //  Main
@@ -46,107 +126,693 @@ var isVerbose bool
 
 //nolint:gochecknoinits // Allow init() only for the main function
 func init() {
-	// Set global/public IP address detection service providers
-	listProvider = provider.GetAll()
 	// Define flag options
-	flag.BoolVar(&isVerbose, "verbose", false, "prints detailed information if any. such as IPv6 and etc.")
+	flag.BoolVar(&isAllowPrivate, "allow-private", false,
+		"trusts the non-global IP addresses such as private, CGNAT or loopback. for the lab networks")
+	flag.BoolVar(&isGeo, "geo", false,
+		"prints the location and the ASN of the IP address from the local MaxMind DB files. see --geo-db")
+	flag.StringVar(&geoDB, "geo-db", "",
+		"comma separated paths of the MaxMind DB files such as GeoLite2-City.mmdb and GeoLite2-ASN.mmdb. "+
+			"implies --geo (default: the ones in "+strings.Join(geo.DirsDefault, ", ")+")")
+	flag.StringVar(&netInterface, "interface", "",
+		"name of the network interface to request the providers from. such as eth1. binds its address")
+	flag.StringVar(&sourceAddr, "source", "",
+		"source IP address to request the providers from. such as 192.0.2.10. for the multi-homed hosts")
+	flag.StringVar(&proxyURL, "proxy", "",
+		"URL of the proxy to request via. http://, https:// or socks5:// with user:pass@ if needed. "+
+			"overrides HTTP(S)_PROXY env")
+	flag.BoolVar(&isRDNS, "rdns", false,
+		"prints the reverse DNS name of the IP address and whether it is forward-confirmed (FCrDNS)")
+	flag.StringVar(&rdnsServer, "rdns-server", "",
+		"address of the DNS resolver for --rdns such as 1.1.1.1 or 127.0.0.1:5353. implies --rdns (default: the system resolver)")
+	flag.BoolVar(&isVerbose, "verbose", false, "prints detailed information if any to STDERR. such as IPv6 and etc.")
+	flag.StringVar(&logFormat, "log-format", string(info.FormatText), "format of the verbose logs. text, json or logfmt")
+	flag.StringVar(&logFile, "log-file", "", "path to the file to append the logs instead of STDERR. implies --verbose")
+	flag.StringVar(&orderPolicy, "order", string(whereami.PolicyRandom),
+		"ordering policy of the providers to request. random, fastest, round-robin or weighted-random")
+	flag.StringVar(&healthFile, "health-file", "",
+		"path to the file to persist the health of the providers (default \"<user cache dir>/whereami/health.json\")")
+	flag.IntVar(&retryNum, "retry", whereami.RetryAttemptsDefault-1,
+		"number of retries of each provider on the transient errors. 0 disables")
+	flag.DurationVar(&retryDelay, "retry-delay", whereami.RetryDelayDefault,
+		"delay before the first retry. doubled on each retry up to "+whereami.RetryDelayMaxDefault.String())
+	flag.Float64Var(&retryJitter, "retry-jitter", whereami.RetryJitterDefault,
+		"ratio of the retry delay to randomize between 0 and 1")
+	flag.StringVar(&retryOn, "retry-on", "network,timeout,5xx",
+		"comma separated classes of the errors to retry. network, timeout and/or 5xx")
+	flag.BoolVar(&isProbe, "probe", false,
+		"probes the network for a captive portal and the TLS interception before requesting the providers")
+	flag.StringVar(&probeURL, "probe-url", "",
+		"URL of the HTTP probe to detect a captive portal. it must respond 204. implies --probe "+
+			"(default with --probe: \""+portal.ProbeURLDefault+"\")")
+	flag.StringVar(&probeTLSURL, "probe-tls-url", "",
+		"URL of the HTTPS probe to detect the TLS interception. implies --probe "+
+			"(default with --probe: \""+portal.TLSProbeURLDefault+"\")")
+	flag.StringVar(&probePins, "probe-pin", "",
+		"comma separated pins of the HTTPS probe as <base64 SHA-256 of the public key>. "+
+			"detects the interception by a CA trusted by the system")
+	flag.BoolVar(&isJSON, "json", false, "prints the result in JSON with the status of the network")
+	flag.BoolVar(&isSecureOnly, "secure-only", false, "lets only the providers over HTTPS vote. the plaintext ones are excluded")
+	flag.BoolVar(&isSecureUpgrade, "secure-upgrade", false,
+		"requests the plaintext providers over HTTPS instead of excluding them. implies --secure-only")
+	flag.StringVar(&tlsMin, "tls-min", "1.2", "minimum TLS version of the providers with --secure-only. 1.2 or 1.3")
+	flag.StringVar(&pins, "pin", "",
+		"comma separated pins of the providers as host=<base64 SHA-256 of the public key>. implies --secure-only")
+	flag.Usage = usage
 }
 
 func main() {
-	flag.Parse()          // Parse the flag options
-	info.Clear()          // Ensure to clear the log before run
-	util.ExitOnErr(Run()) // Print the current global/public IP address
+	flag.Parse() // Parse the flag options
+	info.Clear() // Ensure to clear the log before run
+
+	closeLog, err := setLogOutput()
+	exitOnErr(err)
+
+	err = runCommand(flag.Args()) // Print the current global/public IP address
+
+	closeLog()
+	exitOnErr(err)
+}
+
+// Prints the usage of the command and its sub commands.
+func usage() {
+	out := flag.CommandLine.Output()
+	name := filepath.Base(os.Args[0])
+
+	fmt.Fprintf(out, "Usage of %v:\n", name)
+	fmt.Fprintf(out, "  %v [options] [command] [command options]\n\n", name)
+	fmt.Fprintf(out, "Commands:\n")
+
+	for _, cmd := range listCommand {
+		fmt.Fprintf(out, "  %-11v %v\n", cmd.name, cmd.desc)
+	}
+
+	fmt.Fprintf(out, "\n  If no command is given, it prints the current global/public IP address.\n")
+	fmt.Fprintf(out, "  Use \"%v [command] -help\" to see the options of the command.\n\n", name)
+	fmt.Fprintf(out, "Options:\n")
+	flag.PrintDefaults()
 }
 
 // ----------------------------------------------------------------------------
 //  Functions
 // ----------------------------------------------------------------------------
 
-// Returns providers in random order.
-func getRandProviders() []provider.Provider {
-	l := listProvider
+// Probes the network with the --probe-url and --probe-tls-url before trusting
+// the providers. It returns an error if the network is behind a captive portal
+// or HTTPS is intercepted. The result is empty unless --probe or any of the
+// probe URLs is given, not to request the probe servers without notice.
+//
+// The network is regarded as open if the probes could not be reached, since the
+// probe server may be blocked while the providers are not.
+func checkNetwork() (portal.Result, error) {
+	client, err := sourceClient()
+	if err != nil {
+		return portal.Result{}, err
+	}
+
+	return probeNetwork(client)
+}
+
+// Probes the network via the client. If the client is nil, the default one of
+// the detector is used.
+func probeNetwork(client *http.Client) (portal.Result, error) {
+	pinsProbe, err := netutil.ParsePins(probePins)
+	if err != nil {
+		return portal.Result{}, newUsageError(errors.Wrap(err, "invalid --probe-pin"))
+	}
+
+	if !isProbe && probeURL == "" && probeTLSURL == "" {
+		return portal.Result{}, nil
+	}
+
+	detector := newDetector()
+	detector.ProbeURL = probeURL
+	detector.TLSProbeURL = probeTLSURL
+
+	// Only the probes given if any, otherwise the default ones
+	if probeURL == "" && probeTLSURL == "" {
+		detector.ProbeURL = portal.ProbeURLDefault
+		detector.TLSProbeURL = portal.TLSProbeURLDefault
+	}
+	detector.Pins = pinsProbe
+
+	if client != nil {
+		detector.Client = client
+	}
+
+	result := detector.Detect(info.NewContext(context.Background(), info.Default()))
+
+	if result.Status == portal.StatusUnknown {
+		info.Default().Warn("failed to probe the network: "+result.Detail, "status", string(result.Status))
+	}
+
+	return result, errors.WithStack(result.Err())
+}
+
+// Returns the resolver of the flags which requests the providers via the
+// --interface, --source and --proxy flags. See newResolverOf.
+func newResolver() (*whereami.Resolver, *health.Tracker, error) {
+	config, err := netConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return newResolverOf(config)
+}
+
+// Returns the resolver of the flags which requests the providers via the config
+// and the health tracker it records to. The health is tracked per source
+// address of the config. Use resolveIP to save the tracker after resolving.
+func newResolverOf(config netutil.Config) (*whereami.Resolver, *health.Tracker, error) {
+	policy, err := whereami.ParsePolicy(orderPolicy)
+	if err != nil {
+		return nil, nil, newUsageError(errors.Wrap(err, "invalid --order"))
+	}
+
+	retry, err := retryPolicy()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secure, err := securePolicy()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tracker := loadHealth(config.Source)
+
+	opts := []whereami.Option{
+		whereami.WithProviders(getProviders()...),
+		whereami.WithQuorum(whereami.QuorumDefault),
+		whereami.WithLogger(info.Default()),
+		whereami.WithHealth(tracker),
+		whereami.WithPolicy(policy),
+		whereami.WithRetry(retry),
+		whereami.WithAllowPrivate(isAllowPrivate),
+	}
+
+	if secure != nil {
+		opts = append(opts, whereami.WithSecureOnly(*secure))
+	}
+
+	// nil for the zero config, to use http.DefaultClient
+	if client := clientOf(config); client != nil {
+		opts = append(opts, whereami.WithHTTPClient(client))
+	}
+
+	return whereami.New(opts...), tracker, nil
+}
+
+// Returns the IP address agreed by the providers of the resolver. Then saves
+// the health recorded to the tracker.
+func resolveIP(resolver *whereami.Resolver, tracker *health.Tracker) (string, error) {
+	result, err := resolver.Resolve(context.Background())
+
+	// Failing to persist the health should not fail the detection
+	if errSave := tracker.Save(); errSave != nil {
+		info.Default().Warn(errSave.Error())
+	}
+
+	if err != nil {
+		var errDisagree *whereami.DisagreementError
+		if errors.As(err, &errDisagree) {
+			printDisagreement(os.Stderr, errDisagree)
+		}
+
+		return "", errors.Wrap(err, "failed to detect the global/public IP address")
+	}
+
+	return result.IP.String(), nil
+}
+
+// Returns the health tracker of the providers persisted in the --health-file.
+// If the source address is not nil, the one of the source is returned. See
+// healthPathOf.
+//
+// If the state file is broken, it returns an empty tracker which overwrites the
+// file, since the health is just a cache and should not fail the detection.
+func loadHealth(source net.IP) *health.Tracker {
+	pathFile := healthFile
+
+	if pathFile == "" {
+		pathDefault, err := getPathHealth()
+		if err != nil {
+			info.Default().Warn(err.Error())
+		}
+
+		pathFile = pathDefault
+	}
+
+	// The health via an uplink does not tell the one via another
+	if source != nil && pathFile != "" {
+		pathFile = healthPathOf(pathFile, source)
+	}
+
+	tracker, err := health.Load(pathFile)
+	if err != nil {
+		info.Default().Warn(err.Error())
+
+		tracker = health.New()
+		tracker.Path = pathFile
+	}
+
+	return tracker
+}
+
+// Returns the location and the ASN of the IP address from the databases of the
+// --geo-db flag, or the ones found in the default directories.
+func lookupGeo(ipAddress string) (*geo.Info, error) {
+	reader, err := geo.Open(splitList(geoDB)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look up the location")
+	}
+
+	defer reader.Close()
+
+	info.Default().Debug("looking up the location", "ip", ipAddress, "databases", strings.Join(reader.Paths(), ","))
+
+	result, err := reader.Lookup(net.ParseIP(ipAddress))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look up the location")
+	}
+
+	return result, nil
+}
+
+// Returns the reverse DNS of the IP address from the resolver of the
+// --rdns-server flag, or the system resolver.
+func lookupRDNS(ipAddress string) (*rdns.Result, error) {
+	config, err := netConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := rdns.New(rdnsServer)
+
+	// Over TCP since the proxies do not relay UDP. --rdns-server is required with
+	// --proxy, see checkFlags.
+	if config.Proxy != nil {
+		resolver.Dial = func(ctx context.Context, _, address string) (net.Conn, error) {
+			return config.DialContext(ctx, "tcp", address)
+		}
+	}
+
+	info.Default().Debug("looking up the reverse DNS", "ip", ipAddress, "server", rdnsServer)
+
+	result, err := resolver.Lookup(net.ParseIP(ipAddress))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look up the reverse DNS")
+	}
+
+	info.Default().Debug("reverse DNS", "ip", ipAddress, "names", strings.Join(result.Names, ","),
+		"confirmed", strings.Join(result.Confirmed, ","))
+
+	return result, nil
+}
+
+// Returns the retry policy of the --retry* flags.
+func retryPolicy() (whereami.RetryPolicy, error) {
+	classes, err := whereami.ParseRetryClasses(retryOn)
+	if err != nil {
+		return whereami.RetryPolicy{}, newUsageError(errors.Wrap(err, "invalid --retry-on"))
+	}
+
+	if retryNum < 0 {
+		return whereami.RetryPolicy{}, newUsageError(errors.Errorf("invalid --retry: must be 0 or more. given: %v", retryNum))
+	}
+
+	if retryJitter < 0 || retryJitter > 1 {
+		return whereami.RetryPolicy{}, newUsageError(
+			errors.Errorf("invalid --retry-jitter: must be between 0 and 1. given: %v", retryJitter))
+	}
+
+	policy := whereami.DefaultRetryPolicy()
+	policy.On = classes
+	policy.MaxAttempts = retryNum + 1
+	policy.BaseDelay = retryDelay
+	policy.Jitter = retryJitter
+
+	return policy, nil
+}
+
+// Returns the HTTP client of netConfig. It returns nil if none of the flags is
+// set, to use the default one.
+func sourceClient() (*http.Client, error) {
+	config, err := netConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return clientOf(config), nil
+}
 
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(l), func(i, j int) { l[i], l[j] = l[j], l[i] })
+// Returns the HTTP client of the config. It returns nil if the config is the
+// zero value, to use the default one.
+func clientOf(config netutil.Config) *http.Client {
+	if config.Source == nil && config.Proxy == nil {
+		return nil
+	}
 
-	return l
+	return config.Client()
 }
 
-// Calls GetIP method from the given provider and returns the detected IP address.
-func request(prov provider.Provider) (net.IP, error) {
-	ipAddress, err := prov.GetIP()
+// Returns the settings of the outgoing connections of the --interface, --source
+// and --proxy flags.
+func netConfig() (netutil.Config, error) {
+	var (
+		config netutil.Config
+		err    error
+	)
 
 	switch {
-	case err != nil:
-		errMsg := fmt.Sprintf("provider %v returned an error: %v", prov, err.Error())
+	case netInterface != "" && sourceAddr != "":
+		return config, newUsageError(errors.New("--interface and --source can not be used together"))
+	case netInterface != "":
+		config.Source, err = netutil.InterfaceAddr(netInterface)
+		if err != nil {
+			return config, newUsageError(errors.Wrap(err, "invalid --interface"))
+		}
+	case sourceAddr != "":
+		config.Source, err = ipaddr.Parse(sourceAddr)
+		if err != nil {
+			return config, newUsageError(errors.Wrap(err, "invalid --source"))
+		}
+	}
+
+	if proxyURL != "" {
+		config.Proxy, err = netutil.ParseProxy(proxyURL)
+		if err != nil {
+			return config, newUsageError(errors.Wrap(err, "invalid --proxy"))
+		}
+	}
+
+	if config.Source != nil {
+		info.Default().Debug("binding the source address", "interface", netInterface, "source", config.Source.String())
+	}
+
+	if config.Proxy != nil {
+		info.Default().Debug("connecting via the proxy", "proxy", config.Proxy.Redacted())
+	}
+
+	return config, nil
+}
+
+// Returns the URL of the --proxy flag to report, without the password. Empty if
+// not set or invalid.
+func proxyReport() string {
+	if proxyURL == "" {
+		return ""
+	}
+
+	parsed, err := netutil.ParseProxy(proxyURL)
+	if err != nil {
+		return ""
+	}
+
+	return parsed.Redacted()
+}
+
+// Returns the path of the health state file per source address. Such as
+// "health.192.0.2.10.json" for "health.json".
+func healthPathOf(pathFile string, source net.IP) string {
+	ext := filepath.Ext(pathFile)
+	// The colons of IPv6 are not allowed in the file names of Windows
+	name := strings.ReplaceAll(source.String(), ":", "-")
 
-		return nil, errors.New(errMsg)
-	case ipAddress == nil:
-		errMsg := fmt.Sprintf("provider %v returned an empty IP address", prov.Name())
+	return strings.TrimSuffix(pathFile, ext) + "." + name + ext
+}
+
+// Returns the secure policy of the --secure-only, --secure-upgrade, --tls-min
+// and --pin flags. It returns nil if the secure mode is off.
+func securePolicy() (*whereami.SecurePolicy, error) {
+	pinsHost, err := whereami.ParsePins(pins)
+	if err != nil {
+		return nil, newUsageError(errors.Wrap(err, "invalid --pin"))
+	}
 
-		return nil, errors.New(errMsg)
+	if !isSecureOnly && !isSecureUpgrade && len(pinsHost) == 0 {
+		return nil, nil //nolint:nilnil // nil policy means the secure mode is off
+	}
+
+	policy := &whereami.SecurePolicy{Pins: pinsHost, Upgrade: isSecureUpgrade}
+
+	switch tlsMin {
+	case "1.2":
+		policy.MinVersion = tls.VersionTLS12
+	case "1.3":
+		policy.MinVersion = tls.VersionTLS13
 	default:
-		return ipAddress, nil
+		return nil, newUsageError(errors.Errorf("invalid --tls-min: must be 1.2 or 1.3. given: %v", tlsMin))
+	}
+
+	return policy, nil
+}
+
+// Prints the answers of the providers grouped by the IP address and the likely
+// causes of the disagreement.
+func printDisagreement(out io.Writer, errDisagree *whereami.DisagreementError) {
+	fmt.Fprintf(out, "Providers did not agree on the global/public IP address (quorum: %v)\n\n",
+		errDisagree.Result.Quorum)
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "IP\tASN\tOWNER\tPROVIDERS")
+
+	for _, vote := range errDisagree.Result.Votes() {
+		asn, owner := "-", "-"
+
+		if vote.ASN != 0 {
+			asn = "AS" + strconv.Itoa(vote.ASN)
+		}
+
+		if vote.Owner != "" {
+			owner = vote.Owner
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", vote.IP, asn, owner, strings.Join(vote.Providers, ", "))
+	}
+
+	for _, answer := range errDisagree.Result.Answers {
+		if answer.Err != nil {
+			// Only the first line since the error may contain the response body
+			msg := strings.SplitN(answer.Err.Error(), "\n", 2)[0]
+
+			fmt.Fprintf(writer, "(error)\t-\t-\t%v: %v\n", answer.Provider, msg)
+		}
+	}
+
+	writer.Flush()
+
+	if len(errDisagree.Causes) == 0 {
+		fmt.Fprintln(out)
+
+		return
+	}
+
+	fmt.Fprintf(out, "\nLikely causes:\n")
+
+	for _, cause := range errDisagree.Causes {
+		fmt.Fprintf(out, "  - %v: %v\n", cause, cause.Description())
 	}
+
+	fmt.Fprintln(out)
 }
 
-// Returns the IPv4 address if all maxNumUse providers returns the same IP.
-func getIPPublic(maxNumUse int) (string, error) {
-	if maxNumUse == 0 {
-		return "", errors.New("error: zero provider. you need at least one provider")
+// Prints the detected IP address, or the output in JSON if --json is set. The
+// verbose information is streamed to STDERR or the log file during the process.
+// See setLogOutput.
+func printResult(out output) error {
+	if isJSON {
+		if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+			return errors.Wrap(err, "failed to encode the result")
+		}
+	} else if out.IP != "" {
+		//nolint:forbidigo // Allow fmt.Println due to the main function
+		fmt.Printf("%v", out.IP)
+
+		if out.Geo != nil {
+			//nolint:forbidigo // Allow fmt.Println due to the main function
+			fmt.Printf("\n%v", out.Geo)
+		}
+
+		if out.RDNS != nil {
+			//nolint:forbidigo // Allow fmt.Println due to the main function
+			fmt.Printf("\n%v", out.RDNS)
+		}
+
+		if out.Owner != nil {
+			//nolint:forbidigo // Allow fmt.Println due to the main function
+			fmt.Printf("\n%v", formatOwner(out.Owner))
+		}
 	}
 
-	providers := getRandProviders()
-	if lenProv := len(providers); maxNumUse > lenProv {
-		maxNumUse = lenProv
+	if out.Status == statusOK {
+		// Force sleep to avoide large number of requests.
+		time.Sleep(sleepTime * time.Second)
 	}
 
-	foundIP := make(map[string]int)
+	return nil
+}
+
+// Sets the output of the verbose logs according to the flags. The returned
+// function stops the output and closes the log file if any.
+//
+// The logs are streamed to the file if --log-file is set, or to STDERR if
+// --verbose is set. Otherwise they are only kept in the buffer of info package.
+func setLogOutput() (func(), error) {
+	format, err := info.ParseFormat(logFormat)
+	if err != nil {
+		return func() {}, newUsageError(errors.Wrap(err, "invalid --log-format"))
+	}
 
-	for _, prov := range providers {
-		ipAddress, err := request(prov)
+	switch {
+	case logFile != "":
+		//nolint:gosec // The path is given by the user
+		file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 		if err != nil {
-			InfoLog(fmt.Sprintf("%v: %v", prov.Name(), err.Error()))
+			return func() {}, errors.Wrap(err, "failed to open the log file")
+		}
+
+		info.Default().SetOutput(file, format)
+
+		return func() {
+			info.Default().SetOutput(nil, format)
+			file.Close()
+		}, nil
+	case isVerbose:
+		info.Default().SetOutput(os.Stderr, format)
+	}
+
+	return func() { info.Default().SetOutput(nil, format) }, nil
+}
 
+// Runs the sub command given in args[0]. If args is empty, it runs Run.
+func runCommand(args []string) error {
+	if len(args) == 0 {
+		return Run()
+	}
+
+	for _, cmd := range listCommand {
+		if cmd.name != args[0] {
 			continue
 		}
 
-		key := ipAddress.String()
+		err := cmd.run(args[1:])
+		// Help flag of the sub command is not an error
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
 
-		InfoLog(fmt.Sprintf(
-			"Provider %v returned the global/public IP as: %v",
-			prov.Name(),
-			key,
-		))
+		return err
+	}
 
-		foundIP[key]++
+	return newUsageError(errors.Errorf("unknown command: %v", args[0]))
+}
 
-		if foundIP[key] == maxNumUse {
-			return key, nil // IP Found!
-		}
+// Returns an error if the combination of the flags can not be run. It is checked
+// before requesting anything.
+func checkFlags() error {
+	// The system resolver can not be routed via the proxy
+	if isRDNS && rdnsServer == "" && proxyURL != "" {
+		return newUsageError(errors.New("--rdns with --proxy requires --rdns-server to look up via the proxy"))
 	}
 
-	return "", errors.New("all returned IP addresses are different from each other")
+	return nil
 }
 
 // Run is the actual function of the app.
 func Run() error {
-	ipAddress, err := getIPPublic(maxNumUseDefault)
-	if err != nil {
+	if err := checkFlags(); err != nil {
 		return err
 	}
 
-	//nolint:forbidigo // Allow fmt.Println due to the main function
-	fmt.Printf("%v", ipAddress)
+	var (
+		out      = output{Status: statusOK, Proxy: proxyReport()}
+		resolver *whereami.Resolver
+		tracker  *health.Tracker
+	)
 
-	// Print verbose information
-	if isVerbose {
-		//nolint:forbidigo // Allow fmt.Println due to the main function
-		fmt.Printf("\n%v", info.Get())
+	network, err := checkNetwork()
+	if network.Status != "" {
+		out.Network = &network
+	}
+
+	if err == nil {
+		resolver, tracker, err = newResolver()
 	}
 
-	// Force sleep to avoide large number of requests.
-	time.Sleep(sleepTime * time.Second)
+	if err == nil {
+		out.IP, err = resolveIP(resolver, tracker)
+	}
 
-	return nil
+	if err == nil && (isGeo || geoDB != "") {
+		out.Geo, err = lookupGeo(out.IP)
+	}
+
+	if err == nil && (isRDNS || rdnsServer != "") {
+		out.RDNS, err = lookupRDNS(out.IP)
+	}
+
+	if err != nil {
+		out.Status = statusOf(err)
+		// Only the first line since the error may contain the response body
+		out.Error = strings.SplitN(err.Error(), "\n", 2)[0]
+	}
+
+	if errPrint := printResult(out); errPrint != nil {
+		return errPrint
+	}
+
+	return err
+}
+
+// Returns the non-empty elements of the comma separated list.
+func splitList(list string) []string {
+	var elems []string
+
+	for _, elem := range strings.Split(list, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+
+	return elems
+}
+
+// Returns the status of the JSON output of the err.
+func statusOf(err error) string {
+	var errUntrusted *portal.UntrustedError
+
+	if errors.As(err, &errUntrusted) {
+		return string(errUntrusted.Result.Status)
+	}
+
+	return statusError
+}
+
+// ============================================================================
+//  Type: output
+// ============================================================================
+
+// output is the result of the app printed with --json.
+type output struct {
+	// Network is the result of the probes. Nil if disabled.
+	Network *portal.Result `json:"network,omitempty"`
+	// Status is "ok", "error" or the status of the untrusted network. Such as
+	// "captive_portal" or "tls_intercepted".
+	Status string `json:"status"`
+	// IP is the global/public IP address detected.
+	IP string `json:"ip,omitempty"`
+	// Proxy is the URL of the proxy requested via, without the password. Empty
+	// if none is set by --proxy.
+	Proxy string `json:"proxy,omitempty"`
+	// Geo is the location and the ASN of the IP address. Nil unless --geo.
+	Geo *geo.Info `json:"geo,omitempty"`
+	// RDNS is the reverse DNS of the IP address. Nil unless --rdns.
+	RDNS *rdns.Result `json:"rdns,omitempty"`
+	// Owner is the owner and the network block of the IP address. Nil unless
+	// the whois command.
+	Owner *whois.Info `json:"owner,omitempty"`
+	// Error is the error message if failed.
+	Error string `json:"error,omitempty"`
 }
@@ -1,14 +1,30 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/KEINOS/go-utiles/util"
+	"github.com/KEINOS/whereami/internal/geotest"
+	"github.com/KEINOS/whereami/pkg/geo"
 	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/portal"
 	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/provider/providers/ipifyorg"
+	"github.com/KEINOS/whereami/pkg/whereami"
+	"github.com/miekg/dns"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -24,17 +40,17 @@ func Test_main_golden(t *testing.T) {
 	restoreFn := backupAndRestore()
 	defer restoreFn()
 
-	dummyIP := "127.0.0.1"
+	dummyIP := "123.123.123.123"
 
 	dFn := func() (net.IP, error) {
 		return net.ParseIP(dummyIP), nil
 	}
 
-	// Mock listProvider with dummy providers
+	// Mock the providers with dummy ones
 	// This value will be recovered by restoreFn.
-	listProvider = []provider.Provider{
+	mockProviders(
 		&DummyStruct{ID: 0, DummyFunc: dFn},
-	}
+	)
 
 	out := capturer.CaptureStdout(func() {
 		main()
@@ -48,115 +64,825 @@ func Test_main_golden_verbose(t *testing.T) {
 	restoreFn := backupAndRestore()
 	defer restoreFn()
 
-	dummyIP := "127.0.0.1"
+	dummyIP := "123.123.123.123"
 
 	dFn := func() (net.IP, error) {
 		return net.ParseIP(dummyIP), nil
 	}
 
-	// Mock listProvider and os.Args with dummy values.
+	// Mock the providers and os.Args with dummy values.
 	// This values will be recovered by restoreFn.
-	listProvider = []provider.Provider{
+	mockProviders(
 		&DummyStruct{ID: 0, DummyFunc: dFn},
-	}
+	)
 	os.Args = []string{
 		t.Name(),    // dummy app name
 		"--verbose", // verbose flag
 	}
 
-	out := capturer.CaptureStdout(func() {
-		main()
+	var outStderr string
+
+	outStdout := capturer.CaptureStdout(func() {
+		outStderr = capturer.CaptureStderr(func() {
+			main()
+		})
 	})
 
-	require.Contains(t, out, "127.0.0.1")
-	require.Contains(t, out, "[LOG]:")
-	require.Contains(t, out, "Provider http://dummy.com/ returned the global/public IP as: 127.0.0.1")
+	require.Equal(t, "123.123.123.123", outStdout, "verbose logs should not be printed to STDOUT")
+	require.Contains(t, outStderr, "[LOG]:")
+	require.Contains(t, outStderr, "Provider http://dummy.com/ returned the global/public IP as: 123.123.123.123")
 }
 
 //nolint:paralleltest // do not parallelize due to mocking global variables
-func Test_main_no_provider_set(t *testing.T) {
+func Test_main_log_format_json(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+	os.Args = []string{t.Name(), "--verbose", "--log-format", "json"}
+
+	out := capturer.CaptureStderr(func() {
+		capturer.CaptureStdout(func() {
+			main()
+		})
+	})
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.NotEmpty(t, lines)
+
+	foundIP := false
+
+	for _, line := range lines {
+		var record map[string]interface{}
+
+		require.NoError(t, json.Unmarshal([]byte(line), &record), "each line should be a JSON object")
+		require.Contains(t, record, "time")
+		require.Contains(t, record, "level")
+		require.Contains(t, record, "msg")
+
+		if record["ip"] == "123.123.123.123" && record["provider"] == "http://dummy.com/" {
+			require.Contains(t, record, "duration")
+
+			foundIP = true
+		}
+	}
+
+	require.True(t, foundIP, "parsed IP should be logged with the provider and duration")
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_log_file(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	pathFile := filepath.Join(t.TempDir(), "whereami.log")
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+	os.Args = []string{t.Name(), "--log-file", pathFile, "--log-format", "logfmt"}
+
+	var outStdout string
+
+	outStderr := capturer.CaptureStderr(func() {
+		outStdout = capturer.CaptureStdout(func() {
+			main()
+		})
+	})
+
+	require.Equal(t, "123.123.123.123", outStdout)
+	require.Empty(t, outStderr, "logs should be written to the file only")
+
+	logs, err := os.ReadFile(pathFile)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(logs), `level=info msg="Provider http://dummy.com/ returned the global/public IP as: 123.123.123.123"`)
+	assert.Contains(t, string(logs), "provider=http://dummy.com/ ip=123.123.123.123 class=global duration=")
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_unknown_log_format(t *testing.T) {
 	restoreFn := backupAndRestore()
 	defer restoreFn()
 
 	var capturedCode int
 
-	// Mock os.Exit. This will be recovered by restoreFn.
 	util.OsExit = func(code int) {
 		capturedCode = code
 	}
 
-	// Mock max provider number to let main() fail.
-	// This will be recovered by restoreFn as well.
-	maxNumUseDefault = 0
+	// Dummy provider since the mocked os.Exit does not stop main()
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+	os.Args = []string{t.Name(), "--log-format", "xml"}
+
+	out := capturer.CaptureStderr(func() {
+		capturer.CaptureStdout(func() {
+			main()
+		})
+	})
+
+	require.Equal(t, ExitUsage, capturedCode)
+	assert.Contains(t, out, "unknown log format: xml")
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_unknown_order(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	var capturedCode int
+
+	util.OsExit = func(code int) {
+		capturedCode = code
+	}
+
+	os.Args = []string{t.Name(), "--order", "slowest"}
 
 	out := capturer.CaptureStderr(func() {
 		main()
 	})
 
-	expectCode := 1
-	actualCode := capturedCode
-	require.Equal(t, expectCode, actualCode, "it should end with status 1 on error")
+	require.Equal(t, ExitUsage, capturedCode)
+	assert.Contains(t, out, "unknown ordering policy: slowest")
+	assert.Contains(t, out, "invalid --order")
+}
 
-	assert.Contains(t, out, "zero provider.")
-	assert.Contains(t, out, "you need at least one provider")
+func Test_order_default(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, string(whereami.PolicyRandom), flag.Lookup("order").DefValue,
+		"the default should be the same as the one of the whereami package")
 }
 
-// ----------------------------------------------------------------------------
-//  getRandProviders()
-// ----------------------------------------------------------------------------
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_invalid_retry(t *testing.T) {
+	for _, test := range []struct {
+		args   []string
+		expect string
+	}{
+		{args: []string{"--retry-on", "network,4xx"}, expect: "unknown retry class: 4xx"},
+		{args: []string{"--retry", "-1"}, expect: "invalid --retry: must be 0 or more"},
+		{args: []string{"--retry-jitter", "1.5"}, expect: "invalid --retry-jitter: must be between 0 and 1"},
+	} {
+		func() {
+			restoreFn := backupAndRestore()
+			defer restoreFn()
+
+			var capturedCode int
+
+			util.OsExit = func(code int) {
+				capturedCode = code
+			}
+
+			os.Args = append([]string{t.Name()}, test.args...)
+
+			out := capturer.CaptureStderr(func() {
+				main()
+			})
+
+			require.Equal(t, ExitUsage, capturedCode, test.args)
+			assert.Contains(t, out, test.expect)
+		}()
+	}
+}
 
 //nolint:paralleltest // do not parallelize due to mocking global variables
-func Test_getRandProviders(t *testing.T) {
+func Test_main_json(t *testing.T) {
 	restoreFn := backupAndRestore()
 	defer restoreFn()
 
-	dFn := func() (net.IP, error) {
-		return net.ParseIP("127.0.0.0"), nil
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+	os.Args = []string{t.Name(), "--json", "--probe-url", srv.URL}
+
+	out := capturer.CaptureStdout(func() {
+		main()
+	})
+
+	var result map[string]interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(out), &result), "output should be a JSON object. got: %v", out)
+	assert.Equal(t, "ok", result["status"])
+	assert.Equal(t, "123.123.123.123", result["ip"])
+	assert.Equal(t, "open", result["network"].(map[string]interface{})["status"]) //nolint:forcetypeassert // test
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_geo(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	pathDB := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+
+	require.NoError(t, geotest.WriteFile(pathDB, "GeoLite2-City", map[string]geotest.Record{
+		"123.123.0.0/16": {
+			"city":     geotest.Record{"names": geotest.Record{"en": "Tokyo"}},
+			"country":  geotest.Record{"iso_code": "JP", "names": geotest.Record{"en": "Japan"}},
+			"location": geotest.Record{"latitude": 35.6895, "longitude": 139.6917},
+		},
+	}))
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+
+	// Plain text
+	os.Args = []string{t.Name(), "--geo-db", pathDB}
+
+	out := capturer.CaptureStdout(func() {
+		main()
+	})
+
+	assert.Equal(t, "123.123.123.123\nTokyo, Japan (JP) 35.6895,139.6917", out)
+
+	// JSON
+	os.Args = []string{t.Name(), "--geo", "--geo-db", pathDB, "--json"}
+
+	out = capturer.CaptureStdout(func() {
+		main()
+	})
+
+	var result struct {
+		Geo geo.Info `json:"geo"`
+		IP  string   `json:"ip"`
 	}
 
-	// Mock listProvider with dummy providers
-	// This value will be recovered by restoreFn.
-	listProvider = []provider.Provider{
-		&DummyStruct{ID: 0, DummyFunc: dFn},
-		&DummyStruct{ID: 1, DummyFunc: dFn},
-		&DummyStruct{ID: 2, DummyFunc: dFn},
-		&DummyStruct{ID: 3, DummyFunc: dFn},
-		&DummyStruct{ID: 4, DummyFunc: dFn},
+	require.NoError(t, json.Unmarshal([]byte(out), &result), "output should be a JSON object. got: %v", out)
+	assert.Equal(t, "123.123.123.123", result.IP)
+	assert.Equal(t, "JP", result.Geo.Country)
+	assert.Equal(t, "Tokyo", result.Geo.City)
+	assert.Equal(t, 139.6917, result.Geo.Longitude)
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_geo_error(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+
+	pathMissing := filepath.Join(t.TempDir(), "missing.mmdb")
+	pathEmpty := filepath.Join(t.TempDir(), "empty.mmdb")
+
+	require.NoError(t, geotest.WriteFile(pathEmpty, "GeoLite2-City", nil))
+
+	for _, test := range []struct {
+		path   string
+		expect string
+	}{
+		{path: pathMissing, expect: "failed to open the database: " + pathMissing},
+		{path: pathEmpty, expect: "IP address not found in the databases"},
+	} {
+		os.Args = []string{t.Name(), "--geo-db", test.path, "--json"}
+
+		capturedStatus := 0
+		util.OsExit = func(code int) {
+			capturedStatus = code
+		}
+
+		out := capturer.CaptureOutput(func() {
+			main()
+		})
+
+		assert.Equal(t, ExitFailure, capturedStatus, "path: %v", test.path)
+		assert.Contains(t, out, `"ip":"123.123.123.123"`, "the IP address should be printed anyway")
+		assert.Contains(t, out, test.expect)
+	}
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_rdns(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	server := startDummyResolver(t, map[string]string{
+		"123.123.123.123.in-addr.arpa.": "PTR mail.example.com.",
+		"mail.example.com.":             "A 123.123.123.123",
+	})
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+
+	// Plain text
+	os.Args = []string{t.Name(), "--rdns-server", server}
+
+	out := capturer.CaptureStdout(func() {
+		main()
+	})
+
+	assert.Equal(t, "123.123.123.123\nmail.example.com (forward-confirmed)", out)
+
+	// JSON
+	os.Args = []string{t.Name(), "--rdns", "--rdns-server", server, "--json"}
+
+	out = capturer.CaptureStdout(func() {
+		main()
+	})
+
+	var result struct {
+		RDNS struct {
+			Name             string `json:"name"`
+			ForwardConfirmed bool   `json:"forward_confirmed"`
+		} `json:"rdns"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(out), &result), "output should be a JSON object. got: %v", out)
+	assert.Equal(t, "mail.example.com", result.RDNS.Name)
+	assert.True(t, result.RDNS.ForwardConfirmed)
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_rdns_error(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	server := startDummyResolver(t, nil) // SERVFAIL on any query
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+
+	os.Args = []string{t.Name(), "--rdns-server", server, "--json"}
+
+	capturedStatus := 0
+	util.OsExit = func(code int) {
+		capturedStatus = code
 	}
 
-	randProviders := getRandProviders()
-	resultOK := false
+	out := capturer.CaptureOutput(func() {
+		main()
+	})
+
+	assert.Equal(t, ExitFailure, capturedStatus)
+	assert.Contains(t, out, `"ip":"123.123.123.123"`, "the IP address should be printed anyway")
+	assert.Contains(t, out, "failed to look up the reverse DNS")
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_rdns_proxy_without_server(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	isRequested := false
 
-	for index, obj := range randProviders {
-		tmpObj, ok := obj.(*DummyStruct)
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			isRequested = true
+
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
 
-		require.True(t, ok, "it should be able to convert to *DummyStruct")
+	os.Args = []string{t.Name(), "--proxy", "http://127.0.0.1:3128", "--rdns"}
 
-		id := tmpObj.ID
-		if index != id {
-			resultOK = true
+	capturedStatus := 0
+	util.OsExit = func(code int) {
+		capturedStatus = code
+	}
+
+	var outStdout string
+
+	outStderr := capturer.CaptureStderr(func() {
+		outStdout = capturer.CaptureStdout(func() {
+			main()
+		})
+	})
+
+	assert.Equal(t, ExitUsage, capturedStatus, "the system resolver should not be used outside the proxy")
+	assert.Contains(t, outStderr, "--rdns with --proxy requires --rdns-server")
+	assert.Empty(t, outStdout, "nothing should be printed before the lookup")
+	assert.False(t, isRequested, "the providers should not be requested")
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_proxy(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	// HTTP proxy stand-in which answers the requests to the providers by itself
+	proxySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !req.URL.IsAbs() || req.Header.Get("Proxy-Authorization") == "" {
+			http.Error(w, "not requested as a proxy", http.StatusBadRequest)
+
+			return
 		}
+
+		fmt.Fprint(w, `{"ip":"123.123.123.123"}`)
+	}))
+	defer proxySrv.Close()
+
+	prov := ipifyorg.New()
+	prov.SetURL("http://provider.example/")
+
+	mockProviders(prov)
+
+	os.Args = []string{t.Name(), "--json", "--proxy", "http://user:secret@" + proxySrv.Listener.Addr().String()}
+
+	out := capturer.CaptureStdout(func() {
+		main()
+	})
+
+	var result struct {
+		IP    string `json:"ip"`
+		Proxy string `json:"proxy"`
 	}
 
-	require.True(t, resultOK, "the returned slice should be shuffled")
+	require.NoError(t, json.Unmarshal([]byte(out), &result), "output should be a JSON object. got: %v", out)
+	assert.Equal(t, "123.123.123.123", result.IP, "the IP address should be the one seen through the proxy")
+	assert.Equal(t, "http://user:xxxxx@"+proxySrv.Listener.Addr().String(), result.Proxy,
+		"the password should be redacted")
+	assert.NotContains(t, out, "secret")
 }
 
-// ----------------------------------------------------------------------------
-//  InfoLog()
-// ----------------------------------------------------------------------------
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_proxy_invalid(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	for _, rawURL := range []string{"socks4://127.0.0.1", "proxy.example:3128", "http://:3128"} {
+		os.Args = []string{t.Name(), "--proxy", rawURL}
+
+		capturedStatus := 0
+		util.OsExit = func(code int) {
+			capturedStatus = code
+		}
+
+		out := capturer.CaptureOutput(func() {
+			main()
+		})
+
+		assert.Equal(t, ExitUsage, capturedStatus, "proxy: %v", rawURL)
+		assert.Contains(t, out, "invalid --proxy")
+	}
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_probe_opt_in(t *testing.T) {
+	for _, test := range []struct {
+		args   []string
+		expect []string
+	}{
+		{args: nil, expect: nil},
+		{args: []string{"--probe"}, expect: []string{portal.ProbeURLDefault, portal.TLSProbeURLDefault}},
+		{args: []string{"--probe-url", "http://probe.example/204"}, expect: []string{"http://probe.example/204"}},
+	} {
+		func() {
+			restoreFn := backupAndRestore()
+			defer restoreFn()
+
+			var requested []string
+
+			// Record the probes requested instead of requesting them
+			newDetector = func() *portal.Detector {
+				detector := portal.New()
+				detector.Client = &http.Client{
+					Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+						requested = append(requested, req.URL.String())
+
+						return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: req}, nil
+					}),
+				}
+
+				return detector
+			}
+
+			mockProviders(
+				&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+					return net.ParseIP("123.123.123.123"), nil
+				}},
+			)
+
+			os.Args = append([]string{t.Name()}, test.args...)
+
+			out := capturer.CaptureStdout(func() {
+				main()
+			})
+
+			assert.Equal(t, "123.123.123.123", out, "args: %v", test.args)
+			assert.Equal(t, test.expect, requested, "args: %v", test.args)
+		}()
+	}
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_captive_portal(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "http://192.168.1.1/login", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			t.Error("the providers should not be requested behind a captive portal")
+
+			return net.ParseIP("192.168.1.1"), nil
+		}},
+	)
+	os.Args = []string{t.Name(), "--json", "--probe-url", srv.URL}
+
+	var capturedStatus int
+
+	util.OsExit = func(code int) {
+		capturedStatus = code
+	}
+
+	var outStdout string
+
+	outStderr := capturer.CaptureStderr(func() {
+		outStdout = capturer.CaptureStdout(func() {
+			main()
+		})
+	})
+
+	assert.Equal(t, ExitCaptivePortal, capturedStatus)
+	assert.Contains(t, outStderr, "you are behind a captive portal: ")
+
+	var result struct {
+		Network struct {
+			Status   string `json:"status"`
+			Location string `json:"location"`
+		} `json:"network"`
+		Status string `json:"status"`
+		IP     string `json:"ip"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(outStdout), &result), "output should be a JSON object. got: %v", outStdout)
+	assert.Equal(t, "captive_portal", result.Status)
+	assert.Equal(t, "captive_portal", result.Network.Status)
+	assert.Equal(t, "http://192.168.1.1/login", result.Network.Location)
+	assert.Empty(t, result.IP)
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_tls_intercepted(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	// The certificate of the test server is not trusted by the system
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+	os.Args = []string{t.Name(), "--probe-tls-url", srv.URL}
+
+	var capturedStatus int
+
+	util.OsExit = func(code int) {
+		capturedStatus = code
+	}
+
+	var outStdout string
+
+	outStderr := capturer.CaptureStderr(func() {
+		outStdout = capturer.CaptureStdout(func() {
+			main()
+		})
+	})
+
+	assert.Equal(t, ExitIntercepted, capturedStatus)
+	assert.Contains(t, outStderr, "HTTPS is being intercepted: ")
+	assert.Empty(t, outStdout, "no IP address should be printed")
+}
 
 //nolint:paralleltest // do not parallelize due to mocking global variables
-func TestInfoLog(t *testing.T) {
+func Test_main_tls_intercepted_by_trusted_ca(t *testing.T) {
 	restoreFn := backupAndRestore()
 	defer restoreFn()
 
-	infoLog = func(logs ...string) (int, error) {
-		return 0, errors.New("forced error")
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// Trust the CA of the test server. As the one installed in the system by
+	// the intercepting proxy.
+	newDetector = func() *portal.Detector {
+		detector := portal.New()
+		detector.RootCAs = srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs //nolint:forcetypeassert // test
+
+		return detector
 	}
 
-	assert.PanicsWithError(t, "forced error", func() {
-		InfoLog("foo")
-	}, "on panic it should contain the error message")
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+
+	hash := sha256.Sum256([]byte("public key of the genuine probe server"))
+	os.Args = []string{
+		t.Name(), "--probe-tls-url", srv.URL, "--probe-pin", base64.StdEncoding.EncodeToString(hash[:]),
+	}
+
+	var capturedStatus int
+
+	util.OsExit = func(code int) {
+		capturedStatus = code
+	}
+
+	outStderr := capturer.CaptureStderr(func() {
+		main()
+	})
+
+	assert.Equal(t, ExitIntercepted, capturedStatus)
+	assert.Contains(t, outStderr, "does not match the pins")
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_invalid_secure(t *testing.T) {
+	for _, test := range []struct {
+		args   []string
+		expect string
+	}{
+		{args: []string{"--secure-only", "--tls-min", "1.1"}, expect: "invalid --tls-min: must be 1.2 or 1.3"},
+		{args: []string{"--pin", "ipinfo.io=YWJj"}, expect: "invalid pin of ipinfo.io"},
+		{args: []string{"--probe-pin", "YWJj"}, expect: "malformed pin: YWJj"},
+	} {
+		func() {
+			restoreFn := backupAndRestore()
+			defer restoreFn()
+
+			var capturedCode int
+
+			util.OsExit = func(code int) {
+				capturedCode = code
+			}
+
+			os.Args = append([]string{t.Name()}, test.args...)
+
+			out := capturer.CaptureStderr(func() {
+				main()
+			})
+
+			require.Equal(t, ExitUsage, capturedCode, test.args)
+			assert.Contains(t, out, test.expect)
+		}()
+	}
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_secure_only(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	// The name of the dummy provider is a plaintext URL
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			t.Error("the plaintext provider should not be requested in the secure mode")
+
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+	os.Args = []string{t.Name(), "--secure-only"}
+
+	var capturedCode int
+
+	util.OsExit = func(code int) {
+		capturedCode = code
+	}
+
+	out := capturer.CaptureStderr(func() {
+		main()
+	})
+
+	assert.Equal(t, ExitNoProviders, capturedCode)
+	assert.Contains(t, out, "zero provider")
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_allow_private(t *testing.T) {
+	for _, test := range []struct {
+		args         []string
+		expectStdout string
+		expectCode   int
+	}{
+		{args: nil, expectStdout: "", expectCode: ExitProvider},
+		{args: []string{"--allow-private"}, expectStdout: "192.168.1.200", expectCode: ExitOK},
+	} {
+		func() {
+			restoreFn := backupAndRestore()
+			defer restoreFn()
+
+			mockProviders(
+				&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+					return net.ParseIP("192.168.1.200"), nil
+				}},
+			)
+			os.Args = append([]string{t.Name()}, test.args...)
+
+			capturedCode := ExitOK
+
+			util.OsExit = func(code int) {
+				capturedCode = code
+			}
+
+			var outStdout string
+
+			outStderr := capturer.CaptureStderr(func() {
+				outStdout = capturer.CaptureStdout(func() {
+					main()
+				})
+			})
+
+			assert.Equal(t, test.expectCode, capturedCode, test.args)
+			assert.Equal(t, test.expectStdout, outStdout, test.args)
+
+			if test.expectCode != ExitOK {
+				assert.Contains(t, outStderr, "returned a non-global IP address: 192.168.1.200 (private)")
+			}
+		}()
+	}
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_main_no_provider_set(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	var capturedCode int
+
+	// Mock os.Exit. This will be recovered by restoreFn.
+	util.OsExit = func(code int) {
+		capturedCode = code
+	}
+
+	// Mock zero provider to let main() fail.
+	// This will be recovered by restoreFn as well.
+	mockProviders()
+
+	out := capturer.CaptureStderr(func() {
+		main()
+	})
+
+	expectCode := ExitNoProviders
+	actualCode := capturedCode
+	require.Equal(t, expectCode, actualCode, "it should end with status 4 on no provider")
+
+	assert.Contains(t, out, "zero provider.")
+	assert.Contains(t, out, "you need at least one provider")
+}
+
+// ----------------------------------------------------------------------------
+//  runCommand()
+// ----------------------------------------------------------------------------
+
+func Test_runCommand_unknown_command(t *testing.T) {
+	t.Parallel()
+
+	err := runCommand([]string{"unknown"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown command: unknown")
+	assert.Equal(t, ExitUsage, exitCode(err))
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func Test_runCommand_help_of_command(t *testing.T) {
+	out := capturer.CaptureStderr(func() {
+		err := runCommand([]string{"ddns", "-help"})
+
+		require.NoError(t, err, "help flag of the command should not be an error")
+	})
+
+	assert.Contains(t, out, "Usage of ddns:")
 }
 
 // ----------------------------------------------------------------------------
@@ -173,11 +899,11 @@ func TestRun_error_from_GetIP_method(t *testing.T) {
 		return nil, errors.New("forced error in GetIP method")
 	}
 
-	// Mock listProvider with dummy providers
+	// Mock the providers with dummy ones
 	// This value will be recovered by restoreFn.
-	listProvider = []provider.Provider{
+	mockProviders(
 		&DummyStruct{ID: 0, DummyFunc: dFn},
-	}
+	)
 
 	err := Run()
 	require.Error(t, err)
@@ -198,11 +924,11 @@ func TestRun_error_if_Get_returns_nil(t *testing.T) {
 		return nil, nil
 	}
 
-	// Mock listProvider with dummy providers
+	// Mock the providers with dummy ones
 	// This value will be recovered by restoreFn.
-	listProvider = []provider.Provider{
+	mockProviders(
 		&DummyStruct{ID: 0, DummyFunc: dFn},
-	}
+	)
 
 	err := Run()
 	require.Error(t, err)
@@ -216,26 +942,60 @@ func TestRun_error_if_providers_returns_different_IPs(t *testing.T) {
 	restoreFn := backupAndRestore()
 	defer restoreFn()
 
-	// Mock listProvider with dummy providers
+	// Mock the providers with dummy ones
 	// This value will be recovered by restoreFn.
-	listProvider = []provider.Provider{
+	mockProviders(
 		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
-			return net.ParseIP("127.0.0.1"), nil
+			return net.ParseIP("123.123.123.123"), nil
 		}},
 		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
-			return net.ParseIP("169.254.1.1"), nil
+			return net.ParseIP("111.111.111.111"), nil
 		}},
-	}
+	)
 
-	err := Run()
+	var err error
+
+	out := capturer.CaptureStderr(func() {
+		err = Run()
+	})
 
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "all returned IP addresses are different from each other")
 
 	logs := info.Get()
 
-	assert.Contains(t, logs, "127.0.0.1")
-	assert.Contains(t, logs, "169.254.1.1")
+	assert.Contains(t, logs, "123.123.123.123")
+	assert.Contains(t, logs, "111.111.111.111")
+
+	// Disagreement report
+	assert.Contains(t, out, "Providers did not agree on the global/public IP address (quorum: 2)")
+	assert.Regexp(t, `123\.123\.123\.123\s+-\s+-\s+http://dummy.com/`, out)
+	assert.Regexp(t, `111\.111\.111\.111\s+-\s+-\s+http://dummy.com/`, out)
+	assert.Contains(t, out, "Likely causes:\n  - split-tunnel VPN:")
+}
+
+func Test_printDisagreement(t *testing.T) {
+	t.Parallel()
+
+	result := &whereami.Result{
+		Quorum: 3,
+		Answers: []whereami.Answer{
+			{Provider: "https://a.example.com/", IP: net.ParseIP("123.123.123.123"), ASN: 2516, Owner: "KDDI CORPORATION"},
+			{Provider: "https://b.example.com/", IP: net.ParseIP("123.123.123.123")},
+			{Provider: "https://c.example.com/", Err: errors.New("forced error\nwith body")},
+		},
+	}
+
+	var buf bytes.Buffer
+
+	printDisagreement(&buf, &whereami.DisagreementError{Result: result})
+
+	out := buf.String()
+
+	assert.Regexp(t, `123\.123\.123\.123\s+AS2516\s+KDDI CORPORATION\s+https://a.example.com/, https://b.example.com/`, out)
+	assert.Regexp(t, `\(error\)\s+-\s+-\s+https://c.example.com/: forced error\n`, out)
+	assert.NotContains(t, out, "with body", "only the first line of the error should be printed")
+	assert.NotContains(t, out, "Likely causes")
 }
 
 // ============================================================================
@@ -249,19 +1009,79 @@ func TestRun_error_if_providers_returns_different_IPs(t *testing.T) {
 //
 //nolint:nonamedreturns // Allow named returns for readablity
 func backupAndRestore() (deferFunc func()) {
-	oldInfoLog := infoLog
 	oldOsArgs := os.Args
-	oldMaxNumUseDefault := maxNumUseDefault
-	oldListProvider := listProvider
+	oldGetProviders := getProviders
 	oldOsExt := util.OsExit
 	oldLog := info.Get()
+	oldIsVerbose := isVerbose
+	oldLogFormat := logFormat
+	oldLogFile := logFile
+	oldHealthFile := healthFile
+	oldOrderPolicy := orderPolicy
+	oldGetPathHealth := getPathHealth
+	oldRetryNum := retryNum
+	oldRetryDelay := retryDelay
+	oldRetryJitter := retryJitter
+	oldRetryOn := retryOn
+	oldIsProbe := isProbe
+	oldProbeURL := probeURL
+	oldProbeTLSURL := probeTLSURL
+	oldProbePins := probePins
+	oldIsJSON := isJSON
+	oldIsSecureOnly := isSecureOnly
+	oldIsSecureUpgrade := isSecureUpgrade
+	oldTLSMin := tlsMin
+	oldPins := pins
+	oldIsAllowPrivate := isAllowPrivate
+	oldIsGeo := isGeo
+	oldGeoDB := geoDB
+	oldIsRDNS := isRDNS
+	oldRDNSServer := rdnsServer
+	oldNetInterface := netInterface
+	oldSourceAddr := sourceAddr
+	oldProxyURL := proxyURL
+	oldListInterfaces := listInterfaces
+	oldNewDetector := newDetector
+
+	// Do not touch the health state file of the user during test
+	getPathHealth = func() (string, error) { return "", nil }
+	// Do not request the probes on the internet during test
+	probeURL = ""
+	probeTLSURL = ""
 
 	return func() {
-		infoLog = oldInfoLog
 		os.Args = oldOsArgs
-		listProvider = oldListProvider
-		maxNumUseDefault = oldMaxNumUseDefault
+		getProviders = oldGetProviders
 		util.OsExit = oldOsExt
+		isVerbose = oldIsVerbose
+		logFormat = oldLogFormat
+		logFile = oldLogFile
+		healthFile = oldHealthFile
+		orderPolicy = oldOrderPolicy
+		getPathHealth = oldGetPathHealth
+		retryNum = oldRetryNum
+		retryDelay = oldRetryDelay
+		retryJitter = oldRetryJitter
+		retryOn = oldRetryOn
+		isProbe = oldIsProbe
+		probeURL = oldProbeURL
+		probeTLSURL = oldProbeTLSURL
+		probePins = oldProbePins
+		isJSON = oldIsJSON
+		isSecureOnly = oldIsSecureOnly
+		isSecureUpgrade = oldIsSecureUpgrade
+		tlsMin = oldTLSMin
+		pins = oldPins
+		isAllowPrivate = oldIsAllowPrivate
+		isGeo = oldIsGeo
+		geoDB = oldGeoDB
+		isRDNS = oldIsRDNS
+		rdnsServer = oldRDNSServer
+		netInterface = oldNetInterface
+		sourceAddr = oldSourceAddr
+		proxyURL = oldProxyURL
+		listInterfaces = oldListInterfaces
+		newDetector = oldNewDetector
 
 		// Clear the current log and restore the old log
 		info.Clear()
@@ -272,6 +1092,68 @@ func backupAndRestore() (deferFunc func()) {
 	}
 }
 
+// Mocks the providers to request with the given ones. Use it with
+// backupAndRestore.
+func mockProviders(providers ...provider.Provider) {
+	getProviders = func() []provider.Provider {
+		return providers
+	}
+}
+
+// Starts a DNS resolver stand-in which answers the records of the name. It
+// responds SERVFAIL if records is nil, or NXDOMAIN if the name is not found.
+func startDummyResolver(t *testing.T, records map[string]string) string {
+	t.Helper()
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	srv := &dns.Server{
+		PacketConn:        packetConn,
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			resp := new(dns.Msg)
+			resp.SetReply(req)
+
+			name := req.Question[0].Name
+			data, ok := records[name]
+
+			switch {
+			case records == nil:
+				resp.Rcode = dns.RcodeServerFailure
+			case !ok:
+				resp.Rcode = dns.RcodeNameError
+			default:
+				record, err := dns.NewRR(name + " 300 IN " + data)
+				require.NoError(t, err)
+
+				if record.Header().Rrtype == req.Question[0].Qtype {
+					resp.Answer = append(resp.Answer, record)
+				}
+			}
+
+			if err := w.WriteMsg(resp); err != nil {
+				t.Log(err)
+			}
+		}),
+	}
+
+	go func() {
+		if err := srv.ActivateAndServe(); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	<-started
+
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+
+	return packetConn.LocalAddr().String()
+}
+
 // ----------------------------------------------------------------------------
 //  Type: DummyStruct
 // ----------------------------------------------------------------------------
@@ -294,3 +1176,15 @@ func (d DummyStruct) Name() string {
 
 // SetURL is an implementation of provider.Provider interface.
 func (d DummyStruct) SetURL(url string) {}
+
+// ----------------------------------------------------------------------------
+//  Type: roundTripperFunc
+// ----------------------------------------------------------------------------
+
+// roundTripperFunc is a function which implements http.RoundTripper.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip is an implementation of http.RoundTripper interface.
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
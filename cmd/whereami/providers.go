@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/health"
+	"github.com/pkg/errors"
+)
+
+// RunProviders is the function of the "providers" command.
+//
+// Currently, only the "status" sub command is available. It prints the health
+// of the providers recorded in the --health-file.
+func RunProviders(args []string) error {
+	flags := flag.NewFlagSet("providers", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of providers:\n")
+		fmt.Fprintf(flags.Output(), "  providers status\n")
+		fmt.Fprintf(flags.Output(), "        prints the health of the providers. Such as the state of the circuit breaker.\n")
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return newUsageError(errors.Wrap(err, "failed to parse providers options"))
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+
+		return newUsageError(errors.New("missing providers command: status"))
+	}
+
+	if flags.Arg(0) != "status" {
+		return newUsageError(errors.Errorf("unknown providers command: %v", flags.Arg(0)))
+	}
+
+	config, err := netConfig()
+	if err != nil {
+		return err
+	}
+
+	printHealth(os.Stdout, loadHealth(config.Source))
+
+	return nil
+}
+
+// Prints the health of the providers of getProviders as a table. The providers
+// which are not in the list but recorded (such as removed ones) are also
+// printed.
+func printHealth(out io.Writer, tracker *health.Tracker) {
+	const noData = "-"
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "PROVIDER\tSTATE\tSUCCESS\tFAILURE\tRATE\tLATENCY\tLAST ERROR")
+
+	listed := make(map[string]bool)
+	providers := getProviders()
+	listStats := make([]health.Stats, 0, len(providers))
+
+	for _, prov := range providers {
+		stats, _ := tracker.Get(prov.Name())
+
+		listed[prov.Name()] = true
+		listStats = append(listStats, stats)
+	}
+
+	for _, stats := range tracker.Status() {
+		if !listed[stats.Provider] {
+			listStats = append(listStats, stats)
+		}
+	}
+
+	for _, stats := range listStats {
+		rate, latency, lastErr := noData, noData, noData
+
+		if stats.SuccessRate() >= 0 {
+			rate = strconv.FormatFloat(stats.SuccessRate()*100, 'f', 1, 64) + "%"
+		}
+
+		if stats.Successes > 0 {
+			latency = stats.Latency.Round(time.Millisecond).String()
+		}
+
+		if stats.LastError != "" {
+			lastErr = stats.LastErrorAt.Format(time.RFC3339) + " " + stats.LastError
+		}
+
+		state := stats.State
+		if state == health.StateOpen {
+			state += " (until " + stats.OpenUntil.Format(time.RFC3339) + ")"
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			stats.Provider, state, stats.Successes, stats.Failures, rate, latency, lastErr)
+	}
+
+	writer.Flush()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KEINOS/go-utiles/util"
+	"github.com/KEINOS/whereami/pkg/health"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zenizh/go-capturer"
+)
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunProviders_status(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	pathFile := filepath.Join(t.TempDir(), "health.json")
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return nil, errors.New("forced error")
+		}},
+	)
+	os.Args = []string{t.Name(), "--health-file", pathFile}
+
+	// Mock os.Exit. This will be recovered by restoreFn.
+	util.OsExit = func(code int) {}
+
+	// Fail the detection to record the failures
+	for i := 0; i < health.ThresholdDefault; i++ {
+		capturer.CaptureOutput(func() {
+			main()
+		})
+	}
+
+	// Recorded but not in the list
+	tracker, err := health.Load(pathFile)
+	require.NoError(t, err)
+
+	tracker.Record("https://removed.example.com/", 0, nil)
+	require.NoError(t, tracker.Save())
+
+	os.Args = []string{t.Name(), "--health-file", pathFile, "providers", "status"}
+
+	out := capturer.CaptureStdout(func() {
+		main()
+	})
+
+	assert.Contains(t, out, "PROVIDER")
+	assert.Regexp(t, `http://dummy.com/\s+open \(until .+\)\s+0\s+3\s+0.0%\s+-\s+.+forced error`, out)
+	assert.Regexp(t, `https://removed.example.com/\s+closed\s+1\s+0\s+100.0%`, out)
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunProviders_no_data(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	mockProviders(&DummyStruct{ID: 0})
+	healthFile = filepath.Join(t.TempDir(), "health.json")
+
+	out := capturer.CaptureStdout(func() {
+		require.NoError(t, RunProviders([]string{"status"}))
+	})
+
+	assert.Regexp(t, `http://dummy.com/\s+closed\s+0\s+0\s+-\s+-\s+-`, out)
+}
+
+func TestRunProviders_bad_command(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		expect string
+		args   []string
+	}{
+		{args: []string{}, expect: "missing providers command: status"},
+		{args: []string{"unknown"}, expect: "unknown providers command: unknown"},
+		{args: []string{"--unknown"}, expect: "failed to parse providers options"},
+	} {
+		var err error
+
+		capturer.CaptureStderr(func() {
+			err = RunProviders(test.args)
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), test.expect)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/reflector"
+	"github.com/pkg/errors"
+)
+
+// Timeouts of the HTTP server of the "serve" command.
+const (
+	timeoutReadHeader = 5 * time.Second
+	timeoutShutdown   = 5 * time.Second
+)
+
+// notifyContext is a copy of signal.NotifyContext to ease mock its behavior
+// during test.
+var notifyContext = signal.NotifyContext
+
+// RunServe is the function of the "serve" command.
+//
+// It runs an HTTP server which responds the IP address of the caller, in the
+// same formats the providers consume. It stops on SIGINT or SIGTERM.
+func RunServe(args []string) error {
+	var addrListen, trustedProxies string
+
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.StringVar(&addrListen, "listen", ":8080", "address to listen on")
+	flags.StringVar(&trustedProxies, "trusted-proxies", "",
+		"comma separated IP addresses or CIDRs of the reverse proxies to trust Forwarded/X-Forwarded-For headers from")
+
+	if err := flags.Parse(args); err != nil {
+		return newUsageError(errors.Wrap(err, "failed to parse serve options"))
+	}
+
+	handler, err := reflector.New(strings.Split(trustedProxies, ",")...)
+	if err != nil {
+		return errors.Wrap(err, "failed to create handler")
+	}
+
+	listener, err := net.Listen("tcp", addrListen)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen")
+	}
+
+	ctx, stop := notifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: timeoutReadHeader,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		ctxShutdown, cancel := context.WithTimeout(context.Background(), timeoutShutdown)
+		defer cancel()
+
+		_ = srv.Shutdown(ctxShutdown)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening on %v\n", listener.Addr())
+
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "failed to serve")
+	}
+
+	return nil
+}
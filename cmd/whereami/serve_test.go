@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zenizh/go-capturer"
+)
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunServe_golden(t *testing.T) {
+	oldNotifyContext := notifyContext
+	defer func() {
+		notifyContext = oldNotifyContext
+	}()
+
+	// Mock signal.NotifyContext to stop the server from the test instead of signals
+	ctx, cancel := context.WithCancel(context.Background())
+	notifyContext = func(parent context.Context, signals ...os.Signal) (context.Context, context.CancelFunc) {
+		return ctx, cancel
+	}
+
+	addr := getFreeAddr(t)
+	chErr := make(chan error, 1)
+
+	out := capturer.CaptureStderr(func() {
+		go func() {
+			chErr <- RunServe([]string{"--listen", addr, "--trusted-proxies", "127.0.0.1"})
+		}()
+
+		body := getWithRetry(t, "http://"+addr+"/json", "203.0.113.1")
+
+		assert.Equal(t, `{"ip":"203.0.113.1"}`+"\n", body, "it should honor X-Forwarded-For from the trusted proxy")
+
+		cancel()
+
+		require.NoError(t, <-chErr, "it should stop gracefully")
+	})
+
+	assert.Contains(t, out, "Listening on "+addr)
+}
+
+func TestRunServe_invalid_trusted_proxy(t *testing.T) {
+	t.Parallel()
+
+	err := RunServe([]string{"--trusted-proxies", "foo"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid trusted proxy")
+}
+
+func TestRunServe_fail_to_listen(t *testing.T) {
+	t.Parallel()
+
+	err := RunServe([]string{"--listen", "256.0.0.1:80"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to listen")
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+// Returns a "host:port" of localhost which is not in use.
+func getFreeAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := listener.Addr().String()
+
+	require.NoError(t, listener.Close())
+
+	return addr
+}
+
+// Requests GET to the url with X-Forwarded-For header until the server is up.
+func getWithRetry(t *testing.T, url string, xForwardedFor string) string {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	req.Header.Set("X-Forwarded-For", xForwardedFor)
+
+	for i := 0; i < 50; i++ {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			time.Sleep(100 * time.Millisecond)
+
+			continue
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		return string(body)
+	}
+
+	t.Fatal("the server did not start")
+
+	return ""
+}
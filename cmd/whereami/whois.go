@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/ipaddr"
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/KEINOS/whereami/pkg/whois"
+	"github.com/pkg/errors"
+)
+
+// RunWhois is the function of the "whois" command.
+//
+// It looks up the owner and the network block of the given IP address, or the
+// current global/public IP address if omitted. RDAP is queried first and it
+// falls back to WHOIS.
+func RunWhois(args []string) error {
+	var bootstrapURLs, rdapServers string
+
+	client := whois.New()
+
+	flags := flag.NewFlagSet("whois", flag.ContinueOnError)
+	flags.StringVar(&bootstrapURLs, "bootstrap", strings.Join(client.BootstrapURLs, ","),
+		"comma separated URLs of the RDAP bootstrap registries. empty disables RDAP")
+	flags.StringVar(&rdapServers, "rdap", "",
+		"comma separated base URLs of the RDAP servers to query instead of the bootstrap. such as https://rdap.apnic.net/")
+	flags.StringVar(&client.WhoisServer, "server", client.WhoisServer,
+		"address of the WHOIS server to fall back. empty disables the fallback")
+	flags.DurationVar(&client.Timeout, "timeout", client.Timeout, "timeout of the whole lookup")
+
+	if err := flags.Parse(args); err != nil {
+		return newUsageError(errors.Wrap(err, "failed to parse whois options"))
+	}
+
+	if flags.NArg() > 1 {
+		return newUsageError(errors.Errorf("too many arguments: %v", strings.Join(flags.Args(), " ")))
+	}
+
+	client.BootstrapURLs = splitList(bootstrapURLs)
+	client.RDAPServers = splitList(rdapServers)
+
+	out := output{Status: statusOK, Proxy: proxyReport()}
+
+	ip, err := whoisTarget(flags.Arg(0), &out)
+	if err == nil {
+		out.IP = ip.String()
+		out.Owner, err = lookupOwner(client, ip)
+	}
+
+	if err != nil {
+		out.Status = statusOf(err)
+		out.Error = strings.SplitN(err.Error(), "\n", 2)[0]
+	}
+
+	if errPrint := printResult(out); errPrint != nil {
+		return errPrint
+	}
+
+	return err
+}
+
+// Returns the IP address of the argument, or the current global/public IP
+// address if empty. The result of the network probes is set to out.
+func whoisTarget(arg string, out *output) (net.IP, error) {
+	if arg != "" {
+		ip, err := ipaddr.Parse(arg)
+		if err != nil {
+			return nil, newUsageError(err)
+		}
+
+		return ip, nil
+	}
+
+	network, err := checkNetwork()
+	if network.Status != "" {
+		out.Network = &network
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, tracker, err := newResolver()
+	if err != nil {
+		return nil, err
+	}
+
+	ipAddress, err := resolveIP(resolver, tracker)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.ParseIP(ipAddress), nil
+}
+
+// Returns the owner of the ip via the proxy and/or from the source address of
+// the flags, if any.
+func lookupOwner(client *whois.Client, ip net.IP) (*whois.Info, error) {
+	config, err := netConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := info.NewContext(context.Background(), info.Default())
+
+	if config.Source != nil || config.Proxy != nil {
+		ctx = netutil.WithClient(ctx, config.Client())
+		client.Dial = config.DialContext
+	}
+
+	owner, err := client.LookupContext(ctx, ip)
+
+	return owner, errors.Wrap(err, "failed to look up the owner")
+}
+
+// Returns the owner in the table of the fields known.
+func formatOwner(owner *whois.Info) string {
+	var buf bytes.Buffer
+
+	table := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{name: "CIDR", value: strings.Join(owner.CIDRs, ", ")},
+		{name: "RANGE", value: owner.Range},
+		{name: "NAME", value: owner.Name},
+		{name: "ORG", value: owner.Org},
+		{name: "COUNTRY", value: owner.Country},
+		{name: "ABUSE", value: owner.Abuse},
+		{name: "REGISTRY", value: strings.TrimSpace(owner.Registry + " (" + owner.Source + ")")},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(table, "%v\t%v\n", field.name, field.value)
+		}
+	}
+
+	table.Flush()
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
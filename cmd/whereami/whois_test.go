@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/KEINOS/go-utiles/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zenizh/go-capturer"
+)
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunWhois_golden(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	srv := startDummyRDAP(t)
+
+	mockProviders(
+		&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+			return net.ParseIP("123.123.123.123"), nil
+		}},
+	)
+
+	// Detected IP address
+	os.Args = []string{t.Name(), "whois", "--rdap", srv.URL, "--server", ""}
+
+	out := capturer.CaptureStdout(func() {
+		main()
+	})
+
+	assert.Equal(t, "123.123.123.123\n"+
+		"CIDR      123.123.0.0/16\n"+
+		"NAME      EXAMPLE-NET\n"+
+		"ORG       Example Networks\n"+
+		"ABUSE     abuse@example.net\n"+
+		"REGISTRY  APNIC (rdap)", out)
+
+	// Given IP address
+	os.Args = []string{t.Name(), "--json", "whois", "--rdap", srv.URL, "--server", "", "123.123.1.1"}
+
+	out = capturer.CaptureStdout(func() {
+		main()
+	})
+
+	var result struct {
+		IP    string `json:"ip"`
+		Owner struct {
+			CIDRs []string `json:"cidrs"`
+			Abuse string   `json:"abuse"`
+		} `json:"owner"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(out), &result), "output should be a JSON object. got: %v", out)
+	assert.Equal(t, "123.123.1.1", result.IP)
+	assert.Equal(t, []string{"123.123.0.0/16"}, result.Owner.CIDRs)
+	assert.Equal(t, "abuse@example.net", result.Owner.Abuse)
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestRunWhois_error(t *testing.T) {
+	restoreFn := backupAndRestore()
+	defer restoreFn()
+
+	srv := startDummyRDAP(t)
+
+	for _, test := range []struct {
+		args       []string
+		expect     string
+		expectCode int
+	}{
+		{
+			args:       []string{"whois", "--rdap", srv.URL, "--server", "", "123.123.1.1", "extra"},
+			expect:     "too many arguments: 123.123.1.1 extra",
+			expectCode: ExitUsage,
+		},
+		{
+			args:       []string{"whois", "--rdap", srv.URL, "--server", "", "123.123.1"},
+			expect:     "invalid IP address",
+			expectCode: ExitUsage,
+		},
+		{
+			args:       []string{"--json", "whois", "--rdap", srv.URL, "--server", "", "8.8.8.8"},
+			expect:     `"ip":"8.8.8.8"`,
+			expectCode: ExitFailure,
+		},
+		{
+			args:       []string{"whois", "--bootstrap", "", "--server", ""},
+			expect:     "neither RDAP nor WHOIS server is set",
+			expectCode: ExitFailure,
+		},
+	} {
+		os.Args = append([]string{t.Name()}, test.args...)
+
+		mockProviders(
+			&DummyStruct{ID: 0, DummyFunc: func() (net.IP, error) {
+				return net.ParseIP("123.123.123.123"), nil
+			}},
+		)
+
+		capturedStatus := 0
+		util.OsExit = func(code int) {
+			capturedStatus = code
+		}
+
+		out := capturer.CaptureOutput(func() {
+			main()
+		})
+
+		assert.Equal(t, test.expectCode, capturedStatus, "args: %v", test.args)
+		assert.Contains(t, out, test.expect)
+	}
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+// Starts an RDAP server stand-in which knows 123.123.0.0/16 only.
+func startDummyRDAP(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ip := net.ParseIP(req.URL.Path[len("/ip/"):])
+
+		_, network, err := net.ParseCIDR("123.123.0.0/16")
+		require.NoError(t, err)
+
+		if !network.Contains(ip) {
+			http.NotFound(w, req)
+
+			return
+		}
+
+		fmt.Fprint(w, `{
+			"objectClassName": "ip network",
+			"name": "EXAMPLE-NET",
+			"port43": "whois.apnic.net",
+			"cidr0_cidrs": [{"v4prefix": "123.123.0.0", "length": 16}],
+			"entities": [
+				{"roles": ["registrant"], "vcardArray": ["vcard", [["fn", {}, "text", "Example Networks"]]]},
+				{"roles": ["abuse"], "vcardArray": ["vcard", [["email", {}, "text", "abuse@example.net"]]]}
+			]
+		}`)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
@@ -5,7 +5,10 @@ go 1.16
 require (
 	github.com/KEINOS/go-utiles v1.5.3
 	github.com/PuerkitoBio/goquery v1.8.0
+	github.com/miekg/dns v1.1.50
+	github.com/oschwald/maxminddb-golang v1.6.0
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.8.1
 	github.com/zenizh/go-capturer v0.0.0-20211219060012-52ea6c8fed04
+	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8
 )
@@ -0,0 +1,273 @@
+/*
+Package geotest provides a minimal writer of the MaxMind DB (MMDB) format to
+create the databases for the tests of the geo package and its users.
+
+It writes the IPv6 database with 32 bit records. The IPv4 networks are stored
+under ::/96 as the real databases do. See:
+  - https://maxmind.github.io/MaxMind-DB/
+*/
+package geotest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Record is the record of a network, or a map in the record.
+type Record map[string]interface{}
+
+// Types of the data section.
+const (
+	typeString = 2
+	typeDouble = 3
+	typeMap    = 7
+	typeUint16 = 5
+	typeUint32 = 6
+	typeUint64 = 9
+	typeArray  = 11
+)
+
+// Size of the separator between the search tree and the data section.
+const sizeSeparator = 16
+
+// Marker of the start of the metadata.
+var markerMetadata = []byte("\xab\xcd\xefMaxMind.com")
+
+// trieNode is a node of the search tree. A leaf has the offset of its record in
+// the data section.
+type trieNode struct {
+	children [2]*trieNode
+	offset   int
+	isLeaf   bool
+	number   int
+}
+
+// WriteFile writes the database of the records per CIDR to the path. Such as:
+//
+//	geotest.WriteFile(path, "GeoLite2-ASN", map[string]geotest.Record{
+//		"123.123.0.0/16": {
+//			"autonomous_system_number":       uint32(64500),
+//			"autonomous_system_organization": "Example Networks",
+//		},
+//	})
+//
+// The values of the records are string, float64, uint16, uint32, uint64,
+// []string or Record.
+func WriteFile(path string, dbType string, records map[string]Record) error {
+	var file bytes.Buffer
+
+	if err := Write(&file, dbType, records); err != nil {
+		return err
+	}
+
+	return errors.Wrap(os.WriteFile(path, file.Bytes(), 0o600), "failed to write the database")
+}
+
+// Write writes the database of the records per CIDR to the writer. See
+// WriteFile.
+func Write(writer io.Writer, dbType string, records map[string]Record) error {
+	root := &trieNode{}
+	data := new(bytes.Buffer)
+
+	// Sorted for the stable output
+	cidrs := make([]string, 0, len(records))
+	for cidr := range records {
+		cidrs = append(cidrs, cidr)
+	}
+
+	sort.Strings(cidrs)
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return errors.Wrap(err, "malformed network of the record")
+		}
+
+		ip := network.IP.To16()
+
+		// IPv4 under ::/96, not ::ffff:0:0/96
+		ones, bits := network.Mask.Size()
+		if bits == net.IPv4len*8 {
+			ones += 96
+			ip = append(make(net.IP, 12), network.IP.To4()...)
+		}
+
+		offset := data.Len()
+
+		if err := encode(data, records[cidr]); err != nil {
+			return err
+		}
+
+		insert(root, ip, ones, offset)
+	}
+
+	// Number the internal nodes in BFS order. The root is 0
+	nodes := []*trieNode{root}
+
+	for i := 0; i < len(nodes); i++ {
+		nodes[i].number = i
+
+		for _, child := range nodes[i].children {
+			if child != nil && !child.isLeaf {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+
+	nodeCount := len(nodes)
+	file := new(bytes.Buffer)
+
+	for _, node := range nodes {
+		for _, child := range node.children {
+			var value uint32
+
+			switch {
+			case child == nil:
+				value = uint32(nodeCount)
+			case child.isLeaf:
+				value = uint32(nodeCount + sizeSeparator + child.offset)
+			default:
+				value = uint32(child.number)
+			}
+
+			_ = binary.Write(file, binary.BigEndian, value) // never fails on bytes.Buffer
+		}
+	}
+
+	file.Write(make([]byte, sizeSeparator))
+	file.Write(data.Bytes())
+	file.Write(markerMetadata)
+
+	if err := encode(file, Record{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               dbType,
+		"description":                 Record{"en": "test database of " + dbType},
+		"ip_version":                  uint16(6),
+		"languages":                   []string{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(32),
+	}); err != nil {
+		return err
+	}
+
+	_, err := writer.Write(file.Bytes())
+
+	return errors.Wrap(err, "failed to write the database")
+}
+
+// Inserts the network of the prefix length to the tree.
+func insert(root *trieNode, ip net.IP, prefixLen int, offset int) {
+	node := root
+
+	for i := 0; i < prefixLen; i++ {
+		bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+
+		if node.children[bit] == nil {
+			node.children[bit] = &trieNode{}
+		}
+
+		node = node.children[bit]
+	}
+
+	node.isLeaf = true
+	node.offset = offset
+}
+
+// Encodes the value in the data section format.
+func encode(buf *bytes.Buffer, value interface{}) error {
+	switch val := value.(type) {
+	case string:
+		writeControl(buf, typeString, len(val))
+		buf.WriteString(val)
+	case float64:
+		writeControl(buf, typeDouble, 8)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(val))
+	case uint16:
+		writeUint(buf, typeUint16, uint64(val))
+	case uint32:
+		writeUint(buf, typeUint32, uint64(val))
+	case uint64:
+		writeUint(buf, typeUint64, val)
+	case []string:
+		writeControl(buf, typeArray, len(val))
+
+		for _, item := range val {
+			_ = encode(buf, item) // string never fails
+		}
+	case Record:
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+		writeControl(buf, typeMap, len(keys))
+
+		for _, key := range keys {
+			_ = encode(buf, key) // string never fails
+
+			if err := encode(buf, val[key]); err != nil {
+				return errors.Wrapf(err, "key %v", key)
+			}
+		}
+	default:
+		return errors.Errorf("unsupported type of the value: %T", value)
+	}
+
+	return nil
+}
+
+// Writes the control byte of the type and the size.
+func writeControl(buf *bytes.Buffer, typeData int, size int) {
+	const (
+		sizeOneByte  = 29
+		sizeTwoBytes = 29 + 256
+	)
+
+	var control byte
+	if typeData <= typeMap {
+		control = byte(typeData << 5)
+	}
+
+	var sizeBytes []byte
+
+	switch {
+	case size < sizeOneByte:
+		control |= byte(size)
+	case size < sizeTwoBytes:
+		control |= sizeOneByte
+		sizeBytes = []byte{byte(size - sizeOneByte)}
+	default:
+		control |= 30
+		sizeBytes = []byte{byte((size - sizeTwoBytes) >> 8), byte(size - sizeTwoBytes)}
+	}
+
+	buf.WriteByte(control)
+
+	if typeData > typeMap {
+		buf.WriteByte(byte(typeData - typeMap))
+	}
+
+	buf.Write(sizeBytes)
+}
+
+// Writes the unsigned integer in the minimum bytes.
+func writeUint(buf *bytes.Buffer, typeData int, value uint64) {
+	var payload []byte
+
+	for ; value > 0; value >>= 8 {
+		payload = append([]byte{byte(value)}, payload...)
+	}
+
+	writeControl(buf, typeData, len(payload))
+	buf.Write(payload)
+}
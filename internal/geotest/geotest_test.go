@@ -0,0 +1,58 @@
+package geotest_test
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/KEINOS/whereami/internal/geotest"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	err := geotest.Write(&buf, "Test-DB", map[string]geotest.Record{
+		"123.123.0.0/16": {"name": "v4", "list": []string{"a", "b"}, "num": uint64(1) << 40},
+		"2001:db8::/32":  {"name": "v6", "long": string(bytes.Repeat([]byte("x"), 300))},
+	})
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+
+	assert.Equal(t, "Test-DB", reader.Metadata.DatabaseType)
+	assert.NoError(t, reader.Verify())
+
+	for ip, expect := range map[string]string{"123.123.1.1": "v4", "2001:db8::1": "v6", "8.8.8.8": ""} {
+		var rec struct {
+			Name string `maxminddb:"name"`
+		}
+
+		require.NoError(t, reader.Lookup(net.ParseIP(ip), &rec))
+		assert.Equal(t, expect, rec.Name, "ip: %v", ip)
+	}
+}
+
+func TestWriteFile_error(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+
+	err := geotest.WriteFile(path, "Test-DB", map[string]geotest.Record{"123.123.0.0": {}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "malformed network")
+
+	err = geotest.WriteFile(path, "Test-DB", map[string]geotest.Record{"123.123.0.0/16": {"num": 1}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported type of the value: int")
+
+	err = geotest.WriteFile(filepath.Join(path, "missing", "test.mmdb"), "Test-DB", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to write the database")
+}
@@ -0,0 +1,337 @@
+/*
+Package bench benchmarks the providers by requesting them repeatedly. It
+reports the latency, error rate, bytes transferred and whether the answers of
+each provider match the majority.
+
+The data helps to decide which providers to trust in the quorum.
+*/
+package bench
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/provider/base"
+	"github.com/pkg/errors"
+)
+
+const (
+	// RoundsDefault is the default number of requests per provider.
+	RoundsDefault = 5
+	// IntervalDefault is the default interval between the requests to the same
+	// provider. To respect the rate limits of the providers.
+	IntervalDefault = time.Second
+	// TimeoutDefault is the default timeout of each request.
+	TimeoutDefault = 10 * time.Second
+	// RetryAfterMax is the max time to wait if the provider is rate limiting.
+	// If the provider tells to wait longer, the rest of the rounds are skipped.
+	RetryAfterMax = time.Minute
+)
+
+// ============================================================================
+//  Type: Bench
+// ============================================================================
+
+// Bench holds the settings of the benchmark.
+type Bench struct {
+	// Client is the HTTP client to request the providers. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+	// Providers are the providers to benchmark.
+	Providers []provider.Provider
+	// Rounds is the number of requests per provider.
+	Rounds int
+	// Interval is the time to wait between the requests to the same provider.
+	Interval time.Duration
+	// Timeout is the timeout of each request. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// New returns a new Bench of the given providers with the default settings.
+func New(providers ...provider.Provider) *Bench {
+	return &Bench{
+		Providers: providers,
+		Rounds:    RoundsDefault,
+		Interval:  IntervalDefault,
+		Timeout:   TimeoutDefault,
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Bench
+// ----------------------------------------------------------------------------
+
+// Run requests each provider Rounds times and returns the result.
+//
+// The providers are requested in parallel, but the requests to the same
+// provider are sent one by one with the Interval. If the provider is rate
+// limiting, the interval is extended to the time it told to retry.
+func (b *Bench) Run(ctx context.Context) (*Result, error) {
+	if len(b.Providers) == 0 {
+		return nil, errors.New("no provider to benchmark")
+	}
+
+	if b.Rounds < 1 {
+		return nil, errors.Errorf("number of rounds must be 1 or more. given: %v", b.Rounds)
+	}
+
+	reports := make([]*Report, len(b.Providers))
+	answers := make([][]net.IP, len(b.Providers))
+
+	var wg sync.WaitGroup
+
+	for index, prov := range b.Providers {
+		wg.Add(1)
+
+		go func(index int, prov provider.Provider) {
+			defer wg.Done()
+
+			reports[index], answers[index] = b.runProvider(ctx, prov)
+		}(index, prov)
+	}
+
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "benchmark interrupted")
+	}
+
+	result := &Result{Rounds: b.Rounds}
+	result.Majority = majority(answers)
+
+	for index, report := range reports {
+		for _, ipAddress := range answers[index] {
+			if result.Majority != nil && ipAddress.Equal(result.Majority) {
+				report.Matched++
+			}
+		}
+
+		report.MatchMajority = report.Matched > 0 && report.Matched == len(answers[index])
+
+		result.Reports = append(result.Reports, *report)
+	}
+
+	return result, nil
+}
+
+// Benchmarks a provider and returns the report and the IP addresses answered.
+func (b *Bench) runProvider(ctx context.Context, prov provider.Provider) (*Report, []net.IP) {
+	var (
+		bytesRead int64
+		latencies []time.Duration
+		answers   []net.IP
+	)
+
+	report := &Report{Provider: prov.Name()}
+
+	// Count the bytes of the responses per provider
+	client := http.Client{}
+	if b.Client != nil {
+		client = *b.Client
+	}
+
+	client.Transport = &countingTransport{base: client.Transport, count: &bytesRead}
+	ctx = netutil.WithClient(ctx, &client)
+
+	wait := b.Interval
+
+	for round := 0; round < b.Rounds; round++ {
+		if round > 0 && !netutil.Sleep(ctx, wait) {
+			break
+		}
+
+		ipAddress, latency, err := b.request(ctx, prov)
+
+		report.Requests++
+
+		if err == nil && ipAddress == nil {
+			err = errors.New("empty IP address")
+		}
+
+		if err != nil {
+			report.Errors++
+			report.LastError = err.Error()
+
+			// Respect the time to retry of the rate limit
+			retryAfter, ok := base.RetryAfterOf(err)
+			if ok && retryAfter > RetryAfterMax {
+				break // too long to wait
+			}
+
+			if ok && retryAfter > wait {
+				wait = retryAfter
+			}
+
+			continue
+		}
+
+		latencies = append(latencies, latency)
+		answers = append(answers, ipAddress)
+	}
+
+	report.Bytes = atomic.LoadInt64(&bytesRead)
+	report.ErrorRate = float64(report.Errors) / float64(report.Requests)
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		report.Min = latencies[0]
+		report.Median = percentile(latencies, 50)
+		report.P95 = percentile(latencies, 95)
+	}
+
+	return report, answers
+}
+
+// Requests the provider once and returns the IP address and the latency.
+func (b *Bench) request(ctx context.Context, prov provider.Provider) (net.IP, time.Duration, error) {
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+
+	timeStart := time.Now()
+	ipAddress, err := provider.GetIPContext(ctx, prov)
+
+	return ipAddress, time.Since(timeStart), err
+}
+
+// ============================================================================
+//  Type: Result
+// ============================================================================
+
+// Result is the outcome of the benchmark.
+type Result struct {
+	// Majority is the IP address answered the most. nil if no answer.
+	Majority net.IP `json:"majority"`
+	// Reports are the reports per provider in the order of Bench.Providers.
+	Reports []Report `json:"reports"`
+	// Rounds is the number of requests per provider.
+	Rounds int `json:"rounds"`
+}
+
+// ============================================================================
+//  Type: Report
+// ============================================================================
+
+// Report is the benchmark of a provider. The latencies are of the successful
+// requests only.
+type Report struct {
+	// Provider is the name of the provider.
+	Provider string `json:"provider"`
+	// LastError is the message of the last error. Empty if no error.
+	LastError string `json:"last_error,omitempty"`
+	// Requests is the number of requests sent.
+	Requests int `json:"requests"`
+	// Errors is the number of failed requests.
+	Errors int `json:"errors"`
+	// Matched is the number of answers which matched the majority.
+	Matched int `json:"matched"`
+	// ErrorRate is the ratio of the failed requests between 0 and 1.
+	ErrorRate float64 `json:"error_rate"`
+	// Bytes is the total bytes of the response bodies read.
+	Bytes int64 `json:"bytes"`
+	// Min is the minimum latency.
+	Min time.Duration `json:"min"`
+	// Median is the median latency.
+	Median time.Duration `json:"median"`
+	// P95 is the 95th percentile latency.
+	P95 time.Duration `json:"p95"`
+	// MatchMajority is true if all the answers matched the majority.
+	MatchMajority bool `json:"match_majority"`
+}
+
+// ============================================================================
+//  Type: countingTransport
+// ============================================================================
+
+// countingTransport is a http.RoundTripper which counts the bytes of the
+// response bodies read.
+type countingTransport struct {
+	base  http.RoundTripper
+	count *int64
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if resp != nil && resp.Body != nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, count: t.count}
+	}
+
+	return resp, err //nolint:wrapcheck // must be as is as a http.RoundTripper
+}
+
+// countingBody is a io.ReadCloser which counts the bytes read.
+type countingBody struct {
+	io.ReadCloser
+	count *int64
+}
+
+// Read implements io.Reader.
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	atomic.AddInt64(b.count, int64(n))
+
+	return n, err //nolint:wrapcheck // must be as is as a io.Reader
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Returns the IP address answered the most. On tie, the one which reached the
+// count first in the order of the providers.
+func majority(answers [][]net.IP) net.IP {
+	var (
+		result  net.IP
+		maxVote int
+	)
+
+	votes := make(map[string]int)
+
+	for _, list := range answers {
+		for _, ipAddress := range list {
+			key := ipAddress.String()
+			votes[key]++
+
+			if votes[key] > maxVote {
+				maxVote = votes[key]
+				result = ipAddress
+			}
+		}
+	}
+
+	return result
+}
+
+// Returns the p-th percentile of the sorted list by the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
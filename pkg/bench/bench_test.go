@@ -0,0 +1,179 @@
+package bench_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/bench"
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/provider/providers/ipifyorg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBench_Run(t *testing.T) {
+	t.Parallel()
+
+	const body = `{"ip": "123.123.123.123"}`
+
+	srvMajority := newDummyServer(t, http.StatusOK, body)
+	srvMinority := newDummyServer(t, http.StatusOK, `{"ip": "123.123.123.124"}`)
+	srvError := newDummyServer(t, http.StatusInternalServerError, "")
+
+	benchmark := bench.New(
+		newProvider(srvMajority.URL),
+		newProvider(srvMajority.URL+"/other"),
+		newProvider(srvMinority.URL),
+		newProvider(srvError.URL),
+	)
+	benchmark.Rounds = 3
+	benchmark.Interval = time.Millisecond
+
+	result, err := benchmark.Run(info.NewContext(context.Background(), nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, result.Rounds)
+	assert.Equal(t, "123.123.123.123", result.Majority.String())
+	require.Len(t, result.Reports, 4)
+
+	for _, report := range result.Reports[:2] {
+		assert.Equal(t, 3, report.Requests)
+		assert.Zero(t, report.Errors)
+		assert.Zero(t, report.ErrorRate)
+		assert.Equal(t, 3, report.Matched)
+		assert.True(t, report.MatchMajority)
+		assert.Equal(t, int64(3*len(body)), report.Bytes, "it should count the bytes of the responses")
+		assert.LessOrEqual(t, int64(report.Min), int64(report.Median))
+		assert.LessOrEqual(t, int64(report.Median), int64(report.P95))
+		assert.NotZero(t, report.Min)
+	}
+
+	minority := result.Reports[2]
+
+	assert.Zero(t, minority.Matched)
+	assert.False(t, minority.MatchMajority)
+
+	failed := result.Reports[3]
+
+	assert.Equal(t, 3, failed.Errors)
+	assert.Equal(t, float64(1), failed.ErrorRate)
+	assert.NotEmpty(t, failed.LastError)
+	assert.Zero(t, failed.Median, "latency should be of the successful requests only")
+	assert.False(t, failed.MatchMajority)
+}
+
+func TestBench_Run_interval(t *testing.T) {
+	t.Parallel()
+
+	srv := newDummyServer(t, http.StatusOK, `{"ip": "123.123.123.123"}`)
+
+	benchmark := bench.New(newProvider(srv.URL))
+	benchmark.Rounds = 3
+	benchmark.Interval = 50 * time.Millisecond
+
+	timeStart := time.Now()
+
+	_, err := benchmark.Run(info.NewContext(context.Background(), nil))
+	require.NoError(t, err)
+
+	assert.GreaterOrEqual(t, int64(time.Since(timeStart)), int64(100*time.Millisecond),
+		"it should wait the interval between the requests to the same provider")
+}
+
+func TestBench_Run_rate_limited(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		retryAfter string
+		requests   int
+	}{
+		{retryAfter: "1", requests: 2},    // waits a second then requests again
+		{retryAfter: "3600", requests: 1}, // too long to wait
+	} {
+		retryAfter := test.retryAfter
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer srv.Close()
+
+		benchmark := bench.New(newProvider(srv.URL))
+		benchmark.Rounds = 2
+		benchmark.Interval = time.Millisecond
+
+		timeStart := time.Now()
+
+		result, err := benchmark.Run(info.NewContext(context.Background(), nil))
+		require.NoError(t, err)
+
+		assert.Equal(t, test.requests, result.Reports[0].Requests, "Retry-After: %v", retryAfter)
+		assert.Contains(t, result.Reports[0].LastError, "Retry after:")
+
+		if test.requests == 2 {
+			assert.GreaterOrEqual(t, int64(time.Since(timeStart)), int64(time.Second),
+				"it should wait the time to retry instead of the interval")
+		}
+	}
+}
+
+func TestBench_Run_canceled(t *testing.T) {
+	t.Parallel()
+
+	srv := newDummyServer(t, http.StatusOK, `{"ip": "123.123.123.123"}`)
+
+	ctx, cancel := context.WithCancel(info.NewContext(context.Background(), nil))
+	cancel()
+
+	result, err := bench.New(newProvider(srv.URL)).Run(ctx)
+
+	require.Error(t, err)
+	require.Nil(t, result)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBench_Run_bad_settings(t *testing.T) {
+	t.Parallel()
+
+	_, err := bench.New().Run(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no provider to benchmark")
+
+	benchmark := bench.New(ipifyorg.New())
+	benchmark.Rounds = 0
+
+	_, err = benchmark.Run(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "number of rounds must be 1 or more")
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+func newDummyServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newProvider(url string) provider.Provider {
+	prov := ipifyorg.New()
+	prov.SetURL(url)
+
+	return prov
+}
@@ -0,0 +1,204 @@
+/*
+Package ddns updates the A/AAAA record of a DNS name via the dynamic update
+protocol (RFC 2136), authenticated with TSIG (RFC 8945).
+
+It is meant to be used with self-hosted authoritative DNS servers such as BIND
+or Knot, as an alternative to feeding the output of whereami to "nsupdate".
+*/
+package ddns
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Default port number of DNS.
+	portDefault = "53"
+	// Default TTL in seconds of the record to be added.
+	ttlDefault = 300
+	// Time difference allowed in seconds between the server and the client.
+	fudgeDefault = 300
+	// Default timeout to wait for the response of the server.
+	timeoutDefault = 5 * time.Second
+)
+
+// TimeNow is a copy of time.Now to ease mock its behavior during test.
+var TimeNow = time.Now
+
+// ============================================================================
+//  Type: Updater
+// ============================================================================
+
+// Updater holds information to send dynamic updates to an authoritative DNS
+// server.
+type Updater struct {
+	// Server is the address of the primary DNS server of the zone. If the port
+	// number is omitted, 53 is used.
+	Server string
+	// Zone is the name of the zone to update. Such as "example.com".
+	Zone string
+	// Name is the name of the record to replace. Such as "home.example.com".
+	Name string
+	// KeyName is the name of the TSIG key. If empty, the update is sent unsigned.
+	KeyName string
+	// Secret is the base64 encoded TSIG secret of the KeyName.
+	Secret string
+	// Algorithm is the TSIG algorithm. Such as "hmac-sha256" (default).
+	Algorithm string
+	// Network is the protocol to use. Either "udp" (default) or "tcp".
+	Network string
+	// TTL is the time to live in seconds of the new record.
+	TTL uint32
+	// Timeout is the time to wait for the response of the server.
+	Timeout time.Duration
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// New returns a new Updater for the given server, zone and record name with
+// default values.
+func New(server, zone, name string) *Updater {
+	return &Updater{
+		Server:    server,
+		Zone:      zone,
+		Name:      name,
+		Algorithm: dns.HmacSHA256,
+		Network:   "udp",
+		TTL:       ttlDefault,
+		Timeout:   timeoutDefault,
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Updater
+// ----------------------------------------------------------------------------
+
+// NewMessage returns the UPDATE message that replaces the A record (or AAAA if
+// the given ip is an IPv6 address) of the Name with the ip.
+//
+// Note that the message is not signed yet. It will be signed on Update.
+func (u *Updater) NewMessage(ip net.IP) (*dns.Msg, error) {
+	if err := u.Validate(); err != nil {
+		return nil, err
+	}
+
+	var record dns.RR
+
+	header := dns.RR_Header{
+		Name:  dns.Fqdn(u.Name),
+		Class: dns.ClassINET,
+		Ttl:   u.TTL,
+	}
+
+	switch {
+	case ip.To4() != nil:
+		header.Rrtype = dns.TypeA
+		record = &dns.A{Hdr: header, A: ip.To4()}
+	case ip.To16() != nil:
+		header.Rrtype = dns.TypeAAAA
+		record = &dns.AAAA{Hdr: header, AAAA: ip.To16()}
+	default:
+		return nil, errors.Errorf("invalid IP address to update: %v", ip)
+	}
+
+	msg := new(dns.Msg)
+
+	msg.SetUpdate(dns.Fqdn(u.Zone))
+	// Delete the existing RRset of the same type then add the new one.
+	msg.RemoveRRset([]dns.RR{record})
+	msg.Insert([]dns.RR{record})
+
+	return msg, nil
+}
+
+// Update replaces the A record (or AAAA if the given ip is an IPv6 address) of
+// the Name with the given ip.
+func (u *Updater) Update(ip net.IP) error {
+	return u.UpdateContext(context.Background(), ip)
+}
+
+// UpdateContext is the same as Update but with context.
+func (u *Updater) UpdateContext(ctx context.Context, ip net.IP) error {
+	msg, err := u.NewMessage(ip)
+	if err != nil {
+		return errors.Wrap(err, "failed to create update message")
+	}
+
+	client := &dns.Client{
+		Net:     u.Network,
+		Timeout: u.Timeout,
+	}
+
+	isSigned := u.KeyName != ""
+	if isSigned {
+		keyName := dns.CanonicalName(u.KeyName)
+
+		client.TsigSecret = map[string]string{keyName: u.Secret}
+
+		msg.SetTsig(keyName, dns.CanonicalName(u.Algorithm), fudgeDefault, TimeNow().Unix())
+	}
+
+	response, _, err := client.ExchangeContext(ctx, msg, u.address())
+	if err != nil {
+		return errors.Wrapf(err, "failed to send update to %v", u.address())
+	}
+
+	if response.Rcode != dns.RcodeSuccess {
+		reason := dns.RcodeToString[response.Rcode]
+
+		// TSIG errors such as BADSIG and BADKEY are in the TSIG record
+		if tsig := response.IsTsig(); tsig != nil && tsig.Error != dns.RcodeSuccess {
+			reason += " (" + dns.RcodeToString[int(tsig.Error)] + ")"
+		}
+
+		return errors.Errorf("server %v refused to update %v: %v", u.address(), u.Name, reason)
+	}
+
+	if isSigned && response.IsTsig() == nil {
+		return errors.Errorf("response from %v is not signed", u.address())
+	}
+
+	return nil
+}
+
+// Returns the server address with the port number.
+func (u *Updater) address() string {
+	if _, _, err := net.SplitHostPort(u.Server); err == nil {
+		return u.Server
+	}
+
+	return net.JoinHostPort(strings.Trim(u.Server, "[]"), portDefault)
+}
+
+// Validate returns an error if the required fields are missing or malformed.
+// It is also checked on Update, but can be used to check the settings before
+// detecting the IP address.
+func (u *Updater) Validate() error {
+	switch {
+	case u.Server == "":
+		return errors.New("DNS server is not set")
+	case u.Zone == "":
+		return errors.New("zone is not set")
+	case u.Name == "":
+		return errors.New("name of the record is not set")
+	case !dns.IsSubDomain(dns.Fqdn(u.Zone), dns.Fqdn(u.Name)):
+		return errors.Errorf("name %v is not in the zone %v", u.Name, u.Zone)
+	}
+
+	if u.KeyName != "" {
+		if _, err := base64.StdEncoding.DecodeString(u.Secret); err != nil || u.Secret == "" {
+			return errors.New("TSIG secret is empty or not base64 encoded")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package ddns_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/ddns"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	dummyKeyName = "whereami-key."
+	dummySecret  = "c2VjcmV0LWtleS1mb3ItdGVzdGluZy1wdXJwb3Nl" // "secret-key-for-testing-purpose"
+)
+
+func TestUpdate_golden_ipv4(t *testing.T) {
+	t.Parallel()
+
+	srv := newDummyServer(t)
+
+	updater := ddns.New(srv.Addr, "example.com", "home.example.com")
+	updater.KeyName = dummyKeyName
+	updater.Secret = dummySecret
+
+	err := updater.Update(net.ParseIP("123.123.123.123"))
+	require.NoError(t, err)
+
+	received := srv.LastMessage()
+	require.NotNil(t, received, "the server should receive the message")
+
+	assert.Equal(t, dns.OpcodeUpdate, received.Opcode, "it should be an UPDATE message")
+	assert.Equal(t, "example.com.", received.Question[0].Name, "zone section should be the zone")
+	require.Len(t, received.Ns, 2, "update section should have the deletion and the addition")
+
+	// RFC 2136 2.5.2: Delete An RRset
+	deletion := received.Ns[0].Header()
+	assert.Equal(t, "home.example.com.", deletion.Name)
+	assert.Equal(t, dns.TypeA, deletion.Rrtype)
+	assert.Equal(t, uint16(dns.ClassANY), deletion.Class)
+
+	// RFC 2136 2.5.1: Add To An RRset
+	addition, ok := received.Ns[1].(*dns.A)
+	require.True(t, ok, "the added record should be an A record")
+	assert.Equal(t, "123.123.123.123", addition.A.String())
+	assert.Equal(t, uint32(300), addition.Hdr.Ttl, "default TTL should be 300")
+}
+
+func TestUpdate_golden_ipv6(t *testing.T) {
+	t.Parallel()
+
+	srv := newDummyServer(t)
+
+	updater := ddns.New(srv.Addr, "example.com.", "home.example.com.")
+	updater.KeyName = dummyKeyName
+	updater.Secret = dummySecret
+	updater.TTL = 60
+
+	err := updater.Update(net.ParseIP("2001:db8::1"))
+	require.NoError(t, err)
+
+	received := srv.LastMessage()
+	require.NotNil(t, received, "the server should receive the message")
+	require.Len(t, received.Ns, 2)
+
+	addition, ok := received.Ns[1].(*dns.AAAA)
+	require.True(t, ok, "the added record should be an AAAA record")
+	assert.Equal(t, "2001:db8::1", addition.AAAA.String())
+	assert.Equal(t, uint32(60), addition.Hdr.Ttl)
+}
+
+func TestUpdate_wrong_secret(t *testing.T) {
+	t.Parallel()
+
+	srv := newDummyServer(t)
+
+	updater := ddns.New(srv.Addr, "example.com", "home.example.com")
+	updater.KeyName = dummyKeyName
+	updater.Secret = "d3Jvbmctc2VjcmV0" // "wrong-secret"
+
+	err := updater.Update(net.ParseIP("123.123.123.123"))
+
+	require.Error(t, err, "it should fail if the server can not verify the signature")
+	assert.Contains(t, err.Error(), "refused to update")
+	assert.Contains(t, err.Error(), "NOTAUTH")
+}
+
+func TestUpdate_unsigned_refused(t *testing.T) {
+	t.Parallel()
+
+	srv := newDummyServer(t)
+
+	updater := ddns.New(srv.Addr, "example.com", "home.example.com")
+
+	err := updater.Update(net.ParseIP("123.123.123.123"))
+
+	require.Error(t, err, "it should fail if the server requires TSIG")
+	assert.Contains(t, err.Error(), "REFUSED")
+}
+
+func TestUpdate_server_unreachable(t *testing.T) {
+	t.Parallel()
+
+	updater := ddns.New("127.0.0.1:1", "example.com", "home.example.com")
+	updater.Network = "tcp"
+
+	err := updater.Update(net.ParseIP("123.123.123.123"))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to send update to 127.0.0.1:1")
+}
+
+func TestNewMessage_invalid_settings(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		updater *ddns.Updater
+		ip      net.IP
+		expect  string
+	}{
+		{ddns.New("", "example.com", "home.example.com"), net.IPv4(1, 1, 1, 1), "DNS server is not set"},
+		{ddns.New("localhost", "", "home.example.com"), net.IPv4(1, 1, 1, 1), "zone is not set"},
+		{ddns.New("localhost", "example.com", ""), net.IPv4(1, 1, 1, 1), "name of the record is not set"},
+		{ddns.New("localhost", "example.com", "home.example.net"), net.IPv4(1, 1, 1, 1), "is not in the zone"},
+		{ddns.New("localhost", "example.com", "home.example.com"), nil, "invalid IP address"},
+		{
+			&ddns.Updater{Server: "localhost", Zone: "example.com", Name: "home.example.com", KeyName: "key", Secret: "@@@"},
+			net.IPv4(1, 1, 1, 1),
+			"TSIG secret is empty or not base64 encoded",
+		},
+	} {
+		msg, err := test.updater.NewMessage(test.ip)
+
+		require.Error(t, err)
+		require.Nil(t, msg, "returned message should be nil on error")
+		assert.Contains(t, err.Error(), test.expect)
+	}
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+// dummyServer is an authoritative DNS server stand-in which accepts updates
+// signed with dummyKeyName and dummySecret only.
+type dummyServer struct {
+	Addr string
+
+	mu      sync.Mutex
+	lastMsg *dns.Msg
+}
+
+// LastMessage returns the last update message accepted by the server.
+func (d *dummyServer) LastMessage() *dns.Msg {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.lastMsg
+}
+
+func newDummyServer(t *testing.T) *dummyServer {
+	t.Helper()
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	dummy := &dummyServer{Addr: packetConn.LocalAddr().String()}
+	started := make(chan struct{})
+
+	srv := &dns.Server{
+		PacketConn:        packetConn,
+		TsigSecret:        map[string]string{dummyKeyName: dummySecret},
+		NotifyStartedFunc: func() { close(started) },
+		// The default accept function rejects UPDATE messages as NOTIMP
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			resp := new(dns.Msg)
+			resp.SetReply(req)
+
+			switch {
+			case req.IsTsig() == nil:
+				resp.Rcode = dns.RcodeRefused
+			case w.TsigStatus() != nil:
+				resp.Rcode = dns.RcodeNotAuth
+			default:
+				dummy.mu.Lock()
+				dummy.lastMsg = req
+				dummy.mu.Unlock()
+			}
+
+			if tsig := req.IsTsig(); tsig != nil && w.TsigStatus() == nil {
+				resp.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+			}
+
+			if err := w.WriteMsg(resp); err != nil {
+				t.Log(err)
+			}
+		}),
+	}
+
+	go func() {
+		if err := srv.ActivateAndServe(); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	<-started
+
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+
+	return dummy
+}
@@ -0,0 +1,554 @@
+/*
+Package doctor diagnoses the connectivity problems to the providers step by
+step. Such as DNS resolution, TCP connect, TLS handshake, HTTP status and
+response parse of each provider, along with the proxy environment variables,
+IPv4/IPv6 reachability and clock skew.
+
+The result is meant to be pasted back to the helpdesk as is.
+*/
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/pkg/errors"
+)
+
+// Stages of the checks.
+const (
+	StageProxy = "proxy"
+	StageIPv4  = "ipv4"
+	StageIPv6  = "ipv6"
+	StageClock = "clock"
+	StageDNS   = "dns"
+	StageTCP   = "tcp"
+	StageTLS   = "tls"
+	StageHTTP  = "http"
+	StageParse = "parse"
+)
+
+// Statuses of the checks.
+const (
+	StatusOK   = "OK"
+	StatusWarn = "WARN"
+	StatusFail = "FAIL"
+	StatusSkip = "SKIP"
+)
+
+const (
+	// TimeoutDefault is the default timeout of each stage.
+	TimeoutDefault = 10 * time.Second
+	// ClockSkewMax is the clock skew to warn. TLS and TSIG are sensitive to it.
+	ClockSkewMax = 5 * time.Minute
+	// Addresses to check the reachability. No packet is sent since the UDP
+	// "connection" only looks up the route.
+	addrReachIPv4 = "8.8.8.8:53"
+	addrReachIPv6 = "[2001:4860:4860::8888]:53"
+)
+
+// Names of the proxy environment variables to check.
+var envProxy = []string{
+	"HTTP_PROXY", "http_proxy",
+	"HTTPS_PROXY", "https_proxy",
+	"ALL_PROXY", "all_proxy",
+	"NO_PROXY", "no_proxy",
+}
+
+// ============================================================================
+//  Type: Doctor
+// ============================================================================
+
+// Doctor holds the settings of the diagnosis. The fields for the network
+// operations are replaceable for testing or for the custom network settings.
+type Doctor struct {
+	// Client is the HTTP client used in the "http" and "parse" stages. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+	// TLSConfig is the base config of the "tls" stage. If nil, the default
+	// config is used.
+	TLSConfig *tls.Config
+	// LookupHost resolves the host name in the "dns" stage.
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+	// DialContext connects to the address in the "tcp", "ipv4" and "ipv6" stages.
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+	// LookupEnv returns the environment variable in the "proxy" stage.
+	LookupEnv func(key string) (string, bool)
+	// Proxy is the URL of the proxy which Client and DialContext connect via,
+	// without the password. It is reported in the "proxy" stage, and the
+	// "ipv4" and "ipv6" stages are skipped since the route is of the proxy. If
+	// empty, they connect directly.
+	Proxy string
+	// Providers are the providers to diagnose.
+	Providers []provider.Provider
+	// Timeout is the timeout of each stage.
+	Timeout time.Duration
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// New returns a new Doctor of the given providers with the default settings.
+func New(providers ...provider.Provider) *Doctor {
+	return &Doctor{
+		Providers:   providers,
+		LookupHost:  net.DefaultResolver.LookupHost,
+		DialContext: (&net.Dialer{}).DialContext,
+		LookupEnv:   os.LookupEnv,
+		Timeout:     TimeoutDefault,
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Doctor
+// ----------------------------------------------------------------------------
+
+// Run runs all the checks and returns the results in the order of the stages.
+// The stages of a provider after the failed one are skipped.
+func (d *Doctor) Run(ctx context.Context) []Check {
+	checks := []Check{
+		d.checkProxy(),
+		d.checkReach(ctx, StageIPv4, "udp4", addrReachIPv4),
+		d.checkReach(ctx, StageIPv6, "udp6", addrReachIPv6),
+	}
+
+	var skews []time.Duration
+
+	for _, prov := range d.Providers {
+		provChecks, skew, ok := d.checkProvider(ctx, prov)
+
+		checks = append(checks, provChecks...)
+
+		if ok {
+			skews = append(skews, skew)
+		}
+	}
+
+	return append(checks, checkClock(skews))
+}
+
+// Runs the stages of a provider. It also returns the clock skew from the Date
+// header of the response if available.
+func (d *Doctor) checkProvider(ctx context.Context, prov provider.Provider) ([]Check, time.Duration, bool) {
+	name := prov.Name()
+	stages := []string{StageDNS, StageTCP, StageTLS, StageHTTP, StageParse}
+	checks := make([]Check, 0, len(stages))
+
+	// Skips the stages after the failed one
+	skip := func(from int) []Check {
+		for _, stage := range stages[from:] {
+			checks = append(checks, Check{Stage: stage, Target: name, Status: StatusSkip, Detail: "previous stage failed"})
+		}
+
+		return checks
+	}
+
+	endpoint, err := url.Parse(name)
+	if err != nil || endpoint.Hostname() == "" {
+		checks = append(checks, Check{
+			Stage: StageDNS, Target: name, Status: StatusFail,
+			Detail: "the name of the provider is not a URL",
+		})
+
+		return skip(1), 0, false
+	}
+
+	host := endpoint.Hostname()
+	port := endpoint.Port()
+
+	if port == "" {
+		port = "80"
+		if endpoint.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	// DNS
+	check, addrs := d.checkDNS(ctx, name, host)
+	if checks = append(checks, check); check.Status == StatusFail {
+		return skip(1), 0, false
+	}
+
+	// TCP
+	check, conn := d.checkTCP(ctx, name, net.JoinHostPort(addrs[0], port))
+	if checks = append(checks, check); check.Status == StatusFail {
+		return skip(2), 0, false
+	}
+
+	// TLS
+	if endpoint.Scheme == "https" {
+		check = d.checkTLS(ctx, name, host, conn)
+	} else {
+		check = Check{Stage: StageTLS, Target: name, Status: StatusSkip, Detail: "not HTTPS"}
+	}
+
+	conn.Close()
+
+	if checks = append(checks, check); check.Status == StatusFail {
+		return skip(3), 0, false
+	}
+
+	// HTTP
+	check, skew, hasDate := d.checkHTTP(ctx, name)
+	if checks = append(checks, check); check.Status == StatusFail {
+		return skip(4), skew, hasDate // error responses also have the Date header
+	}
+
+	// Parse
+	checks = append(checks, d.checkParse(ctx, prov))
+
+	return checks, skew, hasDate
+}
+
+// Resolves the host and returns the addresses.
+func (d *Doctor) checkDNS(ctx context.Context, name, host string) (Check, []string) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	timeStart := time.Now()
+	addrs, err := d.LookupHost(ctx, host)
+	check := Check{Stage: StageDNS, Target: name, Duration: time.Since(timeStart)}
+
+	switch {
+	case err != nil:
+		check.Status, check.Detail = StatusFail, "failed to resolve "+host+": "+err.Error()
+	case len(addrs) == 0:
+		check.Status, check.Detail = StatusFail, "no address found for "+host
+	default:
+		check.Status, check.Detail = StatusOK, host+" -> "+strings.Join(addrs, ", ")
+	}
+
+	return check, addrs
+}
+
+// Connects to the address and returns the connection on success.
+func (d *Doctor) checkTCP(ctx context.Context, name, address string) (Check, net.Conn) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	timeStart := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", address)
+	check := Check{Stage: StageTCP, Target: name, Duration: time.Since(timeStart)}
+
+	if err != nil {
+		check.Status, check.Detail = StatusFail, "failed to connect to "+address+": "+err.Error()
+
+		return check, nil
+	}
+
+	check.Status, check.Detail = StatusOK, "connected to "+address
+
+	return check, conn
+}
+
+// Handshakes TLS over the conn and reports the version and the certificate.
+func (d *Doctor) checkTLS(ctx context.Context, name, host string, conn net.Conn) Check {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if d.TLSConfig != nil {
+		config = d.TLSConfig.Clone()
+	}
+
+	config.ServerName = host
+
+	// Handshake with the deadline since HandshakeContext is not available in Go 1.16
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	tlsConn := tls.Client(conn, config)
+	timeStart := time.Now()
+	err := tlsConn.Handshake()
+	check := Check{Stage: StageTLS, Target: name, Duration: time.Since(timeStart)}
+
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = "handshake failed: " + err.Error() +
+			" (a proxy or security software may intercept the TLS connection)"
+
+		return check
+	}
+
+	state := tlsConn.ConnectionState()
+	cert := state.PeerCertificates[0]
+
+	check.Status = StatusOK
+	check.Detail = fmt.Sprintf("%v, issuer %q, expires %v",
+		tlsVersionName(state.Version), cert.Issuer.String(), cert.NotAfter.Format("2006-01-02"))
+
+	return check
+}
+
+// Requests the provider and checks the status. It also returns the clock skew
+// from the Date header of the response if any.
+func (d *Doctor) checkHTTP(ctx context.Context, name string) (Check, time.Duration, bool) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	timeStart := time.Now()
+	resp, err := netutil.HTTPGetContext(netutil.WithClient(ctx, d.Client), name)
+	check := Check{Stage: StageHTTP, Target: name, Duration: time.Since(timeStart)}
+
+	if err != nil {
+		check.Status, check.Detail = StatusFail, err.Error()
+
+		return check, 0, false
+	}
+
+	defer resp.Body.Close()
+
+	check.Status, check.Detail = StatusOK, resp.Status
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		check.Status = StatusFail
+	}
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return check, 0, false
+	}
+
+	// The Date header is truncated to seconds
+	return check, time.Since(date).Round(time.Second), true
+}
+
+// Requests the provider via its GetIP method to check the response parse.
+func (d *Doctor) checkParse(ctx context.Context, prov provider.Provider) Check {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	timeStart := time.Now()
+	ipAddress, err := provider.GetIPContext(netutil.WithClient(ctx, d.Client), prov)
+	check := Check{Stage: StageParse, Target: prov.Name(), Duration: time.Since(timeStart)}
+
+	switch {
+	case err != nil:
+		check.Status, check.Detail = StatusFail, err.Error()
+	case ipAddress == nil:
+		check.Status, check.Detail = StatusFail, "no IP address in the response"
+	default:
+		check.Status, check.Detail = StatusOK, "IP address: "+ipAddress.String()
+	}
+
+	return check
+}
+
+// Reports the proxy environment variables set. The credentials are redacted.
+func (d *Doctor) checkProxy() Check {
+	check := Check{Stage: StageProxy, Status: StatusOK}
+
+	var found []string
+
+	for _, key := range envProxy {
+		value, ok := d.LookupEnv(key)
+		if !ok || value == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(key, "NO_") && !strings.HasPrefix(key, "no_") {
+			proxyURL, err := url.Parse(value)
+			if err != nil || proxyURL.Host == "" {
+				check.Status = StatusWarn
+				found = append(found, key+" is not a valid URL")
+
+				continue
+			}
+
+			value = proxyURL.Redacted()
+		}
+
+		found = append(found, key+"="+value)
+	}
+
+	if d.Proxy != "" {
+		check.Detail = "connecting via " + d.Proxy + " (the dns stage does not use the proxy)"
+
+		if len(found) > 0 {
+			sort.Strings(found)
+
+			check.Detail += ", ignoring " + strings.Join(found, ", ")
+		}
+
+		return check
+	}
+
+	if len(found) == 0 {
+		check.Detail = "no proxy environment variable is set"
+
+		return check
+	}
+
+	sort.Strings(found)
+
+	check.Detail = strings.Join(found, ", ") + " (the dns/tcp/tls stages do not use the proxy)"
+
+	return check
+}
+
+// Checks if the route to the address of the network (udp4 or udp6) exists.
+func (d *Doctor) checkReach(ctx context.Context, stage, network, address string) Check {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	check := Check{Stage: stage}
+
+	if d.Proxy != "" {
+		check.Status, check.Detail = StatusSkip, "connecting via the proxy"
+
+		return check
+	}
+
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		// Single stack network is common. So it is not a failure.
+		check.Status, check.Detail = StatusWarn, "no route to the internet: "+err.Error()
+
+		return check
+	}
+
+	defer conn.Close()
+
+	check.Status, check.Detail = StatusOK, "route available via "+conn.LocalAddr().String()
+
+	return check
+}
+
+// Returns the ctx with the Timeout if set.
+func (d *Doctor) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, d.Timeout)
+}
+
+// ============================================================================
+//  Type: Check
+// ============================================================================
+
+// Check is the result of a stage.
+type Check struct {
+	// Stage is the name of the stage. Such as StageDNS.
+	Stage string `json:"stage"`
+	// Target is the provider of the stage. Empty for the global stages.
+	Target string `json:"target,omitempty"`
+	// Status is one of StatusOK, StatusWarn, StatusFail or StatusSkip.
+	Status string `json:"status"`
+	// Detail is the human readable detail of the result.
+	Detail string `json:"detail"`
+	// Duration is the time took for the stage.
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// String returns the check as a line. Such as:
+//
+//	[ OK ] dns    https://ipinfo.io/  ipinfo.io -> 34.117.59.81 (12ms)
+func (c Check) String() string {
+	line := fmt.Sprintf("[%-4v] %-5v", c.Status, c.Stage)
+
+	if c.Target != "" {
+		line += " " + c.Target
+	}
+
+	line += "  " + c.Detail
+
+	if c.Duration > 0 {
+		line += " (" + c.Duration.Round(time.Millisecond).String() + ")"
+	}
+
+	return line
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Verdict returns the summary of the checks and an error if any check failed.
+func Verdict(checks []Check) (string, error) {
+	var numFail, numWarn int
+
+	for _, check := range checks {
+		switch check.Status {
+		case StatusFail:
+			numFail++
+		case StatusWarn:
+			numWarn++
+		}
+	}
+
+	if numFail > 0 {
+		return fmt.Sprintf("%v check(s) failed and %v warning(s). See the FAIL lines above.", numFail, numWarn),
+			errors.Errorf("%v check(s) failed", numFail)
+	}
+
+	if numWarn > 0 {
+		return fmt.Sprintf("All checks passed with %v warning(s).", numWarn), nil
+	}
+
+	return "All checks passed.", nil
+}
+
+// Returns the check of the clock skew from the skews of the providers. The skew
+// is the local time minus the time of the provider. The median is used to
+// ignore the providers with the wrong clock.
+func checkClock(skews []time.Duration) Check {
+	check := Check{Stage: StageClock}
+
+	if len(skews) == 0 {
+		check.Status, check.Detail = StatusSkip, "no Date header received from the providers"
+
+		return check
+	}
+
+	sort.Slice(skews, func(i, j int) bool { return skews[i] < skews[j] })
+
+	skew := skews[len(skews)/2]
+	abs := skew
+
+	if abs < 0 {
+		abs = -abs
+	}
+
+	check.Status = StatusOK
+	check.Detail = fmt.Sprintf("local clock is %v ahead of the providers", abs)
+
+	if skew < 0 {
+		check.Detail = fmt.Sprintf("local clock is %v behind the providers", abs)
+	}
+
+	if abs > ClockSkewMax {
+		check.Status = StatusWarn
+		check.Detail += ". adjust the system clock (NTP)"
+	}
+
+	return check
+}
+
+// Returns the name of the TLS version.
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+
+	return fmt.Sprintf("TLS(0x%04x)", version)
+}
@@ -0,0 +1,311 @@
+/*
+Package geo annotates the IP address with its location and ASN from the local
+MaxMind DB (MMDB) files. Such as GeoLite2-City, GeoLite2-ASN or the compatible
+ones like DB-IP Lite. No network access is needed, so it works on the
+air-gapped machines as well.
+
+	reader, err := geo.Open("GeoLite2-City.mmdb", "GeoLite2-ASN.mmdb")
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	info, err := reader.Lookup(ip)
+
+See: https://maxmind.github.io/MaxMind-DB/
+*/
+package geo
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is the error when none of the databases has the IP address.
+var ErrNotFound = errors.New("IP address not found in the databases")
+
+// LanguageDefault is the language of the names, such as the country and the
+// city, used if the databases have it.
+const LanguageDefault = "en"
+
+// DirsDefault are the directories to search the databases if no path is given.
+// They are the default ones of geoipupdate.
+var DirsDefault = []string{
+	"/usr/local/share/GeoIP",
+	"/usr/share/GeoIP",
+	"/var/lib/GeoIP",
+}
+
+// NamesDefault are the file names of the databases to search in DirsDefault.
+var NamesDefault = []string{
+	"GeoLite2-City.mmdb",
+	"GeoIP2-City.mmdb",
+	"dbip-city-lite.mmdb",
+	"GeoLite2-Country.mmdb",
+	"GeoIP2-Country.mmdb",
+	"dbip-country-lite.mmdb",
+	"GeoLite2-ASN.mmdb",
+	"GeoIP2-ISP.mmdb",
+	"dbip-asn-lite.mmdb",
+}
+
+// ============================================================================
+//  Type: Info
+// ============================================================================
+
+// Info is the location and the network of an IP address. The fields unknown to
+// the databases are zero values.
+type Info struct {
+	// Country is the ISO 3166-1 alpha-2 code of the country. Such as "JP".
+	Country string `json:"country,omitempty"`
+	// CountryName is the name of the country. Such as "Japan".
+	CountryName string `json:"country_name,omitempty"`
+	// City is the name of the city. Such as "Tokyo".
+	City string `json:"city,omitempty"`
+	// TimeZone is the time zone of the location. Such as "Asia/Tokyo".
+	TimeZone string `json:"time_zone,omitempty"`
+	// Org is the organization of the autonomous system. Such as "Google LLC".
+	Org string `json:"org,omitempty"`
+	// Network is the network of the IP address in the database which answered
+	// first. Such as "123.123.0.0/16".
+	Network string `json:"network,omitempty"`
+	// Latitude is the approximate latitude of the location.
+	Latitude float64 `json:"latitude,omitempty"`
+	// Longitude is the approximate longitude of the location.
+	Longitude float64 `json:"longitude,omitempty"`
+	// AccuracyRadius is the radius in kilometers around the coordinates.
+	AccuracyRadius uint `json:"accuracy_radius,omitempty"`
+	// ASN is the autonomous system number. Such as 15169.
+	ASN uint `json:"asn,omitempty"`
+}
+
+// Merges the fields of the record which are not set yet.
+func (i *Info) merge(rec *record) {
+	setIfEmpty(&i.Country, rec.Country.ISOCode)
+	setIfEmpty(&i.CountryName, nameOf(rec.Country.Names))
+	setIfEmpty(&i.City, nameOf(rec.City.Names))
+	setIfEmpty(&i.TimeZone, rec.Location.TimeZone)
+	setIfEmpty(&i.Org, rec.ASOrg)
+
+	// The registered country if the location is unknown. Such as the anycast
+	setIfEmpty(&i.Country, rec.RegisteredCountry.ISOCode)
+	setIfEmpty(&i.CountryName, nameOf(rec.RegisteredCountry.Names))
+
+	if i.Latitude == 0 && i.Longitude == 0 {
+		i.Latitude = rec.Location.Latitude
+		i.Longitude = rec.Location.Longitude
+		i.AccuracyRadius = rec.Location.AccuracyRadius
+	}
+
+	if i.ASN == 0 {
+		i.ASN = rec.ASN
+	}
+}
+
+// String returns the one line summary of the info. Such as
+// "Tokyo, Japan (JP) 35.6895,139.6917 AS15169 Google LLC".
+func (i *Info) String() string {
+	var parts []string
+
+	place := strings.Trim(i.City+", "+i.CountryName, ", ")
+	if i.Country != "" {
+		place = strings.TrimSpace(place + " (" + i.Country + ")")
+	}
+
+	if place != "" {
+		parts = append(parts, place)
+	}
+
+	if i.Latitude != 0 || i.Longitude != 0 {
+		parts = append(parts, strconv.FormatFloat(i.Latitude, 'f', -1, 64)+","+
+			strconv.FormatFloat(i.Longitude, 'f', -1, 64))
+	}
+
+	if i.ASN != 0 {
+		parts = append(parts, strings.TrimSpace("AS"+strconv.FormatUint(uint64(i.ASN), 10)+" "+i.Org))
+	} else if i.Org != "" {
+		parts = append(parts, i.Org)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// record is the union of the fields of the City, Country and ASN databases.
+type record struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country           country `maxminddb:"country"`
+	RegisteredCountry country `maxminddb:"registered_country"`
+	Location          struct {
+		TimeZone       string  `maxminddb:"time_zone"`
+		Latitude       float64 `maxminddb:"latitude"`
+		Longitude      float64 `maxminddb:"longitude"`
+		AccuracyRadius uint    `maxminddb:"accuracy_radius"`
+	} `maxminddb:"location"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+}
+
+// country is the country field of the record.
+type country struct {
+	Names   map[string]string `maxminddb:"names"`
+	ISOCode string            `maxminddb:"iso_code"`
+}
+
+// ============================================================================
+//  Type: Reader
+// ============================================================================
+
+// Reader looks up the IP addresses in the databases.
+type Reader struct {
+	dbs   []*maxminddb.Reader
+	paths []string
+}
+
+// Open opens the databases of the paths. If no path is given, the ones found in
+// DirsDefault are opened. See Find.
+func Open(paths ...string) (*Reader, error) {
+	if len(paths) == 0 {
+		paths = Find()
+		if len(paths) == 0 {
+			return nil, errors.Errorf("no database found. searched %v in %v",
+				strings.Join(NamesDefault, ", "), strings.Join(DirsDefault, ", "))
+		}
+	}
+
+	reader := &Reader{paths: paths}
+
+	for _, path := range paths {
+		database, err := maxminddb.Open(path)
+		if err != nil {
+			_ = reader.Close() // the error of opening matters
+
+			return nil, errors.Wrapf(err, "failed to open the database: %v", path)
+		}
+
+		reader.dbs = append(reader.dbs, database)
+	}
+
+	return reader, nil
+}
+
+// Close closes the databases.
+func (r *Reader) Close() error {
+	var errClose error
+
+	for _, database := range r.dbs {
+		if err := database.Close(); err != nil && errClose == nil {
+			errClose = errors.Wrap(err, "failed to close the database")
+		}
+	}
+
+	r.dbs = nil
+
+	return errClose
+}
+
+// Lookup returns the info of the IP address merged from all the databases. The
+// database given first takes precedence if they conflict. It returns an error
+// of ErrNotFound if none of them has the IP address.
+func (r *Reader) Lookup(ip net.IP) (*Info, error) {
+	if ip == nil {
+		return nil, errors.New("IP address to look up is nil")
+	}
+
+	info := new(Info)
+	found := false
+
+	for i, database := range r.dbs {
+		// IPv6 can not be looked up in the IPv4 only database
+		if ip.To4() == nil && database.Metadata.IPVersion == 4 {
+			continue
+		}
+
+		rec := new(record)
+
+		network, ok, err := database.LookupNetwork(ip, rec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to look up %v in %v", ip, r.paths[i])
+		}
+
+		if !ok {
+			continue
+		}
+
+		if !found {
+			info.Network = network.String()
+		}
+
+		found = true
+
+		info.merge(rec)
+	}
+
+	if !found {
+		return nil, errors.Wrapf(ErrNotFound, "%v", ip)
+	}
+
+	return info, nil
+}
+
+// Paths returns the paths of the databases opened.
+func (r *Reader) Paths() []string {
+	return r.paths
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Find returns the paths of the databases found in DirsDefault. Such as
+// "/usr/share/GeoIP/GeoLite2-City.mmdb". Only the first one found per name is
+// returned.
+func Find() []string {
+	var paths []string
+
+	for _, name := range NamesDefault {
+		for _, dir := range DirsDefault {
+			path := filepath.Join(dir, name)
+
+			if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+				paths = append(paths, path)
+
+				break
+			}
+		}
+	}
+
+	return paths
+}
+
+// Returns the name in LanguageDefault, or any if not available.
+func nameOf(names map[string]string) string {
+	if name, ok := names[LanguageDefault]; ok {
+		return name
+	}
+
+	// The smallest language code for the stable result
+	lang := ""
+
+	for key := range names {
+		if lang == "" || key < lang {
+			lang = key
+		}
+	}
+
+	return names[lang]
+}
+
+// Sets the value to the field if the field is empty.
+func setIfEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
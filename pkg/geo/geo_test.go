@@ -0,0 +1,203 @@
+package geo_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KEINOS/whereami/internal/geotest"
+	"github.com/KEINOS/whereami/pkg/geo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader_Lookup(t *testing.T) {
+	t.Parallel()
+
+	reader, err := geo.Open(newCityDB(t), newASNDB(t))
+	require.NoError(t, err)
+
+	defer reader.Close()
+
+	info, err := reader.Lookup(net.ParseIP("123.123.123.123"))
+
+	require.NoError(t, err)
+	assert.Equal(t, &geo.Info{
+		Country:        "JP",
+		CountryName:    "Japan",
+		City:           "Tokyo",
+		TimeZone:       "Asia/Tokyo",
+		Org:            "Example Networks",
+		Network:        "123.123.0.0/16",
+		Latitude:       35.6895,
+		Longitude:      139.6917,
+		AccuracyRadius: 100,
+		ASN:            64500,
+	}, info)
+	assert.Equal(t, "Tokyo, Japan (JP) 35.6895,139.6917 AS64500 Example Networks", info.String())
+}
+
+func TestReader_Lookup_ipv6(t *testing.T) {
+	t.Parallel()
+
+	reader, err := geo.Open(newCityDB(t), newASNDB(t))
+	require.NoError(t, err)
+
+	defer reader.Close()
+
+	info, err := reader.Lookup(net.ParseIP("2001:4860:4860::8888"))
+
+	require.NoError(t, err)
+	assert.Equal(t, "US", info.Country)
+	assert.Equal(t, "United States", info.CountryName, "registered country should be used if no location")
+	assert.Empty(t, info.City)
+	assert.Equal(t, uint(15169), info.ASN)
+	assert.Equal(t, "2001:4860::/32", info.Network)
+	assert.Equal(t, "United States (US) AS15169 Google LLC", info.String())
+}
+
+func TestReader_Lookup_partial(t *testing.T) {
+	t.Parallel()
+
+	// Only the ASN database
+	reader, err := geo.Open(newASNDB(t))
+	require.NoError(t, err)
+
+	defer reader.Close()
+
+	info, err := reader.Lookup(net.ParseIP("123.123.1.1"))
+
+	require.NoError(t, err)
+	assert.Empty(t, info.Country)
+	assert.Zero(t, info.Latitude)
+	assert.Equal(t, "AS64500 Example Networks", info.String())
+}
+
+func TestReader_Lookup_not_found(t *testing.T) {
+	t.Parallel()
+
+	reader, err := geo.Open(newCityDB(t), newASNDB(t))
+	require.NoError(t, err)
+
+	defer reader.Close()
+
+	info, err := reader.Lookup(net.ParseIP("8.8.8.8"))
+
+	require.Error(t, err)
+	assert.Nil(t, info)
+	assert.ErrorIs(t, err, geo.ErrNotFound)
+	assert.Contains(t, err.Error(), "8.8.8.8")
+
+	info, err = reader.Lookup(nil)
+
+	require.Error(t, err)
+	assert.Nil(t, info)
+}
+
+func TestOpen_error(t *testing.T) {
+	t.Parallel()
+
+	pathBroken := filepath.Join(t.TempDir(), "broken.mmdb")
+	require.NoError(t, os.WriteFile(pathBroken, []byte("not a database"), 0o600))
+
+	for _, path := range []string{filepath.Join(t.TempDir(), "missing.mmdb"), pathBroken} {
+		reader, err := geo.Open(newASNDB(t), path)
+
+		require.Error(t, err, "path: %v", path)
+		assert.Nil(t, reader)
+		assert.Contains(t, err.Error(), "failed to open the database: "+path)
+	}
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global variables
+func TestOpen_default(t *testing.T) {
+	oldDirs := geo.DirsDefault
+	defer func() {
+		geo.DirsDefault = oldDirs
+	}()
+
+	dirEmpty := t.TempDir()
+	dirFound := t.TempDir()
+
+	data, err := os.ReadFile(newASNDB(t))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dirFound, "GeoLite2-ASN.mmdb"), data, 0o600))
+
+	geo.DirsDefault = []string{dirEmpty}
+
+	reader, err := geo.Open()
+
+	require.Error(t, err)
+	assert.Nil(t, reader)
+	assert.Contains(t, err.Error(), "no database found")
+
+	geo.DirsDefault = []string{dirEmpty, dirFound}
+
+	reader, err = geo.Open()
+	require.NoError(t, err)
+
+	defer reader.Close()
+
+	assert.Equal(t, []string{filepath.Join(dirFound, "GeoLite2-ASN.mmdb")}, reader.Paths())
+}
+
+// ----------------------------------------------------------------------------
+//  Helper functions
+// ----------------------------------------------------------------------------
+
+// Returns the path of the City database for the tests.
+func newCityDB(t *testing.T) string {
+	t.Helper()
+
+	return writeDB(t, "GeoLite2-City", map[string]geotest.Record{
+		"123.123.0.0/16": {
+			"city": geotest.Record{
+				"names": geotest.Record{"en": "Tokyo", "ja": "東京"},
+			},
+			"country": geotest.Record{
+				"iso_code": "JP",
+				"names":    geotest.Record{"en": "Japan", "ja": "日本"},
+			},
+			"location": geotest.Record{
+				"accuracy_radius": uint16(100),
+				"latitude":        35.6895,
+				"longitude":       139.6917,
+				"time_zone":       "Asia/Tokyo",
+			},
+		},
+		"2001:4860::/32": {
+			"registered_country": geotest.Record{
+				"iso_code": "US",
+				"names":    geotest.Record{"en": "United States"},
+			},
+		},
+	})
+}
+
+// Returns the path of the ASN database for the tests.
+func newASNDB(t *testing.T) string {
+	t.Helper()
+
+	return writeDB(t, "GeoLite2-ASN", map[string]geotest.Record{
+		"123.123.0.0/16": {
+			"autonomous_system_number":       uint32(64500),
+			"autonomous_system_organization": "Example Networks",
+		},
+		"2001:4860::/32": {
+			"autonomous_system_number":       uint32(15169),
+			"autonomous_system_organization": "Google LLC",
+		},
+	})
+}
+
+// Writes the database to a temporary file and returns its path.
+func writeDB(t *testing.T, dbType string, records map[string]geotest.Record) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), dbType+".mmdb")
+
+	require.NoError(t, geotest.WriteFile(path, dbType, records))
+
+	return path
+}
@@ -0,0 +1,349 @@
+/*
+Package health tracks the health of the providers, such as the success rate,
+latency and the last error, and persists it to a state file between runs.
+
+It also works as a circuit breaker. A provider which failed ThresholdDefault
+times in a row is skipped until the cool-down period has passed. After that,
+the provider is tried once again (half-open) and the circuit is closed on
+success or opened again on failure.
+*/
+package health
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ThresholdDefault is the default number of consecutive failures to open the
+	// circuit of the provider.
+	ThresholdDefault = 3
+	// CoolDownDefault is the default period to skip the provider after the
+	// circuit is opened.
+	CoolDownDefault = time.Hour
+	// Weight of the latest latency in the moving average.
+	latencyWeight = 0.2
+	// Max number of characters of the last error to keep.
+	lastErrorLenMax = 200
+)
+
+// States of the circuit.
+const (
+	// StateClosed means the provider is healthy and requested as usual.
+	StateClosed = "closed"
+	// StateOpen means the provider is skipped until the cool-down has passed.
+	StateOpen = "open"
+	// StateHalfOpen means the cool-down has passed and the provider is tried
+	// once again.
+	StateHalfOpen = "half-open"
+)
+
+// TimeNow is a copy of time.Now to ease mock its behavior during test.
+var TimeNow = time.Now
+
+// ============================================================================
+//  Type: Stats
+// ============================================================================
+
+// Stats is the health record of a provider.
+type Stats struct {
+	// LastErrorAt is the time of the last failure.
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+	// LastSuccessAt is the time of the last success.
+	LastSuccessAt time.Time `json:"last_success_at,omitempty"`
+	// OpenUntil is the time until the circuit is open. Zero if closed. It is
+	// also set by Tracker.Hold.
+	OpenUntil time.Time `json:"open_until,omitempty"`
+	// Provider is the name of the provider.
+	Provider string `json:"provider"`
+	// LastError is the first line of the message of the last error, truncated
+	// to 200 characters. The response body in the message is not kept.
+	LastError string `json:"last_error,omitempty"`
+	// State is the state of the circuit at the time of Tracker.Status. It is
+	// not persisted.
+	State string `json:"-"`
+	// Successes is the total number of successful requests.
+	Successes int `json:"successes"`
+	// Failures is the total number of failed requests.
+	Failures int `json:"failures"`
+	// ConsecutiveFailures is the number of failures in a row.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// Latency is the moving average of the latency of the successful requests.
+	Latency time.Duration `json:"latency"`
+}
+
+// SuccessRate returns the ratio of the successful requests between 0 and 1.
+// It returns -1 if the provider was never requested.
+func (s Stats) SuccessRate() float64 {
+	total := s.Successes + s.Failures
+	if total == 0 {
+		return -1
+	}
+
+	return float64(s.Successes) / float64(total)
+}
+
+// ============================================================================
+//  Type: Tracker
+// ============================================================================
+
+// Tracker records the health of the providers. It is safe for concurrent use.
+type Tracker struct {
+	stats map[string]*Stats
+	// Path is the path to the state file. If empty, Save does nothing.
+	Path string
+	// Threshold is the number of consecutive failures to open the circuit.
+	Threshold int
+	// CoolDown is the period to skip the provider after the circuit is opened.
+	CoolDown time.Duration
+	mu       sync.Mutex
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// New returns a new Tracker with no records and the default settings. It is
+// not persisted unless Path is set.
+func New() *Tracker {
+	return &Tracker{
+		stats:     make(map[string]*Stats),
+		Threshold: ThresholdDefault,
+		CoolDown:  CoolDownDefault,
+	}
+}
+
+// Load returns a new Tracker with the records of the state file at path. If the
+// file does not exist, it returns an empty tracker which will be saved to path.
+func Load(path string) (*Tracker, error) {
+	tracker := New()
+	tracker.Path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return tracker, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to read the health state file")
+	}
+
+	var list []*Stats
+
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the health state file: %v", path)
+	}
+
+	for _, stats := range list {
+		tracker.stats[stats.Provider] = stats
+	}
+
+	return tracker, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Tracker
+// ----------------------------------------------------------------------------
+
+// Allow returns false if the circuit of the provider is open. Unknown providers
+// are allowed.
+func (t *Tracker) Allow(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.state(name) != StateOpen
+}
+
+// Get returns a copy of the record of the provider and true. If not recorded,
+// it returns false.
+func (t *Tracker) Get(name string) (Stats, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, ok := t.stats[name]
+	if !ok {
+		return Stats{Provider: name, State: StateClosed}, false
+	}
+
+	result := *stats
+	result.State = t.state(name)
+
+	return result, true
+}
+
+// Record records the result of a request to the provider. err is nil on
+// success.
+func (t *Tracker) Record(name string, latency time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, ok := t.stats[name]
+	if !ok {
+		stats = &Stats{Provider: name}
+		t.stats[name] = stats
+	}
+
+	now := TimeNow()
+
+	if err != nil {
+		stats.Failures++
+		stats.ConsecutiveFailures++
+		stats.LastError = summarize(err)
+		stats.LastErrorAt = now
+
+		// Open (or re-open on half-open) the circuit. Keep the longer one held.
+		if until := now.Add(t.CoolDown); stats.ConsecutiveFailures >= t.Threshold && until.After(stats.OpenUntil) {
+			stats.OpenUntil = until
+		}
+
+		return
+	}
+
+	if stats.Successes == 0 {
+		stats.Latency = latency
+	} else {
+		stats.Latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(stats.Latency))
+	}
+
+	stats.Successes++
+	stats.ConsecutiveFailures = 0
+	stats.LastSuccessAt = now
+	stats.OpenUntil = time.Time{}
+}
+
+// Hold skips the provider until the given time regardless of the threshold.
+// Such as the provider told to retry after a while due to the rate limit. It
+// does not shorten the period if the circuit is already open longer.
+func (t *Tracker) Hold(name string, until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, ok := t.stats[name]
+	if !ok {
+		stats = &Stats{Provider: name}
+		t.stats[name] = stats
+	}
+
+	if until.After(stats.OpenUntil) {
+		stats.OpenUntil = until
+	}
+}
+
+// Save writes the records to the state file at Path. The directory is created
+// if not exists.
+func (t *Tracker) Save() error {
+	if t.Path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(t.Status(), "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the health state")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.Path), 0o700); err != nil {
+		return errors.Wrap(err, "failed to create the directory of the health state file")
+	}
+
+	// Write to a temporary file of its own then rename, to not break the state
+	// file on concurrent runs. The rename is atomic in the same directory.
+	fileTemp, err := os.CreateTemp(filepath.Dir(t.Path), filepath.Base(t.Path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create the temporary health state file")
+	}
+
+	pathTemp := fileTemp.Name()
+
+	_, err = fileTemp.Write(data)
+	if errClose := fileTemp.Close(); err == nil {
+		err = errClose
+	}
+
+	if err == nil {
+		err = os.Rename(pathTemp, t.Path)
+	}
+
+	if err != nil {
+		_ = os.Remove(pathTemp)
+
+		return errors.Wrap(err, "failed to write the health state file")
+	}
+
+	return nil
+}
+
+// Status returns the records of all the providers sorted by name, with the
+// current state of the circuit.
+func (t *Tracker) Status() []Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	list := make([]Stats, 0, len(t.stats))
+
+	for name, stats := range t.stats {
+		item := *stats
+		item.State = t.state(name)
+
+		list = append(list, item)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Provider < list[j].Provider
+	})
+
+	return list
+}
+
+// Returns the current state of the circuit of the provider. The lock must be
+// held by the caller.
+func (t *Tracker) state(name string) string {
+	stats, ok := t.stats[name]
+	if !ok || stats.OpenUntil.IsZero() {
+		return StateClosed
+	}
+
+	if TimeNow().Before(stats.OpenUntil) {
+		return StateOpen
+	}
+
+	// Held by Hold but not failed enough to be tried carefully
+	if stats.ConsecutiveFailures < t.Threshold {
+		return StateClosed
+	}
+
+	return StateHalfOpen
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// DefaultPath returns the default path of the state file. Which is
+// "whereami/health.json" under the user cache directory. Such as
+// "~/.cache/whereami/health.json" on Linux.
+func DefaultPath() (string, error) {
+	dirCache, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the user cache directory")
+	}
+
+	return filepath.Join(dirCache, "whereami", "health.json"), nil
+}
+
+// Returns the first line of the error message up to lastErrorLenMax characters.
+// Such as the message without the status and the response body of the provider.
+func summarize(err error) string {
+	msg := []rune(strings.SplitN(err.Error(), "\n", 2)[0])
+	if len(msg) > lastErrorLenMax {
+		return string(msg[:lastErrorLenMax]) + "..."
+	}
+
+	return string(msg)
+}
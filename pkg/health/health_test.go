@@ -0,0 +1,243 @@
+package health_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/health"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dummyName = "https://dummy.example.com/"
+
+//nolint:paralleltest // do not parallelize due to mocking TimeNow
+func TestTracker_circuit_breaker(t *testing.T) {
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	oldTimeNow := health.TimeNow
+	defer func() { health.TimeNow = oldTimeNow }()
+
+	health.TimeNow = func() time.Time { return now }
+
+	tracker := health.New()
+	tracker.Threshold = 2
+	tracker.CoolDown = time.Minute
+
+	require.True(t, tracker.Allow(dummyName), "unknown provider should be allowed")
+
+	tracker.Record(dummyName, time.Second, errors.New("forced error 1"))
+	require.True(t, tracker.Allow(dummyName), "it should be allowed until the threshold")
+
+	tracker.Record(dummyName, time.Second, errors.New("forced error 2"))
+	require.False(t, tracker.Allow(dummyName), "it should open the circuit on threshold")
+
+	stats, ok := tracker.Get(dummyName)
+
+	require.True(t, ok)
+	assert.Equal(t, health.StateOpen, stats.State)
+	assert.Equal(t, "forced error 2", stats.LastError)
+	assert.Equal(t, now.Add(time.Minute), stats.OpenUntil)
+
+	// After the cool-down
+	now = now.Add(time.Minute)
+
+	require.True(t, tracker.Allow(dummyName), "it should be tried again after the cool-down")
+
+	stats, _ = tracker.Get(dummyName)
+	assert.Equal(t, health.StateHalfOpen, stats.State)
+
+	// Failure on half-open re-opens the circuit
+	tracker.Record(dummyName, time.Second, errors.New("forced error 3"))
+	require.False(t, tracker.Allow(dummyName), "failure on half-open should re-open the circuit")
+
+	// Success on half-open closes the circuit
+	now = now.Add(time.Minute)
+
+	tracker.Record(dummyName, 100*time.Millisecond, nil)
+
+	stats, _ = tracker.Get(dummyName)
+
+	assert.Equal(t, health.StateClosed, stats.State)
+	assert.Zero(t, stats.ConsecutiveFailures)
+	assert.Equal(t, 1, stats.Successes)
+	assert.Equal(t, 3, stats.Failures)
+	assert.Equal(t, 0.25, stats.SuccessRate())
+	assert.Equal(t, 100*time.Millisecond, stats.Latency, "the first latency should be as is")
+}
+
+func TestTracker_latency_moving_average(t *testing.T) {
+	t.Parallel()
+
+	tracker := health.New()
+
+	tracker.Record(dummyName, 100*time.Millisecond, nil)
+	tracker.Record(dummyName, 200*time.Millisecond, nil)
+
+	stats, _ := tracker.Get(dummyName)
+
+	assert.Equal(t, 120*time.Millisecond, stats.Latency)
+}
+
+func TestTracker_Record_last_error_first_line(t *testing.T) {
+	t.Parallel()
+
+	tracker := health.New()
+
+	tracker.Record(dummyName, 0, errors.New("forced error\nStatus: 500\nResponse body: <html>"))
+
+	stats, _ := tracker.Get(dummyName)
+
+	assert.Equal(t, "forced error", stats.LastError, "the response body should not be kept")
+
+	tracker.Record(dummyName, 0, errors.New(strings.Repeat("x", 300)))
+
+	stats, _ = tracker.Get(dummyName)
+
+	assert.Equal(t, strings.Repeat("x", 200)+"...", stats.LastError, "long message should be truncated")
+}
+
+func TestTracker_Save_and_Load(t *testing.T) {
+	t.Parallel()
+
+	pathFile := filepath.Join(t.TempDir(), "sub", "health.json")
+
+	tracker, err := health.Load(pathFile)
+	require.NoError(t, err, "missing state file should not be an error")
+	require.Empty(t, tracker.Status())
+
+	tracker.Record(dummyName, time.Second, nil)
+	tracker.Record("https://other.example.com/", time.Second, errors.New("forced error"))
+
+	require.NoError(t, tracker.Save())
+
+	loaded, err := health.Load(pathFile)
+	require.NoError(t, err)
+
+	status := loaded.Status()
+
+	require.Len(t, status, 2)
+	assert.Equal(t, dummyName, status[0].Provider, "it should be sorted by name")
+	assert.Equal(t, time.Second, status[0].Latency)
+	assert.Equal(t, "https://other.example.com/", status[1].Provider)
+	assert.Equal(t, "forced error", status[1].LastError)
+}
+
+func TestTracker_Save_concurrent(t *testing.T) {
+	t.Parallel()
+
+	pathDir := t.TempDir()
+	pathFile := filepath.Join(pathDir, "health.json")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			tracker := health.New()
+			tracker.Path = pathFile
+			tracker.Record(dummyName, time.Second, nil)
+
+			assert.NoError(t, tracker.Save())
+		}()
+	}
+
+	wg.Wait()
+
+	loaded, err := health.Load(pathFile)
+	require.NoError(t, err, "the state file should not be broken by the concurrent runs")
+	require.Len(t, loaded.Status(), 1)
+
+	files, err := os.ReadDir(pathDir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "the temporary files should not be left")
+}
+
+func TestTracker_Save_no_path(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, health.New().Save(), "it should do nothing if the path is empty")
+}
+
+func TestLoad_broken_file(t *testing.T) {
+	t.Parallel()
+
+	pathFile := filepath.Join(t.TempDir(), "health.json")
+
+	require.NoError(t, os.WriteFile(pathFile, []byte("broken"), 0o600))
+
+	tracker, err := health.Load(pathFile)
+
+	require.Error(t, err)
+	require.Nil(t, tracker)
+	assert.Contains(t, err.Error(), "failed to parse the health state file")
+}
+
+func TestStats_SuccessRate_no_request(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, float64(-1), health.Stats{}.SuccessRate())
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Parallel()
+
+	pathFile, err := health.DefaultPath()
+	if err != nil {
+		t.Skip("user cache directory is not available:", err)
+	}
+
+	assert.Equal(t, filepath.Join("whereami", "health.json"),
+		filepath.Join(filepath.Base(filepath.Dir(pathFile)), filepath.Base(pathFile)))
+}
+
+//nolint:paralleltest // do not parallelize due to mocking TimeNow
+func TestTracker_Hold(t *testing.T) {
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	oldTimeNow := health.TimeNow
+	defer func() { health.TimeNow = oldTimeNow }()
+
+	health.TimeNow = func() time.Time { return now }
+
+	tracker := health.New()
+	tracker.CoolDown = time.Minute
+
+	tracker.Record(dummyName, time.Second, errors.New("429 Too Many Requests"))
+	tracker.Hold(dummyName, now.Add(time.Hour))
+
+	require.False(t, tracker.Allow(dummyName), "it should skip the provider held regardless of the threshold")
+
+	// Shorter hold and cool-down should not shorten the period
+	tracker.Hold(dummyName, now.Add(time.Second))
+	tracker.Record(dummyName, time.Second, errors.New("forced error"))
+	tracker.Record(dummyName, time.Second, errors.New("forced error"))
+
+	stats, _ := tracker.Get(dummyName)
+
+	assert.Equal(t, health.StateOpen, stats.State)
+	assert.Equal(t, now.Add(time.Hour), stats.OpenUntil)
+
+	// After the period, it is half-open since it failed enough
+	now = now.Add(time.Hour)
+
+	stats, _ = tracker.Get(dummyName)
+	assert.Equal(t, health.StateHalfOpen, stats.State)
+
+	// Held without failures is closed after the period
+	tracker.Hold("https://other.example.com/", now.Add(time.Minute))
+	require.False(t, tracker.Allow("https://other.example.com/"))
+
+	now = now.Add(time.Minute)
+
+	stats, _ = tracker.Get("https://other.example.com/")
+	assert.Equal(t, health.StateClosed, stats.State)
+}
@@ -1,19 +1,18 @@
 /*
 Package info is a simple logger that displays detailed information when the "--verbose" flag is set.
+
+The package level functions such as Log, Get and Clear use the default Logger.
+Use New to create a separated one. Such as one per resolver, so the logs will
+not be mixed.
 */
 package info
 
 import (
-	"bytes"
-	"math"
-	"net"
-	"strconv"
-	"strings"
-
-	"github.com/pkg/errors"
+	"github.com/KEINOS/whereami/pkg/ipaddr"
 )
 
-var logBuff bytes.Buffer
+// std is the default logger used by the package level functions.
+var std = New()
 
 // Prefix is the prefix of each record in the log.
 var Prefix = "[LOG]: "
@@ -22,62 +21,38 @@ var Prefix = "[LOG]: "
 //  Functions
 // ----------------------------------------------------------------------------
 
-// Clear clears the current log.
+// Clear clears the current log of the default logger.
 func Clear() {
-	logBuff = bytes.Buffer{}
+	std.Clear()
+}
+
+// Default returns the default logger used by the package level functions.
+func Default() *Logger {
+	return std
 }
 
-// Get returns the current log.
+// Get returns the current log of the default logger.
 func Get() string {
-	return logBuff.String()
+	return std.Get()
 }
 
-// Log writes the given logs to the log buffer.
+// Log writes the given logs to the log buffer of the default logger.
 //
 // Note that if a "logs" is empty, or all blank, nothing is recorded.
 func Log(logs ...string) (int, error) {
-	s := strings.TrimSpace(strings.Join(logs, " "))
-	if s == "" {
-		return 0, nil // do nothing
-	}
-
-	lenData, err := logBuff.Write([]byte(Prefix + s + "\n"))
-
-	return lenData, errors.Wrap(err, "failed to write to log buffer")
+	return std.Log(logs...)
 }
 
 // NormalizeIPv4 will trim the zero padded IP address. For example, "001.001.001.001"
 // will be "1.1.1.1".
 //
 // **Note** that it is not a validator. If the given ipAddress is invalid it will return as is.
+//
+// Deprecated: Use ipaddr.Normalize or ipaddr.Parse which also supports IPv6.
 func NormalizeIPv4(ipAddress string) string {
-	// golden input
-	if net.ParseIP(ipAddress).String() == ipAddress {
-		return ipAddress
-	}
-
-	// Number of numerical labels such as 1.1.1.1 has 4 labels.
-	const numChunks = 4
-
-	// Split quad-dotted IP address
-	chunks := strings.Split(ipAddress, ".")
-	if len(chunks) != numChunks {
-		return ipAddress
-	}
-
-	ipv4 := make([]byte, numChunks)
-
-	for index, chunk := range chunks {
-		chunkInt, err := strconv.Atoi(chunk)
-		if err != nil {
-			return ipAddress
-		}
-
-		// Check for lower and upper bounds
-		if chunkInt > 0 && chunkInt <= math.MaxInt8 {
-			ipv4[index] = byte(chunkInt)
-		}
+	if normalized := ipaddr.Normalize(ipAddress); normalized != "" {
+		return normalized
 	}
 
-	return net.IPv4(ipv4[0], ipv4[1], ipv4[2], ipv4[3]).String()
+	return ipAddress
 }
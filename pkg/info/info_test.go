@@ -12,11 +12,13 @@ import (
 func ExampleLog() {
 	// To avoid conflicts during testing this example, back up the current buffer
 	// and restore it later. This is usually not necessary.
-	oldLogBuff := logBuff
+	oldStd := std
 	defer func() {
-		logBuff = oldLogBuff
+		std = oldStd
 	}()
 
+	std = New()
+
 	/* Example usage */
 
 	// Log
@@ -60,11 +62,13 @@ func ExampleLog() {
 //nolint:paralleltest // do not parallelize due to mocking global function variables
 func TestGet_whitespaces(t *testing.T) {
 	// Backup and defer restore.
-	oldLogBuff := logBuff
+	oldStd := std
 	defer func() {
-		logBuff = oldLogBuff
+		std = oldStd
 	}()
 
+	std = New()
+
 	Clear()
 
 	n, err := Log(" ", " ", " ")
@@ -88,6 +92,8 @@ func TestNormalizeIPv4(t *testing.T) {
 		// normalize
 		{input: "001.001.001.001", expect: "1.1.1.1"},
 		{input: "001.010.100.101", expect: "1.10.100.101"},
+		{input: "192.168.001.200", expect: "192.168.1.200"},
+		{input: "255.000.128.001", expect: "255.0.128.1"},
 		// invalid
 		{input: "123.123.123", expect: "123.123.123"},
 		{input: "123.123.123.123.123", expect: "123.123.123.123.123"},
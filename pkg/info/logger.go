@@ -0,0 +1,397 @@
+package info
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Type: Level
+// ============================================================================
+
+// Level is the severity of the log record.
+type Level int
+
+// Log levels.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the name of the level in lower case. Such as "info".
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+
+	return "level(" + strconv.Itoa(int(l)) + ")"
+}
+
+// ============================================================================
+//  Type: Format
+// ============================================================================
+
+// Format is the format of the records written to the output set via SetOutput.
+type Format string
+
+// Log formats.
+const (
+	// FormatText is the human readable format. Same as the records in the buffer.
+	//   [LOG]: WARN: request failed provider=https://ipinfo.io/ duration=1.2s
+	FormatText Format = "text"
+	// FormatJSON is the JSON lines format. One JSON object per record.
+	//   {"time":"2006-01-02T15:04:05Z","level":"warn","msg":"request failed","provider":"https://ipinfo.io/","duration":"1.2s"}
+	FormatJSON Format = "json"
+	// FormatLogfmt is the logfmt format. One line of key=value pairs per record.
+	//   time=2006-01-02T15:04:05Z level=warn msg="request failed" provider=https://ipinfo.io/ duration=1.2s
+	FormatLogfmt Format = "logfmt"
+)
+
+// ParseFormat returns the Format of the given name. Such as "json".
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(name))); format {
+	case FormatText, FormatJSON, FormatLogfmt:
+		return format, nil
+	}
+
+	return "", errors.Errorf("unknown log format: %v (text, json or logfmt)", name)
+}
+
+// ============================================================================
+//  Type: Logger
+// ============================================================================
+
+// Logger is a logger which records the logs in the buffer. It is safe for
+// concurrent use.
+//
+// Note that the methods of nil *Logger do nothing. So nil can be used to
+// disable logging.
+type Logger struct {
+	core   *core
+	fields []interface{} // key/value pairs added to each record
+}
+
+// core is the buffer of the logger shared between the derived loggers.
+type core struct {
+	out    io.Writer // optional output to stream the records
+	format Format    // format of the records written to out
+	buf    bytes.Buffer
+	mu     sync.Mutex
+	level  Level
+}
+
+// record is a log record before formatting.
+type record struct {
+	time   time.Time
+	msg    string
+	fields []interface{}
+	level  Level
+}
+
+// timeNow is a copy of time.Now to ease mock its behavior during test.
+var timeNow = time.Now
+
+// ctxKeyLogger is the key type to store the logger in the context.
+type ctxKeyLogger struct{}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// New returns a new Logger which records all levels.
+func New() *Logger {
+	return &Logger{
+		core: &core{level: LevelDebug},
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Logger
+// ----------------------------------------------------------------------------
+
+// Clear clears the current log.
+func (l *Logger) Clear() {
+	if l == nil {
+		return
+	}
+
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+
+	l.core.buf.Reset()
+}
+
+// Debug records the msg in debug level with the key/value pairs.
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	_, _ = l.write(LevelDebug, msg, keyvals)
+}
+
+// Error records the msg in error level with the key/value pairs.
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	_, _ = l.write(LevelError, msg, keyvals)
+}
+
+// Get returns the current log.
+func (l *Logger) Get() string {
+	if l == nil {
+		return ""
+	}
+
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+
+	return l.core.buf.String()
+}
+
+// Info records the msg in info level with the key/value pairs.
+//
+//	logger.Info("response received", "provider", name, "status", 200)
+//	// Output: [LOG]: response received provider=https://ipinfo.io/ status=200
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	_, _ = l.write(LevelInfo, msg, keyvals)
+}
+
+// Log writes the given logs to the log buffer in info level. The logs are
+// joined with a space.
+//
+// Note that if a "logs" is empty, or all blank, nothing is recorded.
+func (l *Logger) Log(logs ...string) (int, error) {
+	return l.write(LevelInfo, strings.Join(logs, " "), nil)
+}
+
+// SetLevel sets the minimum level to record. It affects all the loggers
+// derived via With.
+func (l *Logger) SetLevel(level Level) {
+	if l == nil {
+		return
+	}
+
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+
+	l.core.level = level
+}
+
+// SetOutput sets the writer to stream each record in the given format, in
+// addition to the buffer. Such as os.Stderr or a log file. nil stops streaming.
+// It affects all the loggers derived via With.
+func (l *Logger) SetOutput(out io.Writer, format Format) {
+	if l == nil {
+		return
+	}
+
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+
+	l.core.out = out
+	l.core.format = format
+}
+
+// Warn records the msg in warn level with the key/value pairs.
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	_, _ = l.write(LevelWarn, msg, keyvals)
+}
+
+// With returns a logger which adds the given key/value pairs to each record.
+// The returned logger shares the buffer with the original.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	if l == nil {
+		return nil
+	}
+
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+
+	return &Logger{core: l.core, fields: fields}
+}
+
+// Formats and writes the record to the buffer and the output if any.
+func (l *Logger) write(level Level, msg string, keyvals []interface{}) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	msg = strings.TrimSpace(msg)
+	if msg == "" {
+		return 0, nil // do nothing
+	}
+
+	rec := record{
+		time:   timeNow(),
+		level:  level,
+		msg:    msg,
+		fields: make([]interface{}, 0, len(l.fields)+len(keyvals)),
+	}
+
+	rec.fields = append(rec.fields, l.fields...)
+	rec.fields = append(rec.fields, keyvals...)
+
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+
+	if level < l.core.level {
+		return 0, nil
+	}
+
+	lenData, err := l.core.buf.WriteString(rec.format(FormatText))
+	if err != nil {
+		return lenData, errors.Wrap(err, "failed to write to log buffer")
+	}
+
+	if l.core.out != nil {
+		if _, err := io.WriteString(l.core.out, rec.format(l.core.format)); err != nil {
+			return lenData, errors.Wrap(err, "failed to write to log output")
+		}
+	}
+
+	return lenData, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for record
+// ----------------------------------------------------------------------------
+
+// Returns the key/value pairs of the fields. The key of the odd one is
+// "!BADKEY".
+func (rec record) eachField(fn func(key string, value interface{})) {
+	for i := 0; i < len(rec.fields); i += 2 {
+		key, value := "!BADKEY", rec.fields[i]
+
+		if i+1 < len(rec.fields) {
+			key, value = fmt.Sprint(rec.fields[i]), rec.fields[i+1]
+		}
+
+		fn(key, value)
+	}
+}
+
+// Returns the record as a line in the given format. Unknown format is treated
+// as FormatText.
+func (rec record) format(format Format) string {
+	var line strings.Builder
+
+	switch format {
+	case FormatJSON:
+		line.WriteString(`{"time":` + jsonValue(rec.time.Format(time.RFC3339Nano)))
+		line.WriteString(`,"level":` + jsonValue(rec.level.String()))
+		line.WriteString(`,"msg":` + jsonValue(rec.msg))
+
+		rec.eachField(func(key string, value interface{}) {
+			line.WriteString("," + jsonValue(key) + ":" + jsonValue(value))
+		})
+
+		line.WriteString("}")
+	case FormatLogfmt:
+		line.WriteString("time=" + rec.time.Format(time.RFC3339Nano))
+		line.WriteString(" level=" + rec.level.String())
+		line.WriteString(" msg=" + formatValue(rec.msg))
+
+		rec.eachField(func(key string, value interface{}) {
+			line.WriteString(" " + key + "=" + formatValue(value))
+		})
+	default:
+		line.WriteString(Prefix)
+
+		if rec.level != LevelInfo {
+			line.WriteString(strings.ToUpper(rec.level.String()) + ": ")
+		}
+
+		line.WriteString(rec.msg)
+
+		rec.eachField(func(key string, value interface{}) {
+			line.WriteString(" " + key + "=" + formatValue(value))
+		})
+	}
+
+	line.WriteString("\n")
+
+	return line.String()
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// FromContext returns the logger stored in the ctx via NewContext. If none, it
+// returns nil.
+func FromContext(ctx context.Context) *Logger {
+	logger, _ := ctx.Value(ctxKeyLogger{}).(*Logger)
+
+	return logger
+}
+
+// LogFunc returns the Log method of the logger stored in the ctx via NewContext.
+// If none, it returns the fallback.
+//
+// It is for the providers to log to the logger of the caller, such as the
+// resolver, while keeping their own (mockable) log function as the default.
+func LogFunc(ctx context.Context, fallback func(logs ...string) (int, error)) func(logs ...string) (int, error) {
+	// nil logger is also respected to disable logging
+	if logger, ok := ctx.Value(ctxKeyLogger{}).(*Logger); ok {
+		return logger.Log
+	}
+
+	return fallback
+}
+
+// NewContext returns a copy of ctx which holds the logger. The providers log
+// to this logger if set.
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, ctxKeyLogger{}, logger)
+}
+
+// Returns the value as a JSON value. Errors and fmt.Stringers (such as
+// time.Duration and net.IP) are encoded as their string, numbers and booleans
+// as is, and the others as fmt.Sprint.
+func jsonValue(value interface{}) string {
+	switch val := value.(type) {
+	case error:
+		value = val.Error()
+	case fmt.Stringer:
+		value = val.String()
+	case string, bool, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, float32, float64:
+		// as is
+	default:
+		value = fmt.Sprint(val)
+	}
+
+	out, err := json.Marshal(value)
+	if err != nil {
+		// Such as NaN of float
+		out, _ = json.Marshal(fmt.Sprint(value))
+	}
+
+	return string(out)
+}
+
+// Returns the value as a string. It will be quoted if it contains spaces,
+// quotes or "=" to keep the record parsable.
+func formatValue(value interface{}) string {
+	str := fmt.Sprint(value)
+
+	if str == "" || strings.ContainsAny(str, " \t\r\n\"=") {
+		return strconv.Quote(str)
+	}
+
+	return str
+}
@@ -0,0 +1,257 @@
+package info_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleLogger() {
+	logger := info.New()
+
+	logger.Info("response received", "provider", "https://ipinfo.io/", "status", 200)
+	logger.Warn("request failed", "error", errors.New("connection reset by peer"))
+
+	// Derived logger adds the fields to each record
+	provLogger := logger.With("provider", "https://api64.ipify.org/")
+	provLogger.Debug("request start")
+
+	fmt.Print(logger.Get())
+
+	// Output:
+	// [LOG]: response received provider=https://ipinfo.io/ status=200
+	// [LOG]: WARN: request failed error="connection reset by peer"
+	// [LOG]: DEBUG: request start provider=https://api64.ipify.org/
+}
+
+func TestLogger_SetLevel(t *testing.T) {
+	t.Parallel()
+
+	logger := info.New()
+	logger.SetLevel(info.LevelWarn)
+
+	logger.Debug("debug log")
+	logger.Info("info log")
+	logger.Warn("warn log")
+	logger.Error("error log")
+
+	logs := logger.Get()
+
+	assert.NotContains(t, logs, "debug log")
+	assert.NotContains(t, logs, "info log")
+	assert.Contains(t, logs, "WARN: warn log")
+	assert.Contains(t, logs, "ERROR: error log")
+}
+
+func TestLogger_With_shares_buffer(t *testing.T) {
+	t.Parallel()
+
+	logger := info.New()
+	child := logger.With("key1", "value1")
+
+	child.Info("from child", "key2", "value 2")
+	logger.Info("from parent")
+
+	expect := "[LOG]: from child key1=value1 key2=\"value 2\"\n[LOG]: from parent\n"
+
+	assert.Equal(t, expect, logger.Get())
+	assert.Equal(t, expect, child.Get())
+
+	child.Clear()
+
+	assert.Empty(t, logger.Get(), "clearing the child should clear the parent as well")
+}
+
+func TestLogger_odd_keyvals(t *testing.T) {
+	t.Parallel()
+
+	logger := info.New()
+	logger.Info("odd", "key", "value", "lonely")
+
+	assert.Equal(t, "[LOG]: odd key=value !BADKEY=lonely\n", logger.Get())
+}
+
+func TestLogger_concurrent_use(t *testing.T) {
+	t.Parallel()
+
+	const numWorkers = 50
+
+	logger := info.New()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+
+		go func(id int) {
+			defer wg.Done()
+
+			logger.With("worker", id).Info("hello")
+			_ = logger.Get()
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, numWorkers, strings.Count(logger.Get(), "hello"), "all the logs should be recorded")
+}
+
+func TestLogger_nil(t *testing.T) {
+	t.Parallel()
+
+	var logger *info.Logger
+
+	require.NotPanics(t, func() {
+		logger.Info("foo")
+		logger.SetLevel(info.LevelError)
+		logger.Clear()
+
+		n, err := logger.Log("foo")
+
+		require.NoError(t, err)
+		require.Zero(t, n)
+		require.Nil(t, logger.With("key", "value"))
+		require.Empty(t, logger.Get())
+	}, "nil logger should be usable to disable logging")
+}
+
+func TestLogger_SetOutput_json(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	logger := info.New()
+	logger.SetOutput(&out, info.FormatJSON)
+
+	logger.With("provider", "https://ipinfo.io/").Warn("request failed\nwith new line",
+		"duration", 1500*time.Millisecond,
+		"status", 503,
+		"error", errors.New("service unavailable"),
+	)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 1, "each record should be a single line")
+
+	var record map[string]interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+
+	_, err := time.Parse(time.RFC3339Nano, fmt.Sprint(record["time"]))
+	require.NoError(t, err, "time should be in RFC3339")
+
+	assert.Equal(t, "warn", record["level"])
+	assert.Equal(t, "request failed\nwith new line", record["msg"])
+	assert.Equal(t, "https://ipinfo.io/", record["provider"])
+	assert.Equal(t, "1.5s", record["duration"])
+	assert.Equal(t, float64(503), record["status"], "numbers should be kept as numbers")
+	assert.Equal(t, "service unavailable", record["error"])
+
+	// The buffer keeps the text format
+	assert.Contains(t, logger.Get(), "[LOG]: WARN: request failed")
+}
+
+func TestLogger_SetOutput_logfmt(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	logger := info.New()
+	logger.SetOutput(&out, info.FormatLogfmt)
+	logger.SetLevel(info.LevelInfo)
+
+	logger.Debug("filtered")
+	logger.Info("response status", "provider", "https://ipinfo.io/", "status", 200)
+
+	expect := regexp.MustCompile(`^time=\S+ level=info msg="response status" provider=https://ipinfo.io/ status=200\n$`)
+
+	assert.Regexp(t, expect, out.String())
+
+	// Stop streaming
+	out.Reset()
+	logger.SetOutput(nil, info.FormatLogfmt)
+	logger.Info("not streamed")
+
+	assert.Empty(t, out.String())
+	assert.Contains(t, logger.Get(), "not streamed")
+}
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		input  string
+		expect info.Format
+	}{
+		{input: "text", expect: info.FormatText},
+		{input: "JSON", expect: info.FormatJSON},
+		{input: " logfmt ", expect: info.FormatLogfmt},
+	} {
+		format, err := info.ParseFormat(test.input)
+
+		require.NoError(t, err)
+		assert.Equal(t, test.expect, format)
+	}
+
+	_, err := info.ParseFormat("xml")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown log format: xml")
+}
+
+func TestLevel_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "debug", info.LevelDebug.String())
+	assert.Equal(t, "info", info.LevelInfo.String())
+	assert.Equal(t, "warn", info.LevelWarn.String())
+	assert.Equal(t, "error", info.LevelError.String())
+	assert.Equal(t, "level(99)", info.Level(99).String())
+}
+
+func TestLogFunc(t *testing.T) {
+	t.Parallel()
+
+	fallbackCalled := false
+	fallback := func(logs ...string) (int, error) {
+		fallbackCalled = true
+
+		return 0, nil
+	}
+
+	// No logger in the context
+	_, err := info.LogFunc(context.Background(), fallback)("foo")
+
+	require.NoError(t, err)
+	require.True(t, fallbackCalled, "it should use the fallback if no logger in the context")
+
+	// Logger in the context
+	fallbackCalled = false
+	logger := info.New()
+	ctx := info.NewContext(context.Background(), logger)
+
+	_, err = info.LogFunc(ctx, fallback)("bar")
+
+	require.NoError(t, err)
+	require.False(t, fallbackCalled, "it should not use the fallback if the logger is in the context")
+	require.Equal(t, logger, info.FromContext(ctx))
+	require.Contains(t, logger.Get(), "bar")
+
+	// nil logger in the context disables logging
+	ctx = info.NewContext(context.Background(), nil)
+
+	_, err = info.LogFunc(ctx, fallback)("baz")
+
+	require.NoError(t, err)
+	require.False(t, fallbackCalled, "nil logger in the context should disable logging")
+}
@@ -0,0 +1,164 @@
+/*
+Package ipaddr classifies the IP addresses by the IANA special-purpose address
+registries. So that the private, CGNAT, loopback or documentation addresses
+returned by a misconfigured proxy are not trusted as the global/public one.
+
+	if class := ipaddr.Classify(ip); !class.IsGlobal() {
+		return errors.Errorf("%v is not global: %v", ip, class)
+	}
+
+See:
+  - https://www.iana.org/assignments/iana-ipv4-special-registry/
+  - https://www.iana.org/assignments/iana-ipv6-special-registry/
+*/
+package ipaddr
+
+import (
+	"net"
+)
+
+// ============================================================================
+//  Type: Class
+// ============================================================================
+
+// Class is the class of an IP address.
+type Class string
+
+// Classes of the IP addresses.
+const (
+	// ClassGlobal is the globally reachable unicast address.
+	ClassGlobal Class = "global"
+	// ClassPrivate is the private address of RFC 1918 or the unique local
+	// address of IPv6 (RFC 4193).
+	ClassPrivate Class = "private"
+	// ClassCGNAT is the shared address space of the carrier-grade NAT (RFC 6598).
+	ClassCGNAT Class = "cgnat"
+	// ClassLoopback is the loopback address.
+	ClassLoopback Class = "loopback"
+	// ClassLinkLocal is the link-local address.
+	ClassLinkLocal Class = "link_local"
+	// ClassDocumentation is the address for the documentation and examples.
+	ClassDocumentation Class = "documentation"
+	// ClassBenchmark is the address for the benchmark testing (RFC 2544).
+	ClassBenchmark Class = "benchmark"
+	// ClassMulticast is the multicast address.
+	ClassMulticast Class = "multicast"
+	// ClassUnspecified is the unspecified address. Such as "0.0.0.0" or "::".
+	ClassUnspecified Class = "unspecified"
+	// ClassReserved is the other address not globally reachable. Such as the
+	// reserved for the future use, the protocol assignments and the ones out of
+	// the global unicast of IPv6 (2000::/3), a.k.a. bogons.
+	ClassReserved Class = "reserved"
+	// ClassInvalid is not an IP address.
+	ClassInvalid Class = "invalid"
+)
+
+// IsGlobal returns true if the class is ClassGlobal.
+func (c Class) IsGlobal() bool {
+	return c == ClassGlobal
+}
+
+// ============================================================================
+//  Registry
+// ============================================================================
+
+// entry is an entry of the special-purpose address registry in CIDR notation.
+type entry struct {
+	cidr  string
+	class Class
+}
+
+// block is the parsed entry.
+type block struct {
+	network *net.IPNet
+	class   Class
+}
+
+// Special-purpose blocks of IPv4. The more specific ones come first.
+var registryIPv4 = newRegistry([]entry{
+	{"0.0.0.0/32", ClassUnspecified},
+	{"0.0.0.0/8", ClassReserved}, // "this network"
+	{"10.0.0.0/8", ClassPrivate},
+	{"100.64.0.0/10", ClassCGNAT},
+	{"127.0.0.0/8", ClassLoopback},
+	{"169.254.0.0/16", ClassLinkLocal},
+	{"172.16.0.0/12", ClassPrivate},
+	{"192.0.0.9/32", ClassGlobal},   // Port Control Protocol anycast
+	{"192.0.0.10/32", ClassGlobal},  // Traversal Using Relays around NAT anycast
+	{"192.0.0.0/24", ClassReserved}, // IETF protocol assignments
+	{"192.0.2.0/24", ClassDocumentation},
+	{"192.88.99.0/24", ClassReserved}, // deprecated 6to4 relay anycast
+	{"192.168.0.0/16", ClassPrivate},
+	{"198.18.0.0/15", ClassBenchmark},
+	{"198.51.100.0/24", ClassDocumentation},
+	{"203.0.113.0/24", ClassDocumentation},
+	{"224.0.0.0/4", ClassMulticast},
+	{"240.0.0.0/4", ClassReserved}, // including the limited broadcast
+})
+
+// Special-purpose blocks of IPv6. The more specific ones come first. The
+// IPv4-mapped addresses are classified as IPv4.
+var registryIPv6 = newRegistry([]entry{
+	{"::/128", ClassUnspecified},
+	{"::1/128", ClassLoopback},
+	{"64:ff9b::/96", ClassGlobal},  // NAT64 well-known prefix
+	{"2001:1::1/128", ClassGlobal}, // Port Control Protocol anycast
+	{"2001:1::2/128", ClassGlobal}, // Traversal Using Relays around NAT anycast
+	{"2001:2::/48", ClassBenchmark},
+	{"2001:3::/32", ClassGlobal},     // Automatic Multicast Tunneling
+	{"2001:4:112::/48", ClassGlobal}, // AS112-v6
+	{"2001:20::/28", ClassGlobal},    // ORCHIDv2
+	{"2001::/23", ClassReserved},     // IETF protocol assignments. Such as Teredo
+	{"2001:db8::/32", ClassDocumentation},
+	{"3fff::/20", ClassDocumentation},
+	{"fc00::/7", ClassPrivate},
+	{"fe80::/10", ClassLinkLocal},
+	{"ff00::/8", ClassMulticast},
+	{"2000::/3", ClassGlobal}, // global unicast. The others are reserved
+})
+
+// Returns the registry of the CIDR notations. It panics if malformed.
+func newRegistry(entries []entry) []block {
+	registry := make([]block, 0, len(entries))
+
+	for _, ent := range entries {
+		_, network, err := net.ParseCIDR(ent.cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		registry = append(registry, block{network: network, class: ent.class})
+	}
+
+	return registry
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Classify returns the class of the IP address. It returns ClassInvalid if the
+// ip is nil or malformed.
+func Classify(ip net.IP) Class {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		for _, blk := range registryIPv4 {
+			if blk.network.Contains(ipv4) {
+				return blk.class
+			}
+		}
+
+		return ClassGlobal
+	}
+
+	if len(ip) != net.IPv6len {
+		return ClassInvalid
+	}
+
+	for _, blk := range registryIPv6 {
+		if blk.network.Contains(ip) {
+			return blk.class
+		}
+	}
+
+	return ClassReserved
+}
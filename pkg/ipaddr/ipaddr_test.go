@@ -0,0 +1,103 @@
+package ipaddr_test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/ipaddr"
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleClassify() {
+	for _, ip := range []string{"123.123.123.123", "10.0.0.1", "100.64.1.1", "2001:db8::1", "::ffff:192.168.1.1"} {
+		fmt.Println(ip, ipaddr.Classify(net.ParseIP(ip)))
+	}
+
+	// Output:
+	// 123.123.123.123 global
+	// 10.0.0.1 private
+	// 100.64.1.1 cgnat
+	// 2001:db8::1 documentation
+	// ::ffff:192.168.1.1 private
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		ip     string
+		expect ipaddr.Class
+	}{
+		// IPv4
+		{"123.123.123.123", ipaddr.ClassGlobal},
+		{"8.8.8.8", ipaddr.ClassGlobal},
+		{"100.63.255.255", ipaddr.ClassGlobal},
+		{"100.128.0.0", ipaddr.ClassGlobal},
+		{"172.32.0.1", ipaddr.ClassGlobal},
+		{"0.0.0.0", ipaddr.ClassUnspecified},
+		{"0.1.2.3", ipaddr.ClassReserved},
+		{"10.0.0.1", ipaddr.ClassPrivate},
+		{"172.16.0.1", ipaddr.ClassPrivate},
+		{"172.31.255.255", ipaddr.ClassPrivate},
+		{"192.168.1.200", ipaddr.ClassPrivate},
+		{"100.64.0.1", ipaddr.ClassCGNAT},
+		{"100.127.255.255", ipaddr.ClassCGNAT},
+		{"127.0.0.1", ipaddr.ClassLoopback},
+		{"169.254.169.254", ipaddr.ClassLinkLocal},
+		{"192.0.0.8", ipaddr.ClassReserved},
+		{"192.0.0.9", ipaddr.ClassGlobal},
+		{"192.0.0.10", ipaddr.ClassGlobal},
+		{"192.0.0.11", ipaddr.ClassReserved},
+		{"192.0.2.1", ipaddr.ClassDocumentation},
+		{"198.51.100.1", ipaddr.ClassDocumentation},
+		{"203.0.113.1", ipaddr.ClassDocumentation},
+		{"192.88.99.1", ipaddr.ClassReserved},
+		{"198.18.0.1", ipaddr.ClassBenchmark},
+		{"198.19.255.255", ipaddr.ClassBenchmark},
+		{"224.0.0.1", ipaddr.ClassMulticast},
+		{"240.0.0.1", ipaddr.ClassReserved},
+		{"255.255.255.255", ipaddr.ClassReserved},
+		// IPv4-mapped IPv6
+		{"::ffff:123.123.123.123", ipaddr.ClassGlobal},
+		{"::ffff:10.0.0.1", ipaddr.ClassPrivate},
+		// IPv6
+		{"2001:4860:4860::8888", ipaddr.ClassGlobal},
+		{"2400:cb00::1", ipaddr.ClassGlobal},
+		{"::", ipaddr.ClassUnspecified},
+		{"::1", ipaddr.ClassLoopback},
+		{"2001:2::1", ipaddr.ClassBenchmark},
+		{"2001::1", ipaddr.ClassReserved},
+		{"2001:1::1", ipaddr.ClassGlobal},
+		{"2001:1::2", ipaddr.ClassGlobal},
+		{"2001:1::4", ipaddr.ClassReserved},
+		{"2001:3::1", ipaddr.ClassGlobal},
+		{"2001:4:112::1", ipaddr.ClassGlobal},
+		{"2001:4:113::1", ipaddr.ClassReserved},
+		{"2001:20::1", ipaddr.ClassGlobal},
+		{"2001:2f:ffff::1", ipaddr.ClassGlobal},
+		{"2001:10::1", ipaddr.ClassReserved},
+		{"2001:db8::1", ipaddr.ClassDocumentation},
+		{"3fff::1", ipaddr.ClassDocumentation},
+		{"fd00::1", ipaddr.ClassPrivate},
+		{"fe80::1", ipaddr.ClassLinkLocal},
+		{"ff02::1", ipaddr.ClassMulticast},
+		{"64:ff9b::7b7b:7b7b", ipaddr.ClassGlobal},
+		{"64:ff9b:1::1", ipaddr.ClassReserved},
+		{"100::1", ipaddr.ClassReserved},
+		{"4000::1", ipaddr.ClassReserved},
+	} {
+		ip := net.ParseIP(test.ip)
+
+		assert.NotNil(t, ip, "malformed test data: %v", test.ip)
+		assert.Equal(t, test.expect, ipaddr.Classify(ip), test.ip)
+		assert.Equal(t, test.expect == ipaddr.ClassGlobal, ipaddr.Classify(ip).IsGlobal(), test.ip)
+	}
+}
+
+func TestClassify_invalid(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, ipaddr.ClassInvalid, ipaddr.Classify(nil))
+	assert.Equal(t, ipaddr.ClassInvalid, ipaddr.Classify(net.IP{1, 2, 3}))
+}
@@ -0,0 +1,284 @@
+package ipaddr
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Patterns of the candidates of the IP addresses in a text. The candidates are
+// validated by Parse along with their boundaries.
+var (
+	rexCandidateIPv4 = regexp.MustCompile(`[0-9]{1,3}(?:\.[0-9]{1,3}){3}`)
+	rexCandidateIPv6 = regexp.MustCompile(`[0-9A-Fa-f:.]*:[0-9A-Fa-f:.]*`)
+)
+
+// found is the IP address found in the text at text[start:end].
+type found struct {
+	ip    net.IP
+	start int
+	end   int
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Extract returns the IP addresses found in the text, such as an HTML page, in
+// the order of appearance without duplicates. Both IPv4 and IPv6 (full,
+// compressed and with the IPv4 suffix) are found. The addresses are normalized
+// as Parse does.
+//
+// The address must be separated from the surrounding words. So "v1.2.3.4.5" or
+// "std::cout" is not an address.
+func Extract(text []byte) []net.IP {
+	var list []found
+
+	// IPv6 first, since its IPv4 suffix should not be found as IPv4
+	for _, loc := range rexCandidateIPv6.FindAllIndex(text, -1) {
+		start, end := trimCandidateIPv6(text, loc[0], loc[1])
+
+		if ip, err := Parse(string(text[start:end])); err == nil && isBoundary(text, start, end) {
+			list = append(list, found{ip: ip, start: start, end: end})
+		}
+	}
+
+	for _, loc := range rexCandidateIPv4.FindAllIndex(text, -1) {
+		start, end := loc[0], loc[1]
+
+		if isInside(start, list) || !isBoundary(text, start, end) ||
+			(end+1 < len(text) && text[end] == '.' && isDigit(text[end+1])) {
+			continue
+		}
+
+		if ip, err := Parse(string(text[start:end])); err == nil {
+			list = append(list, found{ip: ip, start: start, end: end})
+		}
+	}
+
+	// Sort by the position. The list is short, so insertion sort is enough
+	for i := 1; i < len(list); i++ {
+		for j := i; j > 0 && list[j].start < list[j-1].start; j-- {
+			list[j], list[j-1] = list[j-1], list[j]
+		}
+	}
+
+	result := make([]net.IP, 0, len(list))
+	seen := make(map[string]bool, len(list))
+
+	for _, item := range list {
+		if key := item.ip.String(); !seen[key] {
+			seen[key] = true
+
+			result = append(result, item.ip)
+		}
+	}
+
+	return result
+}
+
+// Normalize returns the IP address of Parse as a string. Such as "1.1.1.1" for
+// "001.001.001.001". It returns an empty string if the ipAddress is invalid.
+func Normalize(ipAddress string) string {
+	ip, err := Parse(ipAddress)
+	if err != nil {
+		return ""
+	}
+
+	return ip.String()
+}
+
+// Parse returns the IP address of the string in various notations. Such as:
+//
+//	192.168.1.200           // IPv4
+//	192.168.001.200         // zero padded IPv4. It is decimal, not octal
+//	192.168.1.200:8080      // IPv4 with port
+//	2001:db8::1             // IPv6
+//	::ffff:192.168.1.200    // IPv4-mapped IPv6. Returned as IPv4
+//	fe80::1%eth0            // IPv6 with zone. The zone is dropped
+//	[fe80::1%25eth0]:443    // bracketed IPv6 with zone and port
+//
+// The spaces around are ignored.
+func Parse(ipAddress string) (net.IP, error) {
+	host, ok := splitHost(strings.TrimSpace(ipAddress))
+	if !ok {
+		return nil, errors.Errorf("invalid IP address: %q", ipAddress)
+	}
+
+	var ip net.IP
+
+	if strings.Contains(host, ":") {
+		ip = parseIPv6(host)
+	} else {
+		ip = parseIPv4(host)
+	}
+
+	if ip == nil {
+		return nil, errors.Errorf("invalid IP address: %q", ipAddress)
+	}
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4, nil
+	}
+
+	return ip, nil
+}
+
+// Returns true if the byte is a digit.
+func isDigit(char byte) bool {
+	return '0' <= char && char <= '9'
+}
+
+// Returns true if the byte is a part of a word.
+func isWord(char byte) bool {
+	return isDigit(char) || char == '_' || ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z')
+}
+
+// Returns true if text[start:end] is not a part of a word or a longer dotted
+// numbers.
+func isBoundary(text []byte, start, end int) bool {
+	if start > 0 && (isWord(text[start-1]) || text[start-1] == '.') {
+		return false
+	}
+
+	return end >= len(text) || !isWord(text[end])
+}
+
+// Returns true if the position is inside the range of the found ones.
+func isInside(pos int, list []found) bool {
+	for _, item := range list {
+		if item.start <= pos && pos < item.end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the host part of the address by removing the brackets, the port and
+// the zone. It returns false if malformed.
+func splitHost(address string) (string, bool) {
+	switch {
+	case strings.HasPrefix(address, "["):
+		end := strings.Index(address, "]")
+		if end < 0 {
+			return "", false
+		}
+
+		if rest := address[end+1:]; rest != "" && !isPort(rest) {
+			return "", false
+		}
+
+		address = address[1:end]
+		if !strings.Contains(address, ":") {
+			return "", false
+		}
+	case strings.Count(address, ":") == 1:
+		// IPv4 with port
+		index := strings.Index(address, ":")
+		if !isPort(address[index:]) {
+			return "", false
+		}
+
+		address = address[:index]
+	}
+
+	// Zone of IPv6. Such as "%eth0" or URL encoded "%25eth0"
+	if index := strings.Index(address, "%"); index >= 0 {
+		if !strings.Contains(address, ":") || index == len(address)-1 {
+			return "", false
+		}
+
+		address = address[:index]
+	}
+
+	return address, address != ""
+}
+
+// Returns true if the string is a port with the colon. Such as ":443".
+func isPort(port string) bool {
+	if len(port) < 2 || port[0] != ':' {
+		return false
+	}
+
+	num, err := strconv.Atoi(port[1:])
+
+	return err == nil && num >= 0 && num <= 65535 && !strings.HasPrefix(port[1:], "+")
+}
+
+// Returns the IPv4 address of the dotted decimal notation. Each number may be
+// zero padded up to 3 digits. It returns nil if malformed.
+func parseIPv4(address string) net.IP {
+	const numParts = 4
+
+	parts := strings.Split(address, ".")
+	if len(parts) != numParts {
+		return nil
+	}
+
+	ip := make(net.IP, numParts)
+
+	for i, part := range parts {
+		if len(part) == 0 || len(part) > 3 {
+			return nil
+		}
+
+		for j := 0; j < len(part); j++ {
+			if !isDigit(part[j]) {
+				return nil
+			}
+		}
+
+		num, err := strconv.Atoi(part)
+		if err != nil || num > 255 {
+			return nil
+		}
+
+		ip[i] = byte(num)
+	}
+
+	return net.IPv4(ip[0], ip[1], ip[2], ip[3])
+}
+
+// Returns the IPv6 address. The IPv4 suffix may be zero padded. It returns nil
+// if malformed.
+func parseIPv6(address string) net.IP {
+	if index := strings.LastIndex(address, ":"); strings.Contains(address[index:], ".") {
+		ipv4 := parseIPv4(address[index+1:])
+		if ipv4 == nil {
+			return nil
+		}
+
+		address = address[:index+1] + ipv4.String()
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil || ip.To16() == nil {
+		return nil
+	}
+
+	return ip
+}
+
+// Returns the range of the IPv6 candidate without the colons and the dots which
+// are not a part of the address. Such as "IP:2001:db8::1." in a sentence.
+func trimCandidateIPv6(text []byte, start, end int) (int, int) {
+	for end > start && text[end-1] == '.' {
+		end--
+	}
+
+	// A single colon is a separator, while the double colon is a part of the
+	// address
+	if end-start > 1 && text[start] == ':' && text[start+1] != ':' {
+		start++
+	}
+
+	if end-start > 1 && text[end-1] == ':' && text[end-2] != ':' {
+		end--
+	}
+
+	return start, end
+}
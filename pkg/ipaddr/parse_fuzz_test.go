@@ -0,0 +1,76 @@
+//go:build go1.18
+// +build go1.18
+
+package ipaddr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/ipaddr"
+)
+
+// To run the fuzzing:
+//
+//	go test -fuzz=FuzzParse ./pkg/ipaddr/
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"123.123.123.123", "192.168.001.200", "1.1.1.1:80", "2001:db8::1", "::ffff:1.2.3.4",
+		"[fe80::1%25eth0]:443", "64:ff9b::001.002.003.004", "", "[", "1.1.1.1%", "::%",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		ip, err := ipaddr.Parse(input)
+		if err != nil {
+			if ip != nil {
+				t.Fatalf("non-nil IP with error. input: %q", input)
+			}
+
+			return
+		}
+
+		if ipaddr.Classify(ip) == ipaddr.ClassInvalid {
+			t.Fatalf("parsed IP is invalid. input: %q, ip: %v", input, ip)
+		}
+
+		// The normalized form should be stable
+		again, err := ipaddr.Parse(ip.String())
+		if err != nil || !again.Equal(ip) {
+			t.Fatalf("normalized form is not stable. input: %q, ip: %v, again: %v, err: %v", input, ip, again, err)
+		}
+	})
+}
+
+func FuzzExtract(f *testing.F) {
+	for _, seed := range []string{
+		"<b>192.168.001.200</b>", "IP:2001:db8::1.", "v1.2.3.4.5", "std::cout", "::ffff:1.2.3.4 1.2.3.4",
+		"[fe80::1%eth0]:443", "1.1.1.1::", ":::", "..1.1.1.1..",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, text string) {
+		seen := make(map[string]bool)
+
+		for _, ip := range ipaddr.Extract([]byte(text)) {
+			normalized := ip.String()
+
+			if seen[normalized] {
+				t.Fatalf("duplicate address. text: %q, ip: %v", text, ip)
+			}
+
+			seen[normalized] = true
+
+			if ipaddr.Normalize(normalized) != normalized {
+				t.Fatalf("extracted address is not normalized. text: %q, ip: %v", text, ip)
+			}
+
+			// IPv4 should be found as is in the text unless zero padded or mapped
+			if ip.To4() != nil && !strings.ContainsAny(text, "0:") && !strings.Contains(text, normalized) {
+				t.Fatalf("extracted address is not in the text. text: %q, ip: %v", text, ip)
+			}
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package ipaddr_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/ipaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleExtract() {
+	html := `<p>Your IP: <b>192.168.001.200</b></p><p>IPv6: [2001:db8::1]</p><p>v1.2.3.4.5</p>`
+
+	for _, ip := range ipaddr.Extract([]byte(html)) {
+		fmt.Println(ip)
+	}
+
+	// Output:
+	// 192.168.1.200
+	// 2001:db8::1
+}
+
+func ExampleNormalize() {
+	for _, input := range []string{"192.168.001.200", "::ffff:10.0.0.1", "[fe80::1%eth0]:443", "256.1.1.1"} {
+		fmt.Printf("%q\n", ipaddr.Normalize(input))
+	}
+
+	// Output:
+	// "192.168.1.200"
+	// "10.0.0.1"
+	// "fe80::1"
+	// ""
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		input  string
+		expect string
+	}{
+		// IPv4
+		{"123.123.123.123", "123.123.123.123"},
+		{" 123.123.123.123\n", "123.123.123.123"},
+		{"192.168.001.200", "192.168.1.200"}, // octets above 127 should not be 0
+		{"010.001.000.255", "10.1.0.255"},    // decimal, not octal
+		{"000.000.000.000", "0.0.0.0"},
+		{"123.123.123.123:8080", "123.123.123.123"},
+		// IPv6
+		{"2001:db8::1", "2001:db8::1"},
+		{"2001:0DB8:0000:0000:0000:0000:0000:0001", "2001:db8::1"},
+		{"::1", "::1"},
+		{"::", "::"},
+		{"fe80::1%eth0", "fe80::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"[fe80::1%25eth0]:443", "fe80::1"},
+		{"64:ff9b::192.0.2.1", "64:ff9b::c000:201"},
+		{"2001:db8::001.002.003.004", "2001:db8::102:304"},
+		// IPv4-mapped IPv6
+		{"::ffff:192.168.1.200", "192.168.1.200"},
+		{"::ffff:192.168.001.200", "192.168.1.200"},
+		{"[::ffff:c0a8:1c8]", "192.168.1.200"},
+	} {
+		ip, err := ipaddr.Parse(test.input)
+
+		require.NoError(t, err, "input: %q", test.input)
+		assert.Equal(t, test.expect, ip.String(), "input: %q", test.input)
+		assert.Equal(t, test.expect, ipaddr.Normalize(test.input), "input: %q", test.input)
+	}
+}
+
+func TestParse_ipv4_length(t *testing.T) {
+	t.Parallel()
+
+	ip, err := ipaddr.Parse("::ffff:192.168.1.200")
+
+	require.NoError(t, err)
+	assert.Len(t, ip, 4, "IPv4 should be returned in 4 bytes")
+}
+
+func TestParse_invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{
+		"",
+		"   ",
+		"foo",
+		"256.1.1.1",
+		"1.2.3",
+		"1.2.3.4.5",
+		"1..2.3",
+		"0001.1.1.1",
+		"+1.1.1.1",
+		"1.1.1.1:",
+		"1.1.1.1:port",
+		"1.1.1.1:65536",
+		"1.1.1.1%eth0",
+		"[1.1.1.1]",
+		"[2001:db8::1",
+		"[2001:db8::1]x",
+		"2001:db8::1%",
+		"2001:db8:::1",
+		"2001:db8::1::1",
+		"2001:db8::256.1.1.1",
+		"0x7f.0.0.1",
+	} {
+		ip, err := ipaddr.Parse(input)
+
+		require.Error(t, err, "input: %q", input)
+		assert.Nil(t, ip, "input: %q", input)
+		assert.Contains(t, err.Error(), "invalid IP address", "input: %q", input)
+		assert.Empty(t, ipaddr.Normalize(input), "input: %q", input)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		text   string
+		expect []string
+	}{
+		{"", []string{}},
+		{"no address here", []string{}},
+		{"123.123.123.123", []string{"123.123.123.123"}},
+		{`<input id="ip" value="192.168.001.200">`, []string{"192.168.1.200"}},
+		{"IP:123.123.123.123, IP:123.123.123.123", []string{"123.123.123.123"}},
+		{"1.1.1.1 2.2.2.2\n3.3.3.3", []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"}},
+		{"Address:2001:db8::1.", []string{"2001:db8::1"}},
+		{"<td>2001:db8::1</td><td>1.1.1.1</td>", []string{"2001:db8::1", "1.1.1.1"}},
+		{"1.1.1.1 then 2001:db8::1", []string{"1.1.1.1", "2001:db8::1"}},
+		{"[fe80::1%eth0]:443", []string{"fe80::1"}},
+		{"mapped ::ffff:192.168.1.200", []string{"192.168.1.200"}},
+		{"mixed 64:ff9b::192.0.2.1", []string{"64:ff9b::c000:201"}},
+		// Not the addresses
+		{"version v1.2.3.4 and 1.2.3.4.5", []string{}},
+		{"std::cout << x1::2", []string{}},
+		{"00:1a:2b:3c:4d:5e at 12:30:45", []string{}},
+		{"999.1.1.1 1.1.1.1999", []string{}},
+	} {
+		list := ipaddr.Extract([]byte(test.text))
+
+		actual := make([]string, 0, len(list))
+		for _, ip := range list {
+			actual = append(actual, ip.String())
+		}
+
+		assert.Equal(t, test.expect, actual, "text: %q", test.text)
+	}
+}
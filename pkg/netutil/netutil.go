@@ -7,24 +7,85 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/KEINOS/whereami/pkg/info"
 	"github.com/pkg/errors"
 )
 
 // httpNewRequestWithContext is a copy of http.NewRequestWithContext to ease testing.
 var httpNewRequestWithContext = http.NewRequestWithContext
 
+// ctxKeyClient is the key type to store the HTTP client in the context.
+type ctxKeyClient struct{}
+
+// ----------------------------------------------------------------------------
+//  Functions
+// ----------------------------------------------------------------------------
+
+// ClientFromContext returns the HTTP client stored in the ctx via WithClient.
+// If none, it returns http.DefaultClient.
+func ClientFromContext(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(ctxKeyClient{}).(*http.Client); ok && client != nil {
+		return client
+	}
+
+	return http.DefaultClient
+}
+
 // HTTPGet is a wrappper of http.Get with context.
 func HTTPGet(url string) (*http.Response, error) {
+	return HTTPGetContext(context.Background(), url)
+}
+
+// HTTPGetContext is similar to HTTPGet but with the given context. The request
+// uses the HTTP client stored in the ctx via WithClient if any.
+//
+// The status of the response is logged in debug level to the logger stored in
+// the ctx via info.NewContext if any.
+func HTTPGetContext(ctx context.Context, url string) (*http.Response, error) {
 	body := strings.NewReader("")
 
-	request, err := httpNewRequestWithContext(context.Background(), http.MethodGet, url, body)
+	request, err := httpNewRequestWithContext(ctx, http.MethodGet, url, body)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create HTTP request")
 	}
 
-	resp, err := http.DefaultClient.Do(request)
+	timeStart := time.Now()
+
+	resp, err := ClientFromContext(ctx).Do(request)
 	defer request.Body.Close()
 
+	if err == nil {
+		info.FromContext(ctx).Debug("response status",
+			"url", url,
+			"status", resp.StatusCode,
+			"duration", time.Since(timeStart),
+		)
+	}
+
 	return resp, errors.Wrap(err, "failed to do HTTP request")
 }
+
+// Sleep sleeps for the duration. It returns false if the ctx is done before.
+// Such as to wait between the requests without blocking the cancellation.
+func Sleep(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// WithClient returns a copy of ctx which holds the given HTTP client. The
+// client will be used by HTTPGetContext.
+//
+// This allows to use a custom client (timeouts, proxies, etc.) per request
+// without changing the providers.
+func WithClient(ctx context.Context, client *http.Client) context.Context {
+	return context.WithValue(ctx, ctxKeyClient{}, client)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
@@ -66,3 +67,62 @@ func TestHTTPGet_failed_to_do_request(t *testing.T) {
 	require.Contains(t, err.Error(), "failed to do HTTP request", "it should contain the error reason")
 	require.Nil(t, resp, "returned response should be nil on error")
 }
+
+func TestHTTPGetContext_custom_client(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, err := w.Write([]byte(req.Header.Get("X-Dummy"))); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer dummySrv.Close()
+
+	// Custom client which adds a header to the request
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Dummy", "via custom client")
+
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}
+
+	ctx := WithClient(context.Background(), client)
+
+	resp, err := HTTPGetContext(ctx, dummySrv.URL)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Equal(t, "via custom client", string(body), "it should use the client in the context")
+}
+
+func TestClientFromContext_default(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, http.DefaultClient, ClientFromContext(context.Background()),
+		"it should return the default client if not set")
+	require.Equal(t, http.DefaultClient, ClientFromContext(WithClient(context.Background(), nil)),
+		"it should return the default client if nil is set")
+}
+
+// roundTripperFunc is a function which implements http.RoundTripper.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestSleep(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, Sleep(context.Background(), time.Millisecond), "it should return true after the duration")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.False(t, Sleep(ctx, time.Hour), "it should return false if the ctx is done before")
+}
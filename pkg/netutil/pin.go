@@ -0,0 +1,76 @@
+package netutil
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// ParsePin returns the SPKI pin of RFC 7469. Which is the base64 encoded
+// SHA-256 of the public key, with or without the "sha256/" prefix.
+func ParsePin(pin string) (string, error) {
+	pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+
+	hash, err := base64.StdEncoding.DecodeString(pin)
+	if err != nil || len(hash) != sha256.Size {
+		return "", errors.Errorf("malformed pin: %v (expected: base64 SHA-256 of the public key)", pin)
+	}
+
+	return pin, nil
+}
+
+// ParsePins returns the pins of the comma separated list. Such as
+// "<base64>,sha256/<base64>". An empty string returns no pin. See ParsePin.
+func ParsePins(list string) ([]string, error) {
+	var pins []string
+
+	for _, pin := range strings.Split(list, ",") {
+		if strings.TrimSpace(pin) == "" {
+			continue
+		}
+
+		parsed, err := ParsePin(pin)
+		if err != nil {
+			return nil, err
+		}
+
+		pins = append(pins, parsed)
+	}
+
+	return pins, nil
+}
+
+// PinOf returns the SPKI pin of the public key in the certificate.
+func PinOf(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// MatchPins returns true if any certificate in the verified chains matches any
+// of the pins. Such as tls.ConnectionState.VerifiedChains.
+//
+// The certificates sent by the server but not in the chains are never checked,
+// since anyone can send the pinned one along.
+func MatchPins(chains [][]*x509.Certificate, pins []string) bool {
+	for _, chain := range chains {
+		for _, cert := range chain {
+			pin := PinOf(cert)
+
+			for _, want := range pins {
+				if pin == want {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,51 @@
+package netutil
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePins(t *testing.T) {
+	t.Parallel()
+
+	hash := sha256.Sum256([]byte("dummy"))
+	pin := base64.StdEncoding.EncodeToString(hash[:])
+
+	pins, err := ParsePins(" sha256/" + pin + ", " + pin + ",")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{pin, pin}, pins)
+
+	pins, err = ParsePins("")
+
+	require.NoError(t, err)
+	assert.Empty(t, pins)
+
+	for _, input := range []string{"bm90IGEgaGFzaA==", "not-base64", "sha256/YWJj"} {
+		pins, err := ParsePins(input)
+
+		require.Error(t, err, "input: %v", input)
+		assert.Nil(t, pins)
+		assert.Contains(t, err.Error(), "malformed pin:", "input: %v", input)
+	}
+}
+
+func TestMatchPins(t *testing.T) {
+	t.Parallel()
+
+	leaf := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("leaf")}
+	root := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("root")}
+	other := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("other")}
+
+	chains := [][]*x509.Certificate{{leaf, root}}
+
+	assert.True(t, MatchPins(chains, []string{PinOf(root)}), "any certificate in the chain should match")
+	assert.True(t, MatchPins(chains, []string{PinOf(other), PinOf(leaf)}), "any of the pins should match")
+	assert.False(t, MatchPins(chains, []string{PinOf(other)}), "the certificate not in the chains should not match")
+	assert.False(t, MatchPins(nil, []string{PinOf(leaf)}), "no verified chain should not match")
+}
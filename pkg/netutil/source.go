@@ -0,0 +1,129 @@
+package netutil
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// netInterfaces is a copy of net.Interfaces to ease mock its behavior during
+// test.
+var netInterfaces = net.Interfaces
+
+// ============================================================================
+//  Type: Interface
+// ============================================================================
+
+// Interface is a network interface which is up, with the address to use as the
+// source address of the outgoing connections.
+type Interface struct {
+	// Name is the name of the interface. Such as "eth0".
+	Name string `json:"name"`
+	// Addr is the address of the interface to bind. See InterfaceAddr.
+	Addr net.IP `json:"addr"`
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// InterfaceAddr returns the address of the network interface to use as the
+// source address. The IPv4 address is preferred over the IPv6 one, and the
+// link-local and loopback ones come last.
+func InterfaceAddr(name string) (net.IP, error) {
+	ifaces, err := netInterfaces()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the network interfaces")
+	}
+
+	for _, iface := range ifaces {
+		if iface.Name != name {
+			continue
+		}
+
+		if iface.Flags&net.FlagUp == 0 {
+			return nil, errors.Errorf("network interface %v is down", name)
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the addresses of %v", name)
+		}
+
+		if source := sourceOf(addrs); source != nil {
+			return source, nil
+		}
+
+		return nil, errors.Errorf("network interface %v has no address to use as the source", name)
+	}
+
+	return nil, errors.Errorf("network interface not found: %v", name)
+}
+
+// Interfaces returns the network interfaces which are up and have an address
+// to use as the source. The loopback interfaces are excluded.
+func Interfaces() ([]Interface, error) {
+	ifaces, err := netInterfaces()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the network interfaces")
+	}
+
+	var list []Interface
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue // such as the interface removed meanwhile
+		}
+
+		if source := sourceOf(addrs); source != nil {
+			list = append(list, Interface{Name: iface.Name, Addr: source})
+		}
+	}
+
+	return list, nil
+}
+
+// Returns the address to use as the source among addrs. Nil if none.
+func sourceOf(addrs []net.Addr) net.IP {
+	var (
+		source net.IP
+		rank   int
+	)
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		if r := rankSource(ipNet.IP); r > rank {
+			source, rank = ipNet.IP, r
+		}
+	}
+
+	return source
+}
+
+// Returns the preference of the ip as the source. The greater, the better. Zero
+// means unusable.
+func rankSource(ip net.IP) int {
+	switch {
+	case ip.IsUnspecified() || ip.IsMulticast():
+		return 0
+	case ip.IsLoopback():
+		return 1
+	case ip.IsLinkLocalUnicast() && ip.To4() == nil:
+		return 0 // needs the zone to bind
+	case ip.IsLinkLocalUnicast():
+		return 2
+	case ip.To4() == nil:
+		return 3
+	default:
+		return 4
+	}
+}
@@ -0,0 +1,94 @@
+package netutil
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterfaceAddr(t *testing.T) {
+	t.Parallel()
+
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+
+		source, err := InterfaceAddr(iface.Name)
+
+		require.NoError(t, err)
+		assert.True(t, source.IsLoopback(), "got: %v", source)
+	}
+
+	source, err := InterfaceAddr("unknown0")
+
+	require.Error(t, err)
+	assert.Nil(t, source)
+	assert.Contains(t, err.Error(), "network interface not found: unknown0")
+}
+
+//nolint:paralleltest // do not parallelize due to mocking global function variables
+func TestInterfaces_error(t *testing.T) {
+	oldNetInterfaces := netInterfaces
+	defer func() {
+		netInterfaces = oldNetInterfaces
+	}()
+
+	netInterfaces = func() ([]net.Interface, error) {
+		return nil, errors.New("forced error")
+	}
+
+	list, err := Interfaces()
+
+	require.Error(t, err)
+	assert.Nil(t, list)
+	assert.Contains(t, err.Error(), "failed to get the network interfaces: forced error")
+
+	source, err := InterfaceAddr("eth0")
+
+	require.Error(t, err)
+	assert.Nil(t, source)
+
+	// Loopback only
+	netInterfaces = func() ([]net.Interface, error) {
+		return []net.Interface{{Index: 1, Name: "lo", Flags: net.FlagUp | net.FlagLoopback}}, nil
+	}
+
+	list, err = Interfaces()
+
+	require.NoError(t, err)
+	assert.Empty(t, list, "loopback should be excluded")
+}
+
+func Test_sourceOf(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		expect string
+		addrs  []string
+	}{
+		{expect: "192.0.2.10", addrs: []string{"fe80::1/64", "2001:db8::10/64", "192.0.2.10/24"}},
+		{expect: "2001:db8::10", addrs: []string{"fe80::1/64", "2001:db8::10/64", "127.0.0.1/8"}},
+		{expect: "169.254.1.1", addrs: []string{"169.254.1.1/16", "127.0.0.1/8"}},
+		{expect: "127.0.0.1", addrs: []string{"fe80::1/64", "127.0.0.1/8"}},
+		{expect: "<nil>", addrs: []string{"fe80::1/64"}},
+	} {
+		addrs := make([]net.Addr, 0, len(test.addrs))
+
+		for _, cidr := range test.addrs {
+			ip, ipNet, err := net.ParseCIDR(cidr)
+			require.NoError(t, err)
+
+			ipNet.IP = ip
+			addrs = append(addrs, ipNet)
+		}
+
+		assert.Equal(t, test.expect, sourceOf(addrs).String(), "addrs: %v", test.addrs)
+	}
+}
@@ -0,0 +1,373 @@
+/*
+Package portal detects the networks which should not be trusted to tell the
+global/public IP address. Such as a captive portal of the hotel Wi-Fi, which
+responds its login page to any HTTP request, or a proxy which intercepts the
+HTTPS connections with its own certificate.
+
+It requests a "generate_204" style probe over HTTP, which must respond "204 No
+Content", and a probe over HTTPS, whose certificate chain must be verified by
+the expected root CAs. Since an intercepting proxy may have its CA installed in
+the system, such as the one of a corporate network or a security software, the
+public keys expected in the chain can be pinned as well.
+
+	result := portal.New().Detect(ctx)
+	if err := result.Err(); err != nil {
+		// behind a captive portal or HTTPS is intercepted
+	}
+*/
+package portal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ProbeURLDefault is the default URL of the HTTP probe. It responds "204 No
+	// Content" if the network is open.
+	ProbeURLDefault = "http://connectivitycheck.gstatic.com/generate_204"
+	// TLSProbeURLDefault is the default URL of the HTTPS probe.
+	TLSProbeURLDefault = "https://www.gstatic.com/generate_204"
+	// TimeoutDefault is the default timeout of each probe.
+	TimeoutDefault = 5 * time.Second
+	// Max size of the response body to read. The portal page is just counted.
+	bodySizeMax = 64 << 10
+)
+
+var (
+	// ErrCaptivePortal is the error when the HTTP probe did not respond as
+	// expected. Such as redirected to the login page of a captive portal.
+	ErrCaptivePortal = errors.New("you are behind a captive portal")
+	// ErrIntercepted is the error when the certificate of the HTTPS probe is not
+	// the expected one. Such as a proxy or a security software intercepts HTTPS.
+	ErrIntercepted = errors.New("HTTPS is being intercepted")
+)
+
+// ============================================================================
+//  Type: Status
+// ============================================================================
+
+// Status is the status of the network detected.
+type Status string
+
+// Statuses of the network.
+const (
+	// StatusOpen means the probes responded as expected.
+	StatusOpen Status = "open"
+	// StatusCaptivePortal means the HTTP probe responded something else.
+	StatusCaptivePortal Status = "captive_portal"
+	// StatusIntercepted means the certificate of the HTTPS probe was not
+	// verified.
+	StatusIntercepted Status = "tls_intercepted"
+	// StatusUnknown means the probes could not be reached or were disabled. The
+	// network is not regarded as untrusted.
+	StatusUnknown Status = "unknown"
+)
+
+// ============================================================================
+//  Type: Detector
+// ============================================================================
+
+// Detector holds the settings of the probes. The URLs are configurable to probe
+// the local servers for testing.
+type Detector struct {
+	// Client is the HTTP client to request the probes. Its TLS config is the
+	// expectation of the certificate chain. If nil, a client which verifies the
+	// chain by RootCAs is used.
+	Client *http.Client
+	// RootCAs are the expected root CAs of the HTTPS probe, when Client is nil.
+	// If nil, the root CAs of the system are used.
+	RootCAs *x509.CertPool
+	// Pins are the expected public keys of the HTTPS probe. Each pin is the
+	// base64 encoded SHA-256 hash of the SubjectPublicKeyInfo. One of the
+	// certificates in the verified chain must match one of them. If empty, any
+	// chain verified by the root CAs is expected. See ParsePins.
+	Pins []string
+	// ProbeURL is the URL of the HTTP probe. Empty disables the probe.
+	ProbeURL string
+	// TLSProbeURL is the URL of the HTTPS probe. Empty disables the probe.
+	TLSProbeURL string
+	// Timeout is the timeout of each probe.
+	Timeout time.Duration
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// New returns a new Detector with the default probes.
+func New() *Detector {
+	return &Detector{
+		ProbeURL:    ProbeURLDefault,
+		TLSProbeURL: TLSProbeURLDefault,
+		Timeout:     TimeoutDefault,
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Detector
+// ----------------------------------------------------------------------------
+
+// Detect requests the probes and returns the status of the network. The HTTP
+// probe comes first since the captive portal also breaks the HTTPS.
+//
+// The result of each probe is logged in debug level to the logger stored in the
+// ctx via info.NewContext if any.
+func (d *Detector) Detect(ctx context.Context) Result {
+	var details []string
+
+	status := StatusUnknown
+
+	for _, probe := range []struct {
+		run func(ctx context.Context, client *http.Client) Result
+		url string
+	}{
+		{url: d.ProbeURL, run: d.probeHTTP},
+		{url: d.TLSProbeURL, run: d.probeTLS},
+	} {
+		if probe.url == "" {
+			continue
+		}
+
+		result := d.runProbe(ctx, probe.run)
+
+		info.FromContext(ctx).Debug("probe result",
+			"url", probe.url,
+			"status", string(result.Status),
+			"detail", result.Detail,
+		)
+
+		if result.Status == StatusCaptivePortal || result.Status == StatusIntercepted {
+			return result
+		}
+
+		if result.Status == StatusOpen {
+			status = StatusOpen
+		}
+
+		details = append(details, result.Detail)
+	}
+
+	if len(details) == 0 {
+		details = append(details, "no probe to request")
+	}
+
+	return Result{Status: status, Detail: strings.Join(details, "; ")}
+}
+
+// Returns the client to request the probes. It does not follow the redirects
+// to tell the captive portal.
+func (d *Detector) client() *http.Client {
+	var client http.Client
+
+	if d.Client != nil {
+		client = *d.Client
+	} else {
+		transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // it is always
+		transport.TLSClientConfig = &tls.Config{RootCAs: d.RootCAs, MinVersion: tls.VersionTLS12}
+		client.Transport = transport
+	}
+
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &client
+}
+
+// Requests the HTTP probe. It should respond 204, or 200 with an empty body.
+func (d *Detector) probeHTTP(ctx context.Context, client *http.Client) Result {
+	resp, size, err := get(ctx, client, d.ProbeURL)
+	if err != nil {
+		return Result{Status: StatusUnknown, Detail: "failed to request the probe: " + err.Error()}
+	}
+
+	switch location := resp.Header.Get("Location"); {
+	case resp.StatusCode == http.StatusNoContent, resp.StatusCode == http.StatusOK && size == 0:
+		return Result{Status: StatusOpen, Detail: d.ProbeURL + " responded " + resp.Status}
+	case location != "":
+		return Result{
+			Status:   StatusCaptivePortal,
+			Detail:   fmt.Sprintf("%v redirected to %v (%v)", d.ProbeURL, location, resp.Status),
+			Location: location,
+		}
+	default:
+		return Result{
+			Status: StatusCaptivePortal,
+			Detail: fmt.Sprintf("%v responded %v with %v bytes instead of 204 No Content", d.ProbeURL, resp.Status, size),
+		}
+	}
+}
+
+// Requests the HTTPS probe. Its certificate chain should be verified.
+func (d *Detector) probeTLS(ctx context.Context, client *http.Client) Result {
+	var (
+		errAuthority x509.UnknownAuthorityError
+		errHostname  x509.HostnameError
+		errInvalid   x509.CertificateInvalidError
+	)
+
+	resp, _, err := get(ctx, client, d.TLSProbeURL)
+
+	switch {
+	case errors.As(err, &errAuthority):
+		return newIntercepted(d.TLSProbeURL, errAuthority.Cert, err)
+	case errors.As(err, &errHostname):
+		return newIntercepted(d.TLSProbeURL, errHostname.Certificate, err)
+	case errors.As(err, &errInvalid):
+		return newIntercepted(d.TLSProbeURL, errInvalid.Cert, err)
+	case err != nil:
+		return Result{Status: StatusUnknown, Detail: "failed to request the probe: " + err.Error()}
+	case resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0:
+		return Result{Status: StatusUnknown, Detail: d.TLSProbeURL + " is not HTTPS"}
+	case len(d.Pins) > 0 && !netutil.MatchPins(resp.TLS.VerifiedChains, d.Pins):
+		// Verified by a CA trusted but not expected. Such as the one installed
+		// by the intercepting proxy.
+		issuer := resp.TLS.PeerCertificates[0].Issuer.String()
+
+		return Result{
+			Status: StatusIntercepted,
+			Detail: fmt.Sprintf("certificate of %v does not match the pins (issuer %q)", d.TLSProbeURL, issuer),
+			Issuer: issuer,
+		}
+	}
+
+	return Result{
+		Status: StatusOpen,
+		Detail: fmt.Sprintf("certificate of %v verified, issuer %q", d.TLSProbeURL,
+			resp.TLS.PeerCertificates[0].Issuer.String()),
+	}
+}
+
+// Runs the probe with the timeout.
+func (d *Detector) runProbe(
+	ctx context.Context, probe func(ctx context.Context, client *http.Client) Result,
+) Result {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	return probe(ctx, d.client())
+}
+
+// ============================================================================
+//  Type: Result
+// ============================================================================
+
+// Result is the status of the network detected.
+type Result struct {
+	// Status is the status of the network. Such as StatusCaptivePortal.
+	Status Status `json:"status"`
+	// Detail is the human readable detail of the status.
+	Detail string `json:"detail"`
+	// Location is the URL which the captive portal redirected to, if any.
+	Location string `json:"location,omitempty"`
+	// Issuer is the issuer of the unexpected certificate, if intercepted.
+	Issuer string `json:"issuer,omitempty"`
+}
+
+// Err returns an UntrustedError if the network is behind a captive portal or
+// HTTPS is intercepted. Otherwise nil.
+func (r Result) Err() error {
+	if r.Status != StatusCaptivePortal && r.Status != StatusIntercepted {
+		return nil
+	}
+
+	return &UntrustedError{Result: r}
+}
+
+// ============================================================================
+//  Type: UntrustedError
+// ============================================================================
+
+// UntrustedError is the error of the network which should not be trusted. It
+// matches ErrCaptivePortal or ErrIntercepted with errors.Is by the status.
+type UntrustedError struct {
+	Result Result
+}
+
+// Error implements the error interface.
+func (e *UntrustedError) Error() string {
+	return e.sentinel().Error() + ": " + e.Result.Detail
+}
+
+// Is returns true if the target is the sentinel error of the status.
+func (e *UntrustedError) Is(target error) bool {
+	return target == e.sentinel() //nolint:errorlint,goerr113 // compare the sentinel as is
+}
+
+// Returns the sentinel error of the status.
+func (e *UntrustedError) sentinel() error {
+	if e.Result.Status == StatusIntercepted {
+		return ErrIntercepted
+	}
+
+	return ErrCaptivePortal
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Requests the url and returns the response with the size of the body read.
+func get(ctx context.Context, client *http.Client, url string) (*http.Response, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to create HTTP request")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to do HTTP request")
+	}
+
+	defer resp.Body.Close()
+
+	size, err := io.Copy(io.Discard, io.LimitReader(resp.Body, bodySizeMax))
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to read response body")
+	}
+
+	return resp, size, nil
+}
+
+// Returns the result of the intercepted HTTPS with the issuer of the cert.
+func newIntercepted(url string, cert *x509.Certificate, err error) Result {
+	result := Result{
+		Status: StatusIntercepted,
+		Detail: "certificate of " + url + " is not trusted: " + rootCause(err).Error(),
+	}
+
+	if cert != nil {
+		result.Issuer = cert.Issuer.String()
+		result.Detail += fmt.Sprintf(" (issuer %q)", result.Issuer)
+	}
+
+	return result
+}
+
+// Returns the innermost error of the url.Error chain. Which tells the reason
+// without the request details.
+func rootCause(err error) error {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return err
+		}
+
+		err = next
+	}
+}
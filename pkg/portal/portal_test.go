@@ -0,0 +1,196 @@
+package portal_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/portal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetector_Detect_open(t *testing.T) {
+	t.Parallel()
+
+	srvHTTP := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srvHTTP.Close()
+
+	srvTLS := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srvTLS.Close()
+
+	det := newDetector(srvHTTP.URL, srvTLS.URL)
+	det.RootCAs = srvTLS.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs //nolint:forcetypeassert // test
+
+	result := det.Detect(context.Background())
+
+	assert.Equal(t, portal.StatusOpen, result.Status, result.Detail)
+	assert.Contains(t, result.Detail, "204 No Content")
+	assert.Contains(t, result.Detail, "verified")
+	assert.NoError(t, result.Err())
+}
+
+func TestDetector_Detect_captive_portal_redirect(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "http://192.168.1.1/login", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	result := newDetector(srv.URL, "").Detect(context.Background())
+
+	assert.Equal(t, portal.StatusCaptivePortal, result.Status)
+	assert.Equal(t, "http://192.168.1.1/login", result.Location)
+	assert.Contains(t, result.Detail, "redirected to http://192.168.1.1/login")
+
+	err := result.Err()
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, portal.ErrCaptivePortal)
+	assert.NotErrorIs(t, err, portal.ErrIntercepted)
+	assert.Contains(t, err.Error(), "you are behind a captive portal: ")
+}
+
+func TestDetector_Detect_captive_portal_page(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "<html><body>Please log in. Your IP address: 10.0.0.12</body></html>")
+	}))
+	defer srv.Close()
+
+	// The HTTPS probe should not be requested once the portal is detected
+	srvTLS := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		t.Error("the HTTPS probe should not be requested")
+	}))
+	defer srvTLS.Close()
+
+	result := newDetector(srv.URL, srvTLS.URL).Detect(context.Background())
+
+	assert.Equal(t, portal.StatusCaptivePortal, result.Status)
+	assert.Contains(t, result.Detail, "responded 200 OK with 67 bytes instead of 204 No Content")
+	assert.Empty(t, result.Location)
+}
+
+func TestDetector_Detect_intercepted(t *testing.T) {
+	t.Parallel()
+
+	srvHTTP := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srvHTTP.Close()
+
+	// The certificate of the test server is not signed by the system roots. As
+	// a proxy with its own CA does.
+	srvTLS := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srvTLS.Close()
+
+	result := newDetector(srvHTTP.URL, srvTLS.URL).Detect(context.Background())
+
+	assert.Equal(t, portal.StatusIntercepted, result.Status, result.Detail)
+	assert.Contains(t, result.Detail, "is not trusted: x509: ")
+	assert.Equal(t, "O=Acme Co", result.Issuer)
+
+	err := result.Err()
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, portal.ErrIntercepted)
+	assert.NotErrorIs(t, err, portal.ErrCaptivePortal)
+
+	var errUntrusted *portal.UntrustedError
+
+	require.ErrorAs(t, err, &errUntrusted)
+	assert.Equal(t, portal.StatusIntercepted, errUntrusted.Result.Status)
+}
+
+func TestDetector_Detect_intercepted_by_trusted_ca(t *testing.T) {
+	t.Parallel()
+
+	// The CA of the test server is trusted. As the one installed in the system
+	// by a corporate proxy or a security software.
+	srvTLS := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srvTLS.Close()
+
+	det := newDetector("", srvTLS.URL)
+	det.RootCAs = srvTLS.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs //nolint:forcetypeassert // test
+
+	// Pinned to the public key of the genuine probe server
+	hash := sha256.Sum256([]byte("public key of the genuine probe server"))
+	det.Pins = []string{base64.StdEncoding.EncodeToString(hash[:])}
+
+	result := det.Detect(context.Background())
+
+	assert.Equal(t, portal.StatusIntercepted, result.Status, result.Detail)
+	assert.Contains(t, result.Detail, "does not match the pins")
+	assert.Equal(t, "O=Acme Co", result.Issuer)
+	assert.ErrorIs(t, result.Err(), portal.ErrIntercepted)
+
+	// Pinned to the public key of the test server
+	hash = sha256.Sum256(srvTLS.Certificate().RawSubjectPublicKeyInfo)
+	det.Pins = []string{base64.StdEncoding.EncodeToString(hash[:])}
+
+	result = det.Detect(context.Background())
+
+	assert.Equal(t, portal.StatusOpen, result.Status, result.Detail)
+}
+
+func TestDetector_Detect_unreachable(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	urlClosed := srv.URL
+
+	srv.Close()
+
+	result := newDetector(urlClosed, "").Detect(context.Background())
+
+	assert.Equal(t, portal.StatusUnknown, result.Status, "unreachable probe should not be a portal")
+	assert.Contains(t, result.Detail, "failed to request the probe")
+	assert.NoError(t, result.Err())
+}
+
+func TestDetector_Detect_no_probe(t *testing.T) {
+	t.Parallel()
+
+	result := newDetector("", "").Detect(context.Background())
+
+	assert.Equal(t, portal.StatusUnknown, result.Status)
+	assert.Equal(t, "no probe to request", result.Detail)
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	det := portal.New()
+
+	assert.Equal(t, portal.ProbeURLDefault, det.ProbeURL)
+	assert.Equal(t, portal.TLSProbeURLDefault, det.TLSProbeURL)
+	assert.Equal(t, portal.TimeoutDefault, det.Timeout)
+}
+
+// ----------------------------------------------------------------------------
+//  Helper functions
+// ----------------------------------------------------------------------------
+
+// Returns a new Detector of the given probe URLs.
+func newDetector(probeURL, tlsProbeURL string) *portal.Detector {
+	det := portal.New()
+
+	det.ProbeURL = probeURL
+	det.TLSProbeURL = tlsProbeURL
+
+	return det
+}
@@ -0,0 +1,95 @@
+/*
+Package base provides the types shared between the provider packages and the
+provider package. Such as the details of the IP address, the errors and the
+Pipeline of the HTTP based providers.
+
+It exists to avoid the import cycle, since the provider package imports all the
+provider packages. Use the aliases in the provider package instead.
+*/
+package base
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/KEINOS/whereami/pkg/ipaddr"
+)
+
+// ============================================================================
+//  Type: Details
+// ============================================================================
+
+// Details is the information about the IP address which the provider knows in
+// addition to the IP address itself. The unknown fields are zero values.
+type Details struct {
+	// IP is the global/public IP address detected.
+	IP net.IP `json:"ip"`
+	// Owner is the name of the organization which owns the IP address. Such as
+	// "Google LLC".
+	Owner string `json:"owner,omitempty"`
+	// ASN is the autonomous system number of the IP address. Such as 15169.
+	ASN int `json:"asn,omitempty"`
+	// IPs are the IP addresses of both families if the provider shows IPv4 and
+	// IPv6 at once. One per family and IP is one of them. Empty if only one is
+	// shown.
+	IPs []net.IP `json:"ips,omitempty"`
+}
+
+// ParseOrg parses the organization string in the form of "AS<number> <owner>"
+// into Details. Such as "AS15169 Google LLC". If it does not have the ASN, the
+// whole string is used as the owner.
+func ParseOrg(org string) Details {
+	org = strings.TrimSpace(org)
+
+	prefix := org
+	owner := ""
+
+	if index := strings.IndexAny(org, " \t"); index >= 0 {
+		prefix, owner = org[:index], strings.TrimSpace(org[index+1:])
+	}
+
+	if len(prefix) > 2 && strings.EqualFold(prefix[:2], "AS") {
+		if asn, err := strconv.Atoi(prefix[2:]); err == nil {
+			return Details{ASN: asn, Owner: owner}
+		}
+	}
+
+	return Details{Owner: org}
+}
+
+// ScrapeDetails returns the Details of the IP addresses found in the text, such
+// as the field of an HTML page which shows the IP address of the client. Both
+// IPv4 and IPv6 (full, compressed and with the IPv4 suffix) are found.
+//
+// IP is the first one found. IPs are the first ones of each family if the text
+// shows both. It returns the error of ErrParse with the body if not found.
+func ScrapeDetails(text, body []byte) (*Details, error) {
+	var ipv4, ipv6 net.IP
+
+	list := ipaddr.Extract(text)
+
+	for _, ip := range list {
+		switch {
+		case ip.To4() != nil && ipv4 == nil:
+			ipv4 = ip
+		case ip.To4() == nil && ipv6 == nil:
+			ipv6 = ip
+		}
+	}
+
+	if len(list) == 0 {
+		return nil, NewParseError(nil, "IP address not found in the page", body)
+	}
+
+	details := &Details{IP: list[0]}
+
+	if ipv4 != nil && ipv6 != nil {
+		details.IPs = []net.IP{ipv4, ipv6}
+		if details.IP.Equal(ipv6) {
+			details.IPs = []net.IP{ipv6, ipv4}
+		}
+	}
+
+	return details, nil
+}
@@ -0,0 +1,81 @@
+package base_test
+
+import (
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/provider/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOrg(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		input string
+		owner string
+		asn   int
+	}{
+		{input: "AS15169 Google LLC", asn: 15169, owner: "Google LLC"},
+		{input: " as2516  KDDI CORPORATION ", asn: 2516, owner: "KDDI CORPORATION"},
+		{input: "AS2516", asn: 2516, owner: ""},
+		{input: "Some Company", asn: 0, owner: "Some Company"},
+		{input: "ASX Company", asn: 0, owner: "ASX Company"},
+		{input: "", asn: 0, owner: ""},
+	} {
+		details := base.ParseOrg(test.input)
+
+		assert.Equal(t, test.asn, details.ASN, "input: %q", test.input)
+		assert.Equal(t, test.owner, details.Owner, "input: %q", test.input)
+	}
+}
+
+func TestScrapeDetails(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		text   string
+		expect string
+		ips    []string
+	}{
+		{text: "<b>123.123.123.123</b>", expect: "123.123.123.123"},
+		{text: "<b>123.123.123.123</b><b>111.111.111.111</b>", expect: "123.123.123.123"},
+		{text: "<b>2001:4860:4860:0000:0000:0000:0000:8888</b>", expect: "2001:4860:4860::8888"},
+		{text: "IPv6: 2001:4860:4860::8888", expect: "2001:4860:4860::8888"},
+		{text: "::ffff:123.123.123.123", expect: "123.123.123.123"},
+		{text: "64:ff9b::123.123.123.123", expect: "64:ff9b::7b7b:7b7b"},
+		{
+			text:   "IPv4: 123.123.123.123, IPv6: 2001:4860:4860::8888",
+			expect: "123.123.123.123",
+			ips:    []string{"123.123.123.123", "2001:4860:4860::8888"},
+		},
+		{
+			text:   "IPv6: 2001:4860:4860::8888, IPv4: 123.123.123.123, IPv4: 111.111.111.111",
+			expect: "2001:4860:4860::8888",
+			ips:    []string{"2001:4860:4860::8888", "123.123.123.123"},
+		},
+	} {
+		details, err := base.ScrapeDetails([]byte(test.text), nil)
+
+		require.NoError(t, err, "text: %q", test.text)
+		assert.Equal(t, test.expect, details.IP.String(), "text: %q", test.text)
+
+		ips := []string(nil)
+		for _, ip := range details.IPs {
+			ips = append(ips, ip.String())
+		}
+
+		assert.Equal(t, test.ips, ips, "text: %q", test.text)
+	}
+}
+
+func TestScrapeDetails_not_found(t *testing.T) {
+	t.Parallel()
+
+	details, err := base.ScrapeDetails([]byte("v1.2.3.4.5"), []byte("<p>v1.2.3.4.5</p>"))
+
+	require.Error(t, err)
+	assert.Nil(t, details)
+	assert.ErrorIs(t, err, base.ErrParse)
+	assert.Contains(t, err.Error(), "IP address not found in the page")
+}
@@ -0,0 +1,191 @@
+package base
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Errors of the providers. Use errors.Is to check the kind of the error. Such
+// as errors.Is(err, base.ErrTimeout).
+var (
+	// ErrTimeout is the error when the provider did not respond in time.
+	ErrTimeout = errors.New("provider did not respond in time")
+	// ErrRateLimited is the error when the provider refused the request due to
+	// the rate limit. Such as the HTTP status 429 Too Many Requests. Use
+	// RetryAfterOf to get the time to wait.
+	ErrRateLimited = errors.New("provider rate limited the request")
+	// ErrParse is the error when the response of the provider could not be
+	// parsed or did not contain a valid IP address.
+	ErrParse = errors.New("failed to parse the response of the provider")
+	// ErrHTTPStatus is the error when the provider responded with an unexpected
+	// HTTP status. Use errors.As with *HTTPStatusError to get the status code.
+	ErrHTTPStatus = errors.New("provider responded with an unexpected HTTP status")
+)
+
+// ============================================================================
+//  Type: HTTPStatusError
+// ============================================================================
+
+// HTTPStatusError is the error when the provider responded with a status other
+// than 200 OK. It matches ErrHTTPStatus with errors.Is, and ErrRateLimited as
+// well if the provider is rate limiting. See IsRateLimited.
+//
+//	var errStatus *base.HTTPStatusError
+//	if errors.As(err, &errStatus) {
+//		fmt.Println(errStatus.StatusCode, errStatus.RetryAfter)
+//	}
+type HTTPStatusError struct {
+	// URL is the URL requested.
+	URL string
+	// Status is the status line of the response. Such as "404 Not Found".
+	Status string
+	// ContentType is the Content-Type header of the response.
+	ContentType string
+	// Body is the response body.
+	Body string
+	// StatusCode is the HTTP status code of the response. Such as 404.
+	StatusCode int
+	// RetryAfter is the time to wait before the next request to the provider.
+	// Zero if the response did not tell. See ParseRetryAfter.
+	RetryAfter time.Duration
+}
+
+// NewHTTPStatusError returns a new HTTPStatusError of the response and its body
+// already read.
+func NewHTTPStatusError(url string, response *http.Response, body []byte) *HTTPStatusError {
+	return &HTTPStatusError{
+		URL:         url,
+		Status:      response.Status,
+		StatusCode:  response.StatusCode,
+		ContentType: response.Header.Get("Content-Type"),
+		Body:        string(body),
+		RetryAfter:  ParseRetryAfter(response.Header),
+	}
+}
+
+// Error implements the error interface. The body is summarized to not flood the
+// logs with the error pages.
+func (e *HTTPStatusError) Error() string {
+	msg := fmt.Sprintf("fail to GET response from: %v\nStatus: %v\nResponse body: %v",
+		e.URL, e.Status, summarizeBody(e.ContentType, e.Body))
+
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf("\nRetry after: %v", e.RetryAfter)
+	}
+
+	return msg
+}
+
+// Is returns true if the target is ErrHTTPStatus, or ErrRateLimited and the
+// provider is rate limiting.
+func (e *HTTPStatusError) Is(target error) bool {
+	//nolint:errorlint,goerr113 // compare the sentinels as is
+	switch target {
+	case ErrHTTPStatus:
+		return true
+	case ErrRateLimited:
+		return e.IsRateLimited()
+	}
+
+	return false
+}
+
+// IsRateLimited returns true if the status is 429 Too Many Requests, or 503
+// Service Unavailable with the time to retry. The latter is how some providers
+// tell that they are overloaded.
+func (e *HTTPStatusError) IsRateLimited() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		return e.RetryAfter > 0
+	}
+
+	return false
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// NewParseError returns the error of ErrParse with the message, the cause (if
+// not nil) and the summary of the body. Such as:
+//
+//	fail to parse JSON response (response body: {"ip":): unexpected end of JSON input
+func NewParseError(cause error, msg string, body []byte) error {
+	msg = fmt.Sprintf("%v (response body: %v)", msg, summarizeBody("", string(body)))
+
+	if cause == nil {
+		return Mark(errors.New(msg), ErrParse)
+	}
+
+	return Mark(errors.Wrap(cause, msg), ErrParse)
+}
+
+// ParseIP returns the IP address of the given string found in the body. It
+// returns the error of ErrParse if it is not a valid IP address. So a captive
+// portal page or an error message never becomes the IP address.
+func ParseIP(ipAddress string, body []byte) (net.IP, error) {
+	parsed := net.ParseIP(strings.TrimSpace(ipAddress))
+	if parsed == nil {
+		return nil, NewParseError(nil, fmt.Sprintf("invalid IP address in the response: %q", ipAddress), body)
+	}
+
+	return parsed, nil
+}
+
+// ============================================================================
+//  Type: kindError
+// ============================================================================
+
+// kindError is the error marked as the kind of ErrXxxx sentinels.
+type kindError struct {
+	cause error
+	kind  error
+}
+
+// Mark returns the err marked as the kind. Which errors.Is(err, kind) is true
+// while the message and the cause stay the same. Such as:
+//
+//	err = base.Mark(err, base.ErrParse)
+//
+// It returns nil if err is nil.
+func Mark(err error, kind error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &kindError{cause: err, kind: kind}
+}
+
+// Error implements the error interface.
+func (e *kindError) Error() string {
+	return e.cause.Error()
+}
+
+// Format implements fmt.Formatter to print the stack trace of the cause with
+// "%+v".
+func (e *kindError) Format(state fmt.State, verb rune) {
+	if verb == 'v' && state.Flag('+') {
+		fmt.Fprintf(state, "%+v", e.cause)
+
+		return
+	}
+
+	fmt.Fprint(state, e.cause.Error())
+}
+
+// Is returns true if the target is the kind marked.
+func (e *kindError) Is(target error) bool {
+	return target == e.kind //nolint:errorlint,goerr113 // compare the sentinels as is
+}
+
+// Unwrap returns the cause.
+func (e *kindError) Unwrap() error {
+	return e.cause
+}
@@ -0,0 +1,93 @@
+package base_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/provider/base"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPStatusError(t *testing.T) {
+	t.Parallel()
+
+	response := &http.Response{Status: "404 Not Found", StatusCode: http.StatusNotFound}
+	err := errors.WithStack(base.NewHTTPStatusError("https://example.com/", response, []byte("not found")))
+
+	assert.ErrorIs(t, err, base.ErrHTTPStatus)
+	assert.NotErrorIs(t, err, base.ErrRateLimited, "only 429 should be rate limited")
+	assert.Equal(t,
+		"fail to GET response from: https://example.com/\nStatus: 404 Not Found\nResponse body: not found",
+		err.Error())
+
+	var errStatus *base.HTTPStatusError
+
+	require.ErrorAs(t, err, &errStatus)
+	assert.Equal(t, http.StatusNotFound, errStatus.StatusCode)
+}
+
+func TestHTTPStatusError_rate_limited(t *testing.T) {
+	t.Parallel()
+
+	response := &http.Response{Status: "429 Too Many Requests", StatusCode: http.StatusTooManyRequests}
+	err := base.NewHTTPStatusError("https://example.com/", response, nil)
+
+	assert.ErrorIs(t, err, base.ErrHTTPStatus)
+	assert.ErrorIs(t, err, base.ErrRateLimited)
+}
+
+func TestMark(t *testing.T) {
+	t.Parallel()
+
+	errCause := errors.Wrap(io.ErrUnexpectedEOF, "failed to read")
+	err := errors.Wrap(base.Mark(errCause, base.ErrParse), "failed to get IP")
+
+	assert.ErrorIs(t, err, base.ErrParse)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "it should keep the cause")
+	assert.NotErrorIs(t, err, base.ErrTimeout)
+	assert.Equal(t, "failed to get IP: failed to read: unexpected EOF", err.Error())
+	assert.Contains(t, fmt.Sprintf("%+v", err), "TestMark", "it should keep the stack trace of the cause")
+}
+
+func TestMark_nil(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, base.Mark(nil, base.ErrParse))
+}
+
+func TestNewParseError(t *testing.T) {
+	t.Parallel()
+
+	err := base.NewParseError(io.ErrUnexpectedEOF, "fail to parse JSON response", []byte(`{"ip": `))
+
+	assert.ErrorIs(t, err, base.ErrParse)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "it should keep the cause")
+	assert.Equal(t, `fail to parse JSON response (response body: {"ip":): unexpected EOF`, err.Error())
+
+	err = base.NewParseError(nil, "IP address not found", []byte("<html>\n<body></body></html>"))
+
+	assert.ErrorIs(t, err, base.ErrParse)
+	assert.Equal(t, "IP address not found (response body: (HTML page of 27 bytes omitted))", err.Error())
+}
+
+func TestParseIP(t *testing.T) {
+	t.Parallel()
+
+	ip, err := base.ParseIP(" 123.123.123.123\n", nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "123.123.123.123", ip.String())
+
+	for _, input := range []string{"", "unknown", "123.123.123", "123.123.123.256"} {
+		ip, err := base.ParseIP(input, []byte(input))
+
+		require.Error(t, err, "input: %q", input)
+		assert.Nil(t, ip, "input: %q", input)
+		assert.ErrorIs(t, err, base.ErrParse, "input: %q", input)
+		assert.Contains(t, err.Error(), "invalid IP address in the response", "input: %q", input)
+	}
+}
@@ -0,0 +1,181 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/pkg/errors"
+)
+
+// BodySizeMaxDefault is the default max size of the response body to read in
+// bytes. The IP address detection services respond much smaller.
+const BodySizeMaxDefault = 1 << 20 // 1 MiB
+
+// Media types accepted by the providers. Use them as Pipeline.ContentTypes.
+var (
+	// ContentTypesJSON are the media types of the JSON APIs. Some of them
+	// respond JSON as plain text.
+	ContentTypesJSON = []string{"application/json", "text/json", "text/javascript", "text/plain"}
+	// ContentTypesHTML are the media types of the web pages to scrape. Plain
+	// text is scraped as well.
+	ContentTypesHTML = []string{"text/html", "application/xhtml+xml", "text/plain"}
+)
+
+// httpGetContext is a copy of netutil.HTTPGetContext to ease mock its behavior
+// during test.
+var httpGetContext = netutil.HTTPGetContext
+
+// ParseFunc parses the body of the 200 OK response into the details of the IP
+// address. The returned fmt.Stringer, such as the struct of the response, is
+// logged as the "Response info" for the verbose output.
+type ParseFunc func(body []byte) (*Details, fmt.Stringer, error)
+
+// ============================================================================
+//  Type: Pipeline
+// ============================================================================
+
+// Pipeline is the common steps of the HTTP based providers. Which requests the
+// URL, reads the body up to the limit, validates the status and the content
+// type, then parses the body with the Parse function and logs the result.
+//
+// So the provider only needs to implement the ParseFunc.
+//
+//	pipe := &base.Pipeline{
+//		URL:          c.EndpointURL,
+//		ContentTypes: base.ContentTypesJSON,
+//		Parse:        parseJSON,
+//	}
+//
+//	details, err := pipe.Run(ctx)
+type Pipeline struct {
+	// ReadAll reads the response body. If nil, io.ReadAll is used. Set the
+	// IOReadAll variable of the provider to ease mock it during test.
+	ReadAll func(r io.Reader) ([]byte, error)
+	// LogInfo logs the response info if the ctx does not hold a logger. If nil,
+	// info.Log is used.
+	LogInfo func(logs ...string) (int, error)
+	// Parse parses the body of the response. It is required.
+	Parse ParseFunc
+	// URL is the URL to request.
+	URL string
+	// ContentTypes are the accepted media types of the response. Such as
+	// ContentTypesJSON. If empty, any type is accepted. The response without
+	// the Content-Type header is accepted as well.
+	ContentTypes []string
+	// BodySizeMax is the max size of the response body in bytes. Zero means
+	// BodySizeMaxDefault.
+	BodySizeMax int64
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Pipeline
+// ----------------------------------------------------------------------------
+
+// Run runs the steps and returns the details parsed.
+func (p *Pipeline) Run(ctx context.Context) (*Details, error) {
+	if p.Parse == nil {
+		return nil, errors.New("missing parse function of the pipeline")
+	}
+
+	// HTTP request
+	response, err := httpGetContext(ctx, p.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to GET HTTP request")
+	}
+
+	if response == nil {
+		return nil, errors.Errorf("failed to GET HTTP request: no response from %v", p.URL)
+	}
+
+	defer response.Body.Close()
+
+	// Read response body
+	body, err := p.readBody(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckResponse(p.URL, response, body); err != nil {
+		return nil, err
+	}
+
+	if err := p.checkContentType(response.Header); err != nil {
+		return nil, err
+	}
+
+	details, parsed, err := p.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if details == nil {
+		return nil, NewParseError(nil, "no details parsed from the response of "+p.URL, body)
+	}
+
+	// Log for verbose output. Use the logger of the caller if any.
+	logFallback := p.LogInfo
+	if logFallback == nil {
+		logFallback = info.Log
+	}
+
+	if parsed != nil {
+		if _, err := info.LogFunc(ctx, logFallback)("Response info:\n" + parsed.String()); err != nil {
+			return nil, errors.Wrap(err, "failed to log response")
+		}
+	}
+
+	return details, nil
+}
+
+// Returns an error if the media type of the response is not accepted.
+func (p *Pipeline) checkContentType(header http.Header) error {
+	contentType := header.Get("Content-Type")
+	if len(p.ContentTypes) == 0 || contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Mark(errors.Wrapf(err, "malformed content type of the response: %v", contentType), ErrParse)
+	}
+
+	for _, accepted := range p.ContentTypes {
+		if strings.EqualFold(mediaType, accepted) {
+			return nil
+		}
+	}
+
+	return Mark(errors.Errorf("unexpected content type of the response: %v (expected: %v)",
+		mediaType, strings.Join(p.ContentTypes, ", ")), ErrParse)
+}
+
+// Reads the body up to BodySizeMax. It returns an error if the body is larger.
+func (p *Pipeline) readBody(body io.Reader) ([]byte, error) {
+	readAll := p.ReadAll
+	if readAll == nil {
+		readAll = io.ReadAll
+	}
+
+	sizeMax := p.BodySizeMax
+	if sizeMax <= 0 {
+		sizeMax = BodySizeMaxDefault
+	}
+
+	// Read one more byte to tell if the body is larger than the limit
+	data, err := readAll(io.LimitReader(body, sizeMax+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to read response body")
+	}
+
+	if int64(len(data)) > sizeMax {
+		return nil, Mark(errors.Errorf("response body exceeds the limit of %v bytes", sizeMax), ErrParse)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,37 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:paralleltest // do not parallelize due to mocking global function variables
+func TestPipeline_Run_nil_response(t *testing.T) {
+	oldHTTPGetContext := httpGetContext
+	defer func() {
+		httpGetContext = oldHTTPGetContext
+	}()
+
+	// Mock the response which is nil without an error
+	httpGetContext = func(ctx context.Context, url string) (*http.Response, error) {
+		return nil, nil //nolint:nilnil // the case to test
+	}
+
+	pipe := &Pipeline{
+		URL: "http://dummy.example.com/",
+		Parse: func(body []byte) (*Details, fmt.Stringer, error) {
+			return &Details{}, nil, nil
+		},
+	}
+
+	details, err := pipe.Run(context.Background())
+
+	require.Error(t, err, "nil response should be an error")
+	assert.Nil(t, details)
+	assert.Contains(t, err.Error(), "no response from http://dummy.example.com/")
+}
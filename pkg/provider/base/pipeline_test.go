@@ -0,0 +1,168 @@
+package base_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/provider/base"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeline_Run(t *testing.T) {
+	t.Parallel()
+
+	srv := newDummyServer(t, "application/json; charset=utf-8", `{"ip": "123.123.123.123"}`)
+	logger := info.New()
+
+	pipe := &base.Pipeline{
+		URL:          srv.URL,
+		ContentTypes: base.ContentTypesJSON,
+		Parse:        parseDummy,
+	}
+
+	details, err := pipe.Run(info.NewContext(context.Background(), logger))
+
+	require.NoError(t, err)
+	assert.Equal(t, "123.123.123.123", details.IP.String())
+	assert.Contains(t, logger.Get(), "Response info:\nparsed: {\"ip\": \"123.123.123.123\"}",
+		"the parsed response should be logged to the logger of the ctx")
+}
+
+func TestPipeline_Run_unexpected_content_type(t *testing.T) {
+	t.Parallel()
+
+	// Such as a captive portal
+	srv := newDummyServer(t, "text/html", "<html><body>Sign in to 10.0.0.1</body></html>")
+
+	pipe := &base.Pipeline{
+		URL:          srv.URL,
+		ContentTypes: base.ContentTypesJSON,
+		Parse:        parseDummy,
+	}
+
+	details, err := pipe.Run(info.NewContext(context.Background(), nil))
+
+	require.Error(t, err)
+	require.Nil(t, details)
+	assert.ErrorIs(t, err, base.ErrParse)
+	assert.Contains(t, err.Error(), "unexpected content type of the response: text/html")
+}
+
+func TestPipeline_Run_body_too_large(t *testing.T) {
+	t.Parallel()
+
+	srv := newDummyServer(t, "text/plain", strings.Repeat("x", 101))
+
+	pipe := &base.Pipeline{
+		URL:         srv.URL,
+		BodySizeMax: 100,
+		Parse:       parseDummy,
+	}
+
+	_, err := pipe.Run(info.NewContext(context.Background(), nil))
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, base.ErrParse)
+	assert.Contains(t, err.Error(), "response body exceeds the limit of 100 bytes")
+
+	// Just the limit
+	pipe.BodySizeMax = 101
+
+	_, err = pipe.Run(info.NewContext(context.Background(), nil))
+	require.NoError(t, err)
+}
+
+func TestPipeline_Run_status_error(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, "<html><body>Bad Gateway</body></html>")
+	}))
+	defer srv.Close()
+
+	pipe := &base.Pipeline{
+		URL:          srv.URL,
+		ContentTypes: base.ContentTypesJSON,
+		Parse:        parseDummy,
+	}
+
+	_, err := pipe.Run(info.NewContext(context.Background(), nil))
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, base.ErrHTTPStatus, "the status should be checked before the content type")
+	assert.NotErrorIs(t, err, base.ErrParse)
+}
+
+func TestPipeline_Run_bad_settings(t *testing.T) {
+	t.Parallel()
+
+	_, err := (&base.Pipeline{URL: "http://127.0.0.1:0/"}).Run(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing parse function of the pipeline")
+
+	pipe := &base.Pipeline{URL: "http://127.0.0.1:0/", Parse: parseDummy}
+
+	_, err = pipe.Run(info.NewContext(context.Background(), nil))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to GET HTTP request")
+}
+
+func TestPipeline_Run_nil_details(t *testing.T) {
+	t.Parallel()
+
+	srv := newDummyServer(t, "application/json", `{}`)
+
+	pipe := &base.Pipeline{
+		URL: srv.URL,
+		Parse: func(body []byte) (*base.Details, fmt.Stringer, error) {
+			return nil, nil, nil
+		},
+	}
+
+	details, err := pipe.Run(info.NewContext(context.Background(), nil))
+
+	require.Error(t, err, "nil details without an error should be an error")
+	assert.Nil(t, details)
+	assert.True(t, errors.Is(err, base.ErrParse), "it should be a parse error")
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+// Dummy ParseFunc which returns the IP address 123.123.123.123 and the body as
+// the response info.
+func parseDummy(body []byte) (*base.Details, fmt.Stringer, error) {
+	return &base.Details{IP: net.ParseIP("123.123.123.123")}, dummyStringer("parsed: " + string(body)), nil
+}
+
+type dummyStringer string
+
+func (s dummyStringer) String() string {
+	return string(s)
+}
+
+func newDummyServer(t *testing.T, contentType string, body string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		fmt.Fprint(w, body)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
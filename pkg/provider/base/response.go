@@ -0,0 +1,121 @@
+package base
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Max length of the response body in the error message.
+const lenBodySummaryMax = 256
+
+// TimeNow is a copy of time.Now to ease mock its behavior during test.
+var TimeNow = time.Now
+
+// Headers of the rate limit other than Retry-After, in the order of priority.
+// The values are the seconds to wait or the Unix time to reset.
+var headersRateLimitReset = []string{
+	"RateLimit-Reset",
+	"X-RateLimit-Reset",
+	"X-Rate-Limit-Reset",
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// CheckResponse returns nil if the status of the response is 200 OK. Otherwise
+// it returns the *HTTPStatusError of the response with the body already read.
+//
+// The error matches ErrRateLimited if the provider is rate limiting. Use
+// RetryAfterOf to get the time to wait before the next request.
+func CheckResponse(url string, response *http.Response, body []byte) error {
+	if response.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	return errors.WithStack(NewHTTPStatusError(url, response, body))
+}
+
+// ParseRetryAfter returns the time to wait before the next request from the
+// headers. Such as "Retry-After: 120" or "Retry-After: <HTTP-date>" and the
+// rate limit headers of the providers such as "X-RateLimit-Reset". It returns
+// zero if none of them tells.
+func ParseRetryAfter(header http.Header) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			return secondsToDuration(seconds)
+		}
+
+		if date, err := http.ParseTime(value); err == nil {
+			return positive(date.Sub(TimeNow()))
+		}
+	}
+
+	for _, name := range headersRateLimitReset {
+		value, err := strconv.ParseInt(strings.TrimSpace(header.Get(name)), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		// Large values are the Unix time. Such as 1651374000.
+		const unixTimeMin = 1_000_000_000
+		if value >= unixTimeMin {
+			return positive(time.Unix(value, 0).Sub(TimeNow()))
+		}
+
+		return secondsToDuration(value)
+	}
+
+	return 0
+}
+
+// RetryAfterOf returns the time to wait before the next request to the provider
+// which returned the err. It returns zero and false if the err is not of the
+// rate limit or the provider did not tell the time.
+func RetryAfterOf(err error) (time.Duration, bool) {
+	var errStatus *HTTPStatusError
+
+	if !errors.As(err, &errStatus) || !errStatus.IsRateLimited() || errStatus.RetryAfter <= 0 {
+		return 0, false
+	}
+
+	return errStatus.RetryAfter, true
+}
+
+// Returns the duration if positive. Otherwise zero.
+func positive(duration time.Duration) time.Duration {
+	if duration < 0 {
+		return 0
+	}
+
+	return duration
+}
+
+// Returns the seconds as time.Duration. Negative values are zero.
+func secondsToDuration(seconds int64) time.Duration {
+	return positive(time.Duration(seconds) * time.Second)
+}
+
+// Returns the short version of the body for the error message. The HTML pages
+// are omitted and the others are truncated to lenBodySummaryMax.
+func summarizeBody(contentType, body string) string {
+	lowerType := strings.ToLower(contentType)
+	lowerBody := strings.ToLower(strings.TrimSpace(body))
+
+	if strings.Contains(lowerType, "html") ||
+		strings.HasPrefix(lowerBody, "<!doctype html") || strings.HasPrefix(lowerBody, "<html") {
+		return "(HTML page of " + strconv.Itoa(len(body)) + " bytes omitted)"
+	}
+
+	summary := strings.Join(strings.Fields(body), " ")
+
+	if len(summary) > lenBodySummaryMax {
+		summary = summary[:lenBodySummaryMax] + "..."
+	}
+
+	return summary
+}
@@ -0,0 +1,113 @@
+package base_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/provider/base"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckResponse(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, base.CheckResponse("https://example.com/", &http.Response{StatusCode: http.StatusOK}, nil))
+
+	for _, test := range []struct {
+		header      http.Header
+		status      int
+		rateLimited bool
+	}{
+		{status: http.StatusTooManyRequests, header: http.Header{}, rateLimited: true},
+		{status: http.StatusServiceUnavailable, header: http.Header{"Retry-After": {"30"}}, rateLimited: true},
+		{status: http.StatusServiceUnavailable, header: http.Header{}, rateLimited: false},
+		{status: http.StatusInternalServerError, header: http.Header{"Retry-After": {"30"}}, rateLimited: false},
+	} {
+		response := &http.Response{
+			Status:     http.StatusText(test.status),
+			StatusCode: test.status,
+			Header:     test.header,
+		}
+
+		err := base.CheckResponse("https://example.com/", response, nil)
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, base.ErrHTTPStatus)
+		assert.Equal(t, test.rateLimited, errors.Is(err, base.ErrRateLimited), "status: %v", test.status)
+	}
+}
+
+func TestHTTPStatusError_Error_summarized(t *testing.T) {
+	t.Parallel()
+
+	bodyHTML := "<!DOCTYPE html><html><body>Too Many Requests</body></html>"
+	response := &http.Response{
+		Status:     "429 Too Many Requests",
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": {"60"}},
+	}
+
+	err := base.NewHTTPStatusError("https://example.com/", response, []byte(bodyHTML))
+
+	assert.Contains(t, err.Error(), "Response body: (HTML page of 58 bytes omitted)")
+	assert.Contains(t, err.Error(), "Retry after: 1m0s")
+	assert.Equal(t, bodyHTML, err.Body, "the body should be kept as is")
+
+	// Long text is truncated and the white spaces are collapsed
+	response.Header = http.Header{"Content-Type": {"text/plain"}}
+
+	err = base.NewHTTPStatusError("https://example.com/", response, []byte("slow\n\n down "+strings.Repeat("x", 300)))
+
+	assert.Contains(t, err.Error(), "Response body: slow down xxx")
+	assert.True(t, strings.HasSuffix(err.Error(), "x..."), "long body should be truncated")
+	assert.Less(t, len(err.Error()), 400)
+}
+
+//nolint:paralleltest // do not parallelize due to mocking TimeNow
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	oldTimeNow := base.TimeNow
+	defer func() { base.TimeNow = oldTimeNow }()
+
+	base.TimeNow = func() time.Time { return now }
+
+	for _, test := range []struct {
+		header http.Header
+		expect time.Duration
+	}{
+		{header: http.Header{"Retry-After": {"120"}}, expect: 2 * time.Minute},
+		{header: http.Header{"Retry-After": {now.Add(time.Hour).Format(http.TimeFormat)}}, expect: time.Hour},
+		{header: http.Header{"Retry-After": {now.Add(-time.Hour).Format(http.TimeFormat)}}, expect: 0},
+		{header: http.Header{"Retry-After": {"-1"}}, expect: 0},
+		{header: http.Header{"X-Ratelimit-Reset": {"30"}}, expect: 30 * time.Second},
+		{header: http.Header{"Ratelimit-Reset": {"10"}, "X-Ratelimit-Reset": {"30"}}, expect: 10 * time.Second},
+		{header: http.Header{"X-Rate-Limit-Reset": {"1651410000"}}, expect: time.Hour}, // Unix time
+		{header: http.Header{"Retry-After": {"soon"}}, expect: 0},
+		{header: http.Header{}, expect: 0},
+	} {
+		assert.Equal(t, test.expect, base.ParseRetryAfter(test.header), "header: %v", test.header)
+	}
+}
+
+func TestRetryAfterOf(t *testing.T) {
+	t.Parallel()
+
+	response := &http.Response{
+		Status:     "429 Too Many Requests",
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": {"5"}},
+	}
+
+	retryAfter, ok := base.RetryAfterOf(errors.Wrap(base.CheckResponse("https://example.com/", response, nil), "wrapped"))
+
+	require.True(t, ok)
+	assert.Equal(t, 5*time.Second, retryAfter)
+
+	_, ok = base.RetryAfterOf(errors.New("other error"))
+	assert.False(t, ok)
+}
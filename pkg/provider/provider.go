@@ -1,13 +1,16 @@
 package provider
 
 import (
+	"context"
 	"net"
 
+	"github.com/KEINOS/whereami/pkg/provider/base"
 	"github.com/KEINOS/whereami/pkg/provider/providers/inetcluecom"
 	"github.com/KEINOS/whereami/pkg/provider/providers/inetipinfo"
 	"github.com/KEINOS/whereami/pkg/provider/providers/ipifyorg"
 	"github.com/KEINOS/whereami/pkg/provider/providers/ipinfoio"
 	"github.com/KEINOS/whereami/pkg/provider/providers/toolpageorg"
+	"github.com/pkg/errors"
 )
 
 // Provider is the interface which each provider package must implement.
@@ -20,6 +23,41 @@ type Provider interface {
 	Name() string
 }
 
+// ContextProvider is the optional interface of Provider which supports the
+// cancellation and the deadline of the request via context.
+//
+// All the built-in providers implement this interface.
+type ContextProvider interface {
+	Provider
+	// GetIPContext is similar to GetIP but with the given context.
+	GetIPContext(ctx context.Context) (net.IP, error)
+}
+
+// Details is the information about the IP address which the provider knows in
+// addition to the IP address itself. Such as the ASN and the owner.
+type Details = base.Details
+
+// DetailsProvider is the optional interface of Provider which returns the
+// details of the IP address, such as the ASN and the owner, in a request.
+type DetailsProvider interface {
+	Provider
+	// GetDetailsContext returns the current IP address with its details.
+	GetDetailsContext(ctx context.Context) (*Details, error)
+}
+
+// Errors of the providers usable with errors.Is. See the base package for the
+// details.
+var (
+	ErrTimeout     = base.ErrTimeout
+	ErrRateLimited = base.ErrRateLimited
+	ErrParse       = base.ErrParse
+	ErrHTTPStatus  = base.ErrHTTPStatus
+)
+
+// HTTPStatusError is the error when the provider responded with a status other
+// than 200 OK. Use errors.As to get the status code.
+type HTTPStatusError = base.HTTPStatusError
+
 // GetAll returns all providers.
 //
 // Note that if you implement a new provider, you must add it in this function.
@@ -34,3 +72,55 @@ func GetAll() []Provider {
 		// whatismyipcom.New(),
 	}
 }
+
+// GetDetailsContext returns the global/public IP address detected by the given
+// provider with its details.
+//
+// If the provider does not implement DetailsProvider, only the IP field is set.
+func GetDetailsContext(ctx context.Context, prov Provider) (*Details, error) {
+	if detailsProv, ok := prov.(DetailsProvider); ok {
+		return detailsProv.GetDetailsContext(ctx)
+	}
+
+	ipAddress, err := GetIPContext(ctx, prov)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Details{IP: ipAddress}, nil
+}
+
+// GetIPContext returns the global/public IP address detected by the given
+// provider.
+//
+// If the provider implements ContextProvider, its GetIPContext is used.
+// Otherwise GetIP is called and its result is abandoned once the ctx is done.
+func GetIPContext(ctx context.Context, prov Provider) (net.IP, error) {
+	if ctxProv, ok := prov.(ContextProvider); ok {
+		return ctxProv.GetIPContext(ctx)
+	}
+
+	type result struct {
+		err error
+		ip  net.IP
+	}
+
+	chResult := make(chan result, 1)
+
+	go func() {
+		ip, err := prov.GetIP()
+		chResult <- result{ip: ip, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		err := errors.Wrap(ctx.Err(), "provider did not respond in time")
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = base.Mark(err, ErrTimeout)
+		}
+
+		return nil, err
+	case res := <-chResult:
+		return res.ip, res.err
+	}
+}
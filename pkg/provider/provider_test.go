@@ -1,12 +1,19 @@
 package provider_test
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"testing"
+	"time"
 
 	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func ExampleGetAll() {
@@ -45,3 +52,97 @@ func getDummyServerURL() (dummyURL string, deferFn func()) {
 
 	return dummySrv.URL, dummySrv.Close
 }
+
+func TestGetIPContext_context_provider(t *testing.T) {
+	t.Parallel()
+
+	dummyURL, closer := getDummyServerURL()
+	defer closer()
+
+	prov := provider.GetAll()[0]
+	prov.SetURL(dummyURL)
+
+	ip, err := provider.GetIPContext(context.Background(), prov)
+
+	require.NoError(t, err)
+	assert.Equal(t, "123.123.123.123", ip.String())
+}
+
+func TestGetIPContext_legacy_provider(t *testing.T) {
+	t.Parallel()
+
+	prov := &legacyProvider{delay: 0}
+
+	ip, err := provider.GetIPContext(context.Background(), prov)
+
+	require.NoError(t, err)
+	assert.Equal(t, "123.123.123.123", ip.String())
+}
+
+func TestGetIPContext_legacy_provider_timeout(t *testing.T) {
+	t.Parallel()
+
+	prov := &legacyProvider{delay: time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	ip, err := provider.GetIPContext(ctx, prov)
+
+	require.Error(t, err, "it should not wait the provider which does not support context")
+	require.Nil(t, ip)
+	assert.Contains(t, err.Error(), "provider did not respond in time")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, provider.ErrTimeout, "timeout should be marked as ErrTimeout")
+}
+
+func TestGetDetailsContext(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"ip": "123.123.123.123", "org": "AS15169 Google LLC"}`)
+	}))
+	defer dummySrv.Close()
+
+	// ipinfo.io implements provider.DetailsProvider
+	prov := provider.GetAll()[0]
+	prov.SetURL(dummySrv.URL)
+
+	details, err := provider.GetDetailsContext(context.Background(), prov)
+
+	require.NoError(t, err)
+	assert.Equal(t, "123.123.123.123", details.IP.String())
+	assert.Equal(t, 15169, details.ASN)
+	assert.Equal(t, "Google LLC", details.Owner)
+
+	// Fallback to GetIP
+	details, err = provider.GetDetailsContext(context.Background(), &legacyProvider{})
+
+	require.NoError(t, err)
+	assert.Equal(t, "123.123.123.123", details.IP.String())
+	assert.Zero(t, details.ASN)
+
+	_, err = provider.GetDetailsContext(context.Background(), &legacyProvider{err: errors.New("forced error")})
+
+	require.Error(t, err)
+}
+
+// legacyProvider is a provider which does not implement provider.ContextProvider.
+type legacyProvider struct {
+	err   error
+	delay time.Duration
+}
+
+func (p *legacyProvider) GetIP() (net.IP, error) {
+	time.Sleep(p.delay)
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return net.ParseIP("123.123.123.123"), nil
+}
+
+func (p *legacyProvider) Name() string { return "legacy" }
+
+func (p *legacyProvider) SetURL(url string) {}
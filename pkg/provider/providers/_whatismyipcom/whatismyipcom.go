@@ -15,6 +15,7 @@ import (
 
 	"github.com/KEINOS/go-utiles/util"
 	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/ipaddr"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/pkg/errors"
 )
@@ -48,13 +49,26 @@ func IsIPv4(ip string) bool {
 
 // ScrapeIPv4 returns the first IPv4 address found from the given html.
 func ScrapeIPv4(html string) string {
-	exp := `(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)(\.(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)){3}`
-	rex := regexp.MustCompile(exp)
+	// Zero padded IP such as "001.001.001.001" is normalized as "1.1.1.1"
+	for _, ip := range ipaddr.Extract([]byte(html)) {
+		if ip.To4() != nil {
+			return ip.String()
+		}
+	}
 
-	ip := rex.FindString(html) // Find IP
+	return ""
+}
+
+// ScrapeIPv6 returns the first IPv6 address found from the given html. Such as
+// the full, compressed or with IPv4 suffix forms.
+func ScrapeIPv6(html string) string {
+	for _, ip := range ipaddr.Extract([]byte(html)) {
+		if ip.To4() == nil {
+			return ip.String()
+		}
+	}
 
-	// Trim leading zeroed IP "001.001.001.001" as "1.1.1.1"
-	return info.NormalizeIPv4(ip)
+	return ""
 }
 
 // ----------------------------------------------------------------------------
@@ -74,6 +88,7 @@ type Client struct {
 type Response struct {
 	Provider string `json:"provider"`
 	IP       string `json:"ip"`
+	IPv6     string `json:"ipv6,omitempty"`
 }
 
 // ----------------------------------------------------------------------------
@@ -135,6 +150,13 @@ func GetResponse(urlProvider string) (*Response, error) {
 		}
 	})
 
+	// The IPv6 address is shown if reached over IPv6
+	doc.Find("#ipv6").Each(func(_ int, s *goquery.Selection) {
+		if ip := ScrapeIPv6(s.Text()); ip != "" && result.IPv6 == "" {
+			result.IPv6 = ip
+		}
+	})
+
 	return result, nil
 }
 
@@ -154,6 +176,10 @@ func (c *Client) GetIP() (net.IP, error) {
 		return nil, errors.Wrap(err, "failed to log response")
 	}
 
+	if result.IP == "" {
+		return net.ParseIP(result.IPv6), nil
+	}
+
 	return net.ParseIP(result.IP), nil
 }
 
@@ -276,3 +276,20 @@ func TestIsIPv4(t *testing.T) {
 		require.False(t, actual, "Input IP: %v", test.input)
 	}
 }
+
+func TestScrapeIPv6(t *testing.T) {
+	for _, test := range []struct {
+		input  string
+		expect string
+	}{
+		{"2001:4860:4860:0000:0000:0000:0000:8888", "2001:4860:4860::8888"},
+		{" Your IPv6 is: 2001:4860:4860::8888 ", "2001:4860:4860::8888"},
+		{"64:ff9b::111.111.111.111", "64:ff9b::6f6f:6f6f"},
+		{"111.111.111.111", ""},
+		{"Not Detected", ""},
+	} {
+		actual := whatismyipcom.ScrapeIPv6(test.input)
+
+		require.Equal(t, test.expect, actual, "Input: %v", test.input)
+	}
+}
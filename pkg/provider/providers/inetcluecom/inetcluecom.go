@@ -4,15 +4,16 @@ Package inetcluecom provides an interface to the inetclue.com web service.
 package inetcluecom
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"net"
-	"net/http"
 	"regexp"
 
 	"github.com/KEINOS/go-utiles/util"
 	"github.com/KEINOS/whereami/pkg/info"
-	"github.com/KEINOS/whereami/pkg/netutil"
-	"github.com/pkg/errors"
+	"github.com/KEINOS/whereami/pkg/ipaddr"
+	"github.com/KEINOS/whereami/pkg/provider/base"
 )
 
 const (
@@ -26,19 +27,44 @@ var IOReadAll = io.ReadAll
 // LogInfo is a copy of info.Log function to ease mock it's behavior during test.
 var LogInfo = info.Log
 
+// Pattern of the IPv4 address which may be glued to the surrounding words, such
+// as "foo123.123.123.123bar". It is not in the middle of a number or an IPv6.
+var rexLooseIPv4 = regexp.MustCompile(
+	`(?:^|[^0-9.:])((?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)(?:\.(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)){3})`)
+
 // ============================================================================
 //  Functions
 // ============================================================================
 
 // ScrapeIPv4 returns the first IPv4 address found from the given html.
 func ScrapeIPv4(html []byte) string {
-	exp := `(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)(\.(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)){3}`
-	rex := regexp.MustCompile(exp)
+	// Zero padded IP such as "001.001.001.001" is normalized as "1.1.1.1"
+	for _, ip := range ipaddr.Extract(html) {
+		if ip.To4() != nil {
+			return ip.String()
+		}
+	}
 
-	ip := rex.FindString(string(html)) // Find IP
+	// Fallback to the address glued to the surrounding words as it used to be
+	if match := rexLooseIPv4.FindSubmatch(html); match != nil {
+		if ip, err := ipaddr.Parse(string(match[1])); err == nil {
+			return ip.String()
+		}
+	}
 
-	// Trim leading zeroed IP "001.001.001.001" as "1.1.1.1"
-	return info.NormalizeIPv4(ip)
+	return ""
+}
+
+// ScrapeIPv6 returns the first IPv6 address found from the given html. Such as
+// the full, compressed or with IPv4 suffix forms.
+func ScrapeIPv6(html []byte) string {
+	for _, ip := range ipaddr.Extract(html) {
+		if ip.To4() == nil {
+			return ip.String()
+		}
+	}
+
+	return ""
 }
 
 // ============================================================================
@@ -67,43 +93,63 @@ func New() *Client {
 
 // GetIP returns the current IP address detected by inetclue.com.
 func (c *Client) GetIP() (net.IP, error) {
-	// HTTP request
-	response, err := netutil.HTTPGet(c.EndpointURL)
-	if err != nil || response == nil {
-		return nil, errors.Wrap(err, "failed to GET HTTP request")
+	return c.GetIPContext(context.Background())
+}
+
+// GetIPContext is similar to GetIP but with the given context.
+func (c *Client) GetIPContext(ctx context.Context) (net.IP, error) {
+	details, err := c.GetDetailsContext(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	defer response.Body.Close()
+	return details.IP, nil
+}
 
-	// Read response body
-	resBody, err := IOReadAll(response.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "fail to read response body")
+// GetDetailsContext returns the current IP address. The IP addresses of both
+// families are set in IPs if the page shows IPv4 and IPv6 at once.
+func (c *Client) GetDetailsContext(ctx context.Context) (*base.Details, error) {
+	pipe := &base.Pipeline{
+		URL:          c.EndpointURL,
+		ContentTypes: base.ContentTypesHTML,
+		ReadAll:      IOReadAll,
+		LogInfo:      LogInfo,
+		Parse:        c.parse,
 	}
 
-	if response.StatusCode != http.StatusOK {
-		return nil, errors.Errorf(
-			"fail to GET response from: %v\nStatus: %v\nResponse body: %v",
-			c.EndpointURL,
-			response.Status,
-			string(resBody),
-		)
+	return pipe.Run(ctx) //nolint:wrapcheck // already wrapped in the pipeline
+}
+
+// Parses the response body. The inetclue.com returns the IP address in HTML.
+// IPv4 is the IP if the page shows both families.
+func (c *Client) parse(body []byte) (*base.Details, fmt.Stringer, error) {
+	resJSON := &Response{
+		IPv4:     ScrapeIPv4(body),
+		IPv6:     ScrapeIPv6(body),
+		Provider: c.EndpointURL,
 	}
 
-	ip := ScrapeIPv4(resBody)
+	switch {
+	case resJSON.IPv4 != "":
+		resJSON.IP = resJSON.IPv4
+	case resJSON.IPv6 != "":
+		resJSON.IP = resJSON.IPv6
+	default:
+		return nil, nil, base.NewParseError(nil, "IP address not found in the page", body)
+	}
 
-	resJSON := new(Response)
+	ipAddress, err := base.ParseIP(resJSON.IP, body)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Add Provider
-	resJSON.IP = ip
-	resJSON.Provider = c.EndpointURL
+	details := &base.Details{IP: ipAddress}
 
-	// Log for verbose output
-	if _, err := LogInfo("Response info:\n" + resJSON.String()); err != nil {
-		return nil, errors.Wrap(err, "failed to log response")
+	if resJSON.IPv4 != "" && resJSON.IPv6 != "" {
+		details.IPs = []net.IP{net.ParseIP(resJSON.IPv4), net.ParseIP(resJSON.IPv6)}
 	}
 
-	return net.ParseIP(resJSON.IP), nil
+	return details, resJSON, nil
 }
 
 // Name returns the URL of the current provider as its name.
@@ -124,6 +170,8 @@ func (c *Client) SetURL(url string) {
 type Response struct {
 	Provider string `json:"provider"`
 	IP       string `json:"origin"`
+	IPv4     string `json:"ipv4,omitempty"`
+	IPv6     string `json:"ipv6,omitempty"`
 }
 
 // ----------------------------------------------------------------------------
@@ -1,12 +1,14 @@
 package inetcluecom_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/KEINOS/whereami/pkg/provider/base"
 	"github.com/KEINOS/whereami/pkg/provider/providers/inetcluecom"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -44,6 +46,44 @@ func TestGetIP_golden(t *testing.T) {
 	assert.Equal(t, expect, actual)
 }
 
+func TestGetDetailsContext_ipv6(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `<a href="/my_ip">2001:4860:4860:0:0:0:0:8888</a> <input id="ip" value="2001:4860:4860::8888">`)
+	}))
+	defer dummySrv.Close()
+
+	cli := inetcluecom.New()
+	cli.SetURL(dummySrv.URL)
+
+	details, err := cli.GetDetailsContext(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "2001:4860:4860::8888", details.IP.String())
+	assert.Empty(t, details.IPs, "IPs should be empty if only one family is shown")
+}
+
+func TestGetDetailsContext_both_families(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `<a href="/my_ip">2001:4860:4860::8888</a> <input id="ip" value="123.123.123.123">`)
+	}))
+	defer dummySrv.Close()
+
+	cli := inetcluecom.New()
+	cli.SetURL(dummySrv.URL)
+
+	details, err := cli.GetDetailsContext(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "123.123.123.123", details.IP.String(), "the first field should be the IP")
+	require.Len(t, details.IPs, 2)
+	assert.Equal(t, "123.123.123.123", details.IPs[0].String())
+	assert.Equal(t, "2001:4860:4860::8888", details.IPs[1].String())
+}
+
 //nolint:paralleltest // do not parallelize due to mocking global function variables
 func TestGetIP_error_fail_logging(t *testing.T) {
 	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -76,6 +116,36 @@ func TestGetIP_error_fail_logging(t *testing.T) {
 	assert.Contains(t, err.Error(), "forced fail to log")
 }
 
+func TestGetIP_error_ip_not_found(t *testing.T) {
+	t.Parallel()
+
+	for _, body := range []string{
+		// Captive portal like page without IP address
+		`<html><body>Please log in to use the Wi-Fi</body></html>`,
+		// Field with invalid IP address
+		`<input type="text" name="ip" id="ip" value="unknown">`,
+	} {
+		body := body
+
+		dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, body)
+		}))
+
+		cli := inetcluecom.New()
+		cli.SetURL(dummySrv.URL)
+
+		ip, err := cli.GetIP()
+
+		dummySrv.Close()
+
+		require.Error(t, err, "page without the IP address should be an error")
+		assert.Nil(t, ip, "the returned IP should be nil on error")
+		assert.ErrorIs(t, err, base.ErrParse)
+		assert.Contains(t, err.Error(), "response body:")
+	}
+}
+
 //nolint:paralleltest // do not parallelize due to race condition
 func TestGetIP_error_no_URL(t *testing.T) {
 	cli := inetcluecom.New()
@@ -113,6 +183,7 @@ func TestGetIP_error_response(t *testing.T) {
 		assert.Nil(t, ip, "the returned IP should be nil on error")
 		assert.Contains(t, err.Error(), "fail to GET response from:")
 		assert.Contains(t, err.Error(), "400 Bad Request")
+		assert.ErrorIs(t, err, base.ErrHTTPStatus)
 		assert.Contains(t, err.Error(), "invalid request")
 	})
 
@@ -172,12 +243,19 @@ func TestScrapeIPv4(t *testing.T) {
 		{input: "123.123.123.123", expect: "123.123.123.123"},
 		{input: "001.001.001.001", expect: "1.1.1.1"},
 		{input: "001.010.100.101", expect: "1.10.100.101"},
+		{input: "192.168.001.200", expect: "192.168.1.200"},
 		{input: "234.234.234.234 123.123.123.123", expect: "234.234.234.234"},
+		{input: "2001:db8::1 123.123.123.123", expect: "123.123.123.123"},
+		{input: "::ffff:123.123.123.123", expect: "123.123.123.123"},
 		// irregular
 		{input: "123.123.123.123/24", expect: "123.123.123.123"},
 		{input: "123.123.123.123.254", expect: "123.123.123.123"},
 		{input: "123.123.123.1233", expect: "123.123.123.123"},
 		{input: "foo123.123.123.123bar", expect: "123.123.123.123"},
+		{input: "<b>123.123.123.123</b>", expect: "123.123.123.123"},
+		// not an address
+		{input: "256.123.123.123", expect: ""},
+		{input: "1.256.123.123.123", expect: ""},
 	} {
 		expect := test.expect
 		actual := inetcluecom.ScrapeIPv4([]byte(test.input))
@@ -185,3 +263,28 @@ func TestScrapeIPv4(t *testing.T) {
 		require.Equal(t, expect, actual, "input: %v", test.input)
 	}
 }
+
+func TestScrapeIPv6(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		input  string
+		expect string
+	}{
+		// regular
+		{input: "2001:4860:4860:0000:0000:0000:0000:8888", expect: "2001:4860:4860::8888"},
+		{input: "2001:4860:4860::8888", expect: "2001:4860:4860::8888"},
+		{input: "64:ff9b::123.123.123.123", expect: "64:ff9b::7b7b:7b7b"},
+		{input: "123.123.123.123 2001:4860:4860::8888", expect: "2001:4860:4860::8888"},
+		{input: "<b>IPv6:</b> [2001:4860:4860::8888]", expect: "2001:4860:4860::8888"},
+		// not IPv6
+		{input: "123.123.123.123", expect: ""},
+		{input: "::ffff:123.123.123.123", expect: ""},
+		{input: "12:34:56", expect: ""},
+	} {
+		expect := test.expect
+		actual := inetcluecom.ScrapeIPv6([]byte(test.input))
+
+		require.Equal(t, expect, actual, "input: %v", test.input)
+	}
+}
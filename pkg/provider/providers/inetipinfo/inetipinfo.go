@@ -4,15 +4,15 @@ Package inetipinfo provides an interface to the inet-ip.info.
 package inetipinfo
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net"
-	"net/http"
 
 	"github.com/KEINOS/go-utiles/util"
 	"github.com/KEINOS/whereami/pkg/info"
-	"github.com/KEINOS/whereami/pkg/netutil"
-	"github.com/pkg/errors"
+	"github.com/KEINOS/whereami/pkg/provider/base"
 )
 
 const urlDefault = "https://inet-ip.info/json"
@@ -50,45 +50,53 @@ func New() *Client {
 
 // GetIP returns the current IP address detected by inet-ip.info.
 func (c *Client) GetIP() (net.IP, error) {
-	// HTTP request
-	response, err := netutil.HTTPGet(c.EndpointURL)
-	if err != nil || response == nil {
-		return nil, errors.Wrap(err, "failed to GET HTTP request")
-	}
-
-	defer response.Body.Close()
+	return c.GetIPContext(context.Background())
+}
 
-	// Read response body
-	resBody, err := IOReadAll(response.Body)
+// GetIPContext is similar to GetIP but with the given context.
+func (c *Client) GetIPContext(ctx context.Context) (net.IP, error) {
+	details, err := c.GetDetailsContext(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "fail to read response body")
+		return nil, err
 	}
 
-	if response.StatusCode != http.StatusOK {
-		return nil, errors.Errorf(
-			"fail to GET response from: %v\nStatus: %v\nResponse body: %v",
-			c.EndpointURL,
-			response.Status,
-			string(resBody),
-		)
+	return details.IP, nil
+}
+
+// GetDetailsContext returns the current IP address with its ASN and owner.
+func (c *Client) GetDetailsContext(ctx context.Context) (*base.Details, error) {
+	pipe := &base.Pipeline{
+		URL:          c.EndpointURL,
+		ContentTypes: base.ContentTypesJSON,
+		ReadAll:      IOReadAll,
+		LogInfo:      LogInfo,
+		Parse:        c.parse,
 	}
 
-	// Parse response. The inet-ip.info API returns in JSON.
+	return pipe.Run(ctx) //nolint:wrapcheck // already wrapped in the pipeline
+}
+
+// Parses the response body. The inet-ip.info API returns in JSON.
+func (c *Client) parse(body []byte) (*base.Details, fmt.Stringer, error) {
 	resJSON := new(Response)
 
-	if err := json.Unmarshal(resBody, resJSON); err != nil {
-		return nil, errors.Wrap(err, "fail to parse JSON response: \n"+string(resBody))
+	if err := json.Unmarshal(body, resJSON); err != nil {
+		return nil, nil, base.NewParseError(err, "fail to parse JSON response", body)
 	}
 
 	// Add Provider
 	resJSON.Provider = c.EndpointURL
 
-	// Log for verbose output
-	if _, err := LogInfo("Response info:\n" + resJSON.String()); err != nil {
-		return nil, errors.Wrap(err, "failed to log response")
+	ipAddress, err := base.ParseIP(resJSON.IPAddress, body)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return net.ParseIP(resJSON.IPAddress), nil
+	return &base.Details{
+		IP:    ipAddress,
+		ASN:   resJSON.ASN.AutonomousSystemNumber,
+		Owner: resJSON.ASN.AutonomousSystemOrganization,
+	}, resJSON, nil
 }
 
 // Name returns the URL of the current provider as its name.
@@ -1,12 +1,14 @@
 package inetipinfo_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/KEINOS/whereami/pkg/provider/base"
 	"github.com/KEINOS/whereami/pkg/provider/providers/inetipinfo"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -37,6 +39,24 @@ func TestGetIP_golden(t *testing.T) {
 	assert.Equal(t, expect, actual)
 }
 
+//nolint:paralleltest // do not parallelize due to the race condition
+func TestGetDetailsContext(t *testing.T) {
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"ipAddress": "123.123.123.123", "asn": {"AutonomousSystemNumber": 15169, "AutonomousSystemOrganization": "Google LLC"}}`)
+	}))
+	defer dummySrv.Close()
+
+	cli := inetipinfo.New()
+	cli.SetURL(dummySrv.URL)
+
+	details, err := cli.GetDetailsContext(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, "123.123.123.123", details.IP.String())
+	assert.Equal(t, 15169, details.ASN)
+	assert.Equal(t, "Google LLC", details.Owner)
+}
+
 //nolint:paralleltest // do not parallelize due to race condition
 func TestGetIP_error_bad_json(t *testing.T) {
 	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -54,6 +74,7 @@ func TestGetIP_error_bad_json(t *testing.T) {
 
 	require.Error(t, err, "malformed JSON should return an error")
 	require.Contains(t, err.Error(), "fail to parse JSON response")
+	require.ErrorIs(t, err, base.ErrParse)
 	require.Nil(t, ip, "the returned IP should be nil on error")
 }
 
@@ -127,6 +148,7 @@ func TestGetIP_error_response(t *testing.T) {
 		assert.Nil(t, ip, "the returned IP should be nil on error")
 		assert.Contains(t, err.Error(), "fail to GET response from:")
 		assert.Contains(t, err.Error(), "400 Bad Request")
+		assert.ErrorIs(t, err, base.ErrHTTPStatus)
 		assert.Contains(t, err.Error(), "invalid request")
 	})
 
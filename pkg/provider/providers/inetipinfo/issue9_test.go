@@ -2,6 +2,8 @@ package inetipinfo
 
 import (
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -24,7 +26,12 @@ func Test_issue9(t *testing.T) {
 		return data, nil
 	}
 
+	// Dummy server to avoid requesting the real API. The body is mocked above.
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer dummySrv.Close()
+
 	client := New()
+	client.SetURL(dummySrv.URL)
 
 	expect := "123.123.123.123"
 	actual, err := client.GetIP()
@@ -6,15 +6,15 @@ Package ipifyorg implements an interface to the ipify.org API.
 package ipifyorg
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net"
-	"net/http"
 
 	"github.com/KEINOS/go-utiles/util"
 	"github.com/KEINOS/whereami/pkg/info"
-	"github.com/KEINOS/whereami/pkg/netutil"
-	"github.com/pkg/errors"
+	"github.com/KEINOS/whereami/pkg/provider/base"
 )
 
 // This endpoint returns in JSON with IPv4/IPv6 address.
@@ -71,42 +71,44 @@ func New() *Client {
 
 // GetIP returns the current IP address detected by ipify.org.
 func (c *Client) GetIP() (net.IP, error) {
-	// HTTP request
-	response, err := netutil.HTTPGet(c.EndpointURL)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to GET HTTP request")
-	}
-
-	defer response.Body.Close()
+	return c.GetIPContext(context.Background())
+}
 
-	// Read response body
-	resBody, err := IOReadAll(response.Body)
-	if err != nil || response == nil {
-		return nil, errors.Wrap(err, "fail to read response body")
+// GetIPContext is similar to GetIP but with the given context.
+func (c *Client) GetIPContext(ctx context.Context) (net.IP, error) {
+	pipe := &base.Pipeline{
+		URL:          c.EndpointURL,
+		ContentTypes: base.ContentTypesJSON,
+		ReadAll:      IOReadAll,
+		LogInfo:      LogInfo,
+		Parse:        c.parse,
 	}
 
-	if response.StatusCode != http.StatusOK {
-		return nil, errors.Errorf(
-			"fail to GET response from: %v\nStatus: %v\nResponse body: %v",
-			c.EndpointURL,
-			response.Status,
-			string(resBody),
-		)
+	details, err := pipe.Run(ctx)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // already wrapped in the pipeline
 	}
 
-	// Parse response. The ipify.org API returns in JSON.
+	return details.IP, nil
+}
+
+// Parses the response body. The ipify.org API returns in JSON.
+func (c *Client) parse(body []byte) (*base.Details, fmt.Stringer, error) {
 	resJSON := new(Response)
-	_ = json.Unmarshal(resBody, resJSON)
+
+	if err := json.Unmarshal(body, resJSON); err != nil {
+		return nil, nil, base.NewParseError(err, "fail to parse JSON response", body)
+	}
 
 	// Add Provider
 	resJSON.Provider = c.EndpointURL
 
-	// Log for verbose output
-	if _, err := LogInfo("Response info:\n" + resJSON.String()); err != nil {
-		return nil, errors.Wrap(err, "failed to log response")
+	ipAddress, err := base.ParseIP(resJSON.IP, body)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return net.ParseIP(resJSON.IP), nil
+	return &base.Details{IP: ipAddress}, resJSON, nil
 }
 
 // Name returns the URL of the current provider as its name.
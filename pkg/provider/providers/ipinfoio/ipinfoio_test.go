@@ -1,12 +1,14 @@
 package ipinfoio_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/KEINOS/whereami/pkg/provider/base"
 	"github.com/KEINOS/whereami/pkg/provider/providers/ipinfoio"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -37,6 +39,52 @@ func TestGetIP_golden(t *testing.T) {
 	assert.Equal(t, expect, actual)
 }
 
+//nolint:paralleltest // do not parallelize due to the race condition
+func TestGetDetailsContext(t *testing.T) {
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"ip": "123.123.123.123", "org": "AS15169 Google LLC"}`)
+	}))
+	defer dummySrv.Close()
+
+	cli := ipinfoio.New()
+	cli.SetURL(dummySrv.URL)
+
+	details, err := cli.GetDetailsContext(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, "123.123.123.123", details.IP.String())
+	assert.Equal(t, 15169, details.ASN)
+	assert.Equal(t, "Google LLC", details.Owner)
+}
+
+func TestGetIP_error_bad_response(t *testing.T) {
+	t.Parallel()
+
+	for _, body := range []string{
+		`{"ip": "123.123.123.123"`, // malformed JSON
+		`{"ip": "unknown"}`,        // invalid IP address
+		`{"ip": ""}`,               // empty IP address
+	} {
+		body := body
+
+		dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, body)
+		}))
+
+		cli := ipinfoio.New()
+		cli.SetURL(dummySrv.URL) // Override URL to dummy server
+
+		ip, err := cli.GetIP()
+
+		dummySrv.Close()
+
+		require.Error(t, err, "bad response should be an error. body: %v", body)
+		assert.Nil(t, ip, "the returned IP should be nil on error")
+		assert.ErrorIs(t, err, base.ErrParse, "body: %v", body)
+		assert.Contains(t, err.Error(), body, "the error should contain the response body")
+	}
+}
+
 //nolint:paralleltest // do not parallelize due to mocking global function variables
 func TestGetIP_error_fail_logging(t *testing.T) {
 	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -106,6 +154,7 @@ func TestGet_error_response(t *testing.T) {
 		assert.Nil(t, ip, "the returned IP should be nil on error")
 		assert.Contains(t, err.Error(), "fail to GET response from:")
 		assert.Contains(t, err.Error(), "400 Bad Request")
+		assert.ErrorIs(t, err, base.ErrHTTPStatus)
 		assert.Contains(t, err.Error(), "invalid request")
 	})
 
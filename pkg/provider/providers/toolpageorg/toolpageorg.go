@@ -4,15 +4,17 @@ Package toolpageorg provides an interface to the en.toolpage.org web service.
 package toolpageorg
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"net"
-	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/KEINOS/go-utiles/util"
 	"github.com/KEINOS/whereami/pkg/info"
-	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/KEINOS/whereami/pkg/provider/base"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/pkg/errors"
 )
@@ -52,6 +54,8 @@ type Response struct {
 	Hostname   string `json:"hostname,omitempty"`
 	IPVersion  string `json:"ipVersion,omitempty"`
 	RemotePort string `json:"remotePort,omitempty"`
+
+	cellsIP []string // the cells of the IP addresses as is
 }
 
 // ----------------------------------------------------------------------------
@@ -69,57 +73,76 @@ func New() *Client {
 //  Functions
 // ----------------------------------------------------------------------------
 
-func getResponse(urlProvider string) (*http.Response, error) {
+// GetResponse returns the Response object parsed from the en.toolpage.org's content body.
+func GetResponse(urlProvider string) (*Response, error) {
+	return GetResponseContext(context.Background(), urlProvider)
+}
+
+// GetResponseContext is similar to GetResponse but with the given context.
+func GetResponseContext(ctx context.Context, urlProvider string) (*Response, error) {
+	result, _, err := getContext(ctx, urlProvider)
+
+	return result, err
+}
+
+// Returns the Response and the Details parsed from the content body.
+func getContext(ctx context.Context, urlProvider string) (*Response, *base.Details, error) {
 	// Validate URL to avoid gosec G107 vulnerability: Potential HTTP request made with variable url.
 	parsedURL, err := url.Parse(urlProvider)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse URL")
+		return nil, nil, errors.Wrap(err, "failed to parse URL")
 	}
 
-	// HTTP request
-	response, err := netutil.HTTPGet(parsedURL.String())
+	var result *Response
 
-	return response, errors.Wrap(err, "failed to GET HTTP request")
-}
+	pipe := &base.Pipeline{
+		URL:          parsedURL.String(),
+		ContentTypes: base.ContentTypesHTML,
+		ReadAll:      IOReadAll,
+		LogInfo:      LogInfo,
+		Parse: func(body []byte) (*base.Details, fmt.Stringer, error) {
+			parsed, err := parseDocument(body)
+			if err != nil {
+				return nil, nil, err
+			}
 
-// GetResponse returns the Response object parsed from the en.toolpage.org's content body.
-func GetResponse(urlProvider string) (*Response, error) {
-	result := new(Response)
+			parsed.Provider = urlProvider
+
+			// The cells may be padded or show both families. Such as "IPv6 Address:"
+			details, err := base.ScrapeDetails([]byte(strings.Join(parsed.cellsIP, "\n")), body)
+			if err != nil {
+				return nil, nil, err
+			}
 
-	result.Provider = urlProvider
+			parsed.IP = details.IP.String()
+			result = parsed
 
-	response, err := getResponse(urlProvider)
-	if err != nil || response == nil {
-		return nil, errors.Wrap(err, "failed to GET HTTP request")
+			return details, parsed, nil
+		},
 	}
 
-	defer response.Body.Close()
+	details, err := pipe.Run(ctx)
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // already wrapped in the pipeline
+	}
 
-	if response.StatusCode != http.StatusOK {
-		// Read error response body
-		resBody, resErr := IOReadAll(response.Body)
-		if resErr != nil {
-			return nil, errors.Wrap(resErr, "fail to read response body")
-		}
+	return result, details, nil
+}
 
-		return nil, errors.Errorf(
-			"fail to GET response from: %v\nStatus: %v\nResponse body: %v",
-			urlProvider,
-			response.Status,
-			string(resBody),
-		)
-	}
+// Parses the table of the en.toolpage.org's content body.
+func parseDocument(body []byte) (*Response, error) {
+	result := new(Response)
 
-	// Parse document from response body
-	doc, err := NewDocument(response.Body)
+	doc, err := NewDocument(bytes.NewReader(body))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to construct goquery document")
 	}
 
 	doc.Find(".outputTableKey").Each(func(_ int, querySelection *goquery.Selection) {
 		switch strings.TrimSpace(querySelection.Text()) {
-		case "IP Address:":
+		case "IP Address:", "IPv4 Address:", "IPv6 Address:":
 			result.IP = querySelection.Next().Text()
+			result.cellsIP = append(result.cellsIP, result.IP)
 		case "Host Name:":
 			result.Hostname = querySelection.Next().Text()
 		case "IP Version:":
@@ -138,17 +161,28 @@ func GetResponse(urlProvider string) (*Response, error) {
 
 // GetIP returns the current IP address detected by en.toolpage.org.
 func (c *Client) GetIP() (net.IP, error) {
-	result, err := GetResponse(c.EndpointURL)
+	return c.GetIPContext(context.Background())
+}
+
+// GetIPContext is similar to GetIP but with the given context.
+func (c *Client) GetIPContext(ctx context.Context) (net.IP, error) {
+	details, err := c.GetDetailsContext(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get IP address")
+		return nil, err
 	}
 
-	// Log for verbose output
-	if _, err := LogInfo("Response info:\n" + result.String()); err != nil {
-		return nil, errors.Wrap(err, "failed to log response")
+	return details.IP, nil
+}
+
+// GetDetailsContext returns the current IP address. The IP addresses of both
+// families are set in IPs if the page shows IPv4 and IPv6 at once.
+func (c *Client) GetDetailsContext(ctx context.Context) (*base.Details, error) {
+	_, details, err := getContext(ctx, c.EndpointURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get IP address")
 	}
 
-	return net.ParseIP(result.IP), nil
+	return details, nil
 }
 
 // Name returns the URL of the current provider as its name.
@@ -1,6 +1,7 @@
 package toolpageorg_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/provider/base"
 	"github.com/KEINOS/whereami/pkg/provider/providers/toolpageorg"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/pkg/errors"
@@ -88,6 +90,68 @@ func TestGetIP_golden(t *testing.T) {
 	assert.Contains(t, outLog, "5963")
 }
 
+func TestGetDetailsContext_ipv6(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `<table>
+<tr><td class="outputTableKey">IP Address:</td><td class="outputTableValue"> 2001:4860:4860:0:0:0:0:8888 </td></tr>
+<tr><td class="outputTableKey">IP Version:</td><td class="outputTableValue">IPv6</td></tr>
+</table>`)
+	}))
+	defer dummySrv.Close()
+
+	cli := toolpageorg.New()
+	cli.SetURL(dummySrv.URL)
+
+	details, err := cli.GetDetailsContext(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "2001:4860:4860::8888", details.IP.String(), "the cell should be normalized")
+	assert.Empty(t, details.IPs)
+}
+
+func TestGetDetailsContext_both_families(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `<table>
+<tr><td class="outputTableKey">IPv6 Address:</td><td class="outputTableValue">2001:4860:4860::8888</td></tr>
+<tr><td class="outputTableKey">IPv4 Address:</td><td class="outputTableValue">123.123.123.123</td></tr>
+</table>`)
+	}))
+	defer dummySrv.Close()
+
+	cli := toolpageorg.New()
+	cli.SetURL(dummySrv.URL)
+
+	details, err := cli.GetDetailsContext(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "2001:4860:4860::8888", details.IP.String())
+	require.Len(t, details.IPs, 2)
+	assert.Equal(t, "2001:4860:4860::8888", details.IPs[0].String())
+	assert.Equal(t, "123.123.123.123", details.IPs[1].String())
+}
+
+func TestGetDetailsContext_ip_not_found(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `<table><tr><td class="outputTableKey">IP Address:</td><td>unknown</td></tr></table>`)
+	}))
+	defer dummySrv.Close()
+
+	cli := toolpageorg.New()
+	cli.SetURL(dummySrv.URL)
+
+	details, err := cli.GetDetailsContext(context.Background())
+
+	require.Error(t, err)
+	assert.Nil(t, details)
+	assert.ErrorIs(t, err, base.ErrParse)
+}
+
 func TestGetIP_fail_get_response(t *testing.T) {
 	t.Parallel()
 
@@ -175,6 +239,7 @@ func TestGetResponse_fail_get_response(t *testing.T) {
 
 		assert.Contains(t, err.Error(), "fail to GET response from:")
 		assert.Contains(t, err.Error(), "400 Bad Request")
+		assert.ErrorIs(t, err, base.ErrHTTPStatus)
 		assert.Contains(t, err.Error(), "invalid request")
 	})
 
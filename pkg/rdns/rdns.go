@@ -0,0 +1,228 @@
+/*
+Package rdns looks up the reverse DNS (PTR) names of an IP address and checks
+whether they are forward-confirmed (FCrDNS). That is, the name resolves back to
+the same IP address.
+
+Many mail servers reject or penalize the connections from an IP address without
+the forward-confirmed reverse DNS.
+
+	result, err := rdns.New("").Lookup(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(result) // dns.google (forward-confirmed)
+
+See: https://en.wikipedia.org/wiki/Forward-confirmed_reverse_DNS
+*/
+package rdns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Default port number of DNS.
+	portDefault = "53"
+	// Default timeout of the whole lookup including the forward confirmation.
+	timeoutDefault = 5 * time.Second
+)
+
+// ============================================================================
+//  Type: Result
+// ============================================================================
+
+// Result is the reverse DNS of an IP address.
+type Result struct {
+	// IP is the IP address looked up.
+	IP string `json:"ip"`
+	// Name is the first forward-confirmed name, or the first PTR name if none
+	// is confirmed. Empty if no PTR record.
+	Name string `json:"name,omitempty"`
+	// Names are all the PTR names of the IP address without the trailing dot.
+	Names []string `json:"names,omitempty"`
+	// Confirmed are the names among Names which resolve back to the IP address.
+	Confirmed []string `json:"confirmed,omitempty"`
+	// ForwardConfirmed is true if any of the names resolves back to the IP
+	// address.
+	ForwardConfirmed bool `json:"forward_confirmed"`
+}
+
+// String returns the one line summary of the result. Such as
+// "dns.google (forward-confirmed)".
+func (r *Result) String() string {
+	switch {
+	case r.Name == "":
+		return "no PTR record"
+	case r.ForwardConfirmed:
+		return r.Name + " (forward-confirmed)"
+	default:
+		return r.Name + " (not forward-confirmed)"
+	}
+}
+
+// ============================================================================
+//  Type: Resolver
+// ============================================================================
+
+// Resolver looks up the reverse DNS.
+type Resolver struct {
+	// Server is the address of the DNS resolver to query. Such as "1.1.1.1" or
+	// "127.0.0.1:5353". If the port number is omitted, 53 is used. If empty,
+	// the resolver of the system is used.
+	Server string
+	// Dial connects to the Server. Such as the one via a proxy. The connection
+	// is used as TCP unless it is a net.PacketConn. If nil, it connects
+	// directly.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// Timeout is the time to wait for the whole lookup.
+	Timeout time.Duration
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// New returns a new Resolver which queries the given server with the default
+// timeout. If the server is empty, the resolver of the system is used.
+func New(server string) *Resolver {
+	return &Resolver{
+		Server:  server,
+		Timeout: timeoutDefault,
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Resolver
+// ----------------------------------------------------------------------------
+
+// Lookup is the same as LookupContext with the background context.
+func (r *Resolver) Lookup(ip net.IP) (*Result, error) {
+	return r.LookupContext(context.Background(), ip)
+}
+
+// LookupContext returns the PTR names of the ip and the ones forward-confirmed.
+//
+// No PTR record is not an error, but a result without names. Though, it returns
+// an error if the resolver fails. Such as timeout or SERVFAIL.
+func (r *Resolver) LookupContext(ctx context.Context, ip net.IP) (*Result, error) {
+	if ip == nil {
+		return nil, errors.New("IP address to look up is nil")
+	}
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	resolver := r.resolver()
+	result := &Result{IP: ip.String()}
+
+	names, err := resolver.LookupAddr(ctx, ip.String())
+	if err != nil {
+		if isNotFound(err) {
+			return result, nil
+		}
+
+		return nil, errors.Wrapf(err, "failed to look up the PTR record of %v", ip)
+	}
+
+	for _, name := range names {
+		result.Names = append(result.Names, strings.TrimSuffix(name, "."))
+	}
+
+	for _, name := range result.Names {
+		ok, err := r.confirm(ctx, resolver, name, ip)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			result.Confirmed = append(result.Confirmed, name)
+		}
+	}
+
+	result.ForwardConfirmed = len(result.Confirmed) > 0
+
+	switch {
+	case result.ForwardConfirmed:
+		result.Name = result.Confirmed[0]
+	case len(result.Names) > 0:
+		result.Name = result.Names[0]
+	}
+
+	return result, nil
+}
+
+// Returns true if the name resolves to the ip. The A records are queried for
+// IPv4 and AAAA for IPv6.
+func (r *Resolver) confirm(ctx context.Context, resolver *net.Resolver, name string, ip net.IP) (bool, error) {
+	network := "ip6"
+	if ip.To4() != nil {
+		network = "ip4"
+	}
+
+	addrs, err := resolver.LookupIP(ctx, network, name)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+
+		return false, errors.Wrapf(err, "failed to look up the address of %v", name)
+	}
+
+	for _, addr := range addrs {
+		if addr.Equal(ip) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Returns the resolver of the system, or the one which queries the Server.
+func (r *Resolver) resolver() *net.Resolver {
+	if r.Server == "" {
+		return net.DefaultResolver
+	}
+
+	address := r.Server
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(strings.Trim(address, "[]"), portDefault)
+	}
+
+	dial := r.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dial(ctx, network, address)
+		},
+	}
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Returns true if the err tells that the record does not exist.
+func isNotFound(err error) bool {
+	var errDNS *net.DNSError
+
+	if errors.As(err, &errDNS) {
+		// IsNotFound is available since Go 1.13 but not set by all resolvers
+		return errDNS.IsNotFound || strings.HasSuffix(errDNS.Err, "no such host")
+	}
+
+	return false
+}
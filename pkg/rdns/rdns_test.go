@@ -0,0 +1,148 @@
+package rdns_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/rdns"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup_forward_confirmed(t *testing.T) {
+	t.Parallel()
+
+	resolver := rdns.New(newDummyServer(t))
+
+	for _, test := range []struct {
+		ip     string
+		expect string
+	}{
+		{ip: "123.123.123.123", expect: "mail.example.com"},
+		{ip: "2001:db8::25", expect: "mail6.example.com"},
+	} {
+		result, err := resolver.Lookup(net.ParseIP(test.ip))
+
+		require.NoError(t, err, "ip: %v", test.ip)
+		assert.Equal(t, test.ip, result.IP)
+		assert.Equal(t, test.expect, result.Name)
+		assert.Equal(t, []string{test.expect}, result.Names)
+		assert.Equal(t, []string{test.expect}, result.Confirmed)
+		assert.True(t, result.ForwardConfirmed)
+		assert.Equal(t, test.expect+" (forward-confirmed)", result.String())
+	}
+}
+
+func TestLookup_not_forward_confirmed(t *testing.T) {
+	t.Parallel()
+
+	result, err := rdns.New(newDummyServer(t)).Lookup(net.ParseIP("123.123.123.124"))
+
+	require.NoError(t, err)
+	assert.Equal(t, "123x123x123x124.ftth.example.net", result.Name)
+	assert.Equal(t, []string{"123x123x123x124.ftth.example.net", "other.example.com"}, result.Names)
+	assert.Empty(t, result.Confirmed)
+	assert.False(t, result.ForwardConfirmed)
+	assert.Equal(t, "123x123x123x124.ftth.example.net (not forward-confirmed)", result.String())
+}
+
+func TestLookup_no_ptr(t *testing.T) {
+	t.Parallel()
+
+	result, err := rdns.New(newDummyServer(t)).Lookup(net.ParseIP("123.123.123.200"))
+
+	require.NoError(t, err, "no PTR record should not be an error")
+	assert.Empty(t, result.Name)
+	assert.Empty(t, result.Names)
+	assert.False(t, result.ForwardConfirmed)
+	assert.Equal(t, "no PTR record", result.String())
+}
+
+func TestLookup_server_failure(t *testing.T) {
+	t.Parallel()
+
+	result, err := rdns.New(newDummyServer(t)).Lookup(net.ParseIP("123.123.123.250"))
+
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "failed to look up the PTR record of 123.123.123.250")
+
+	result, err = rdns.New("").Lookup(nil)
+
+	require.Error(t, err)
+	assert.Nil(t, result)
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+// Starts a DNS server with the records below and returns its address. The PTR
+// of 123.123.123.250 responds SERVFAIL.
+func newDummyServer(t *testing.T) string {
+	t.Helper()
+
+	reverseIPv6, err := dns.ReverseAddr("2001:db8::25")
+	require.NoError(t, err)
+
+	records := map[string][]string{
+		"123.123.123.123.in-addr.arpa.": {"PTR mail.example.com."},
+		"124.123.123.123.in-addr.arpa.": {"PTR 123x123x123x124.ftth.example.net.", "PTR other.example.com."},
+		reverseIPv6:                     {"PTR mail6.example.com."},
+		"mail.example.com.":             {"A 123.123.123.123"},
+		"mail6.example.com.":            {"AAAA 2001:db8::25"},
+		// Not forward-confirmed
+		"123x123x123x124.ftth.example.net.": {"A 123.123.123.1"},
+	}
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+
+	srv := &dns.Server{
+		PacketConn:        packetConn,
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			resp := new(dns.Msg)
+			resp.SetReply(req)
+
+			question := req.Question[0]
+
+			switch {
+			case question.Name == "250.123.123.123.in-addr.arpa.":
+				resp.Rcode = dns.RcodeServerFailure
+			case records[question.Name] == nil:
+				resp.Rcode = dns.RcodeNameError
+			}
+
+			for _, data := range records[question.Name] {
+				record, err := dns.NewRR(question.Name + " 300 IN " + data)
+				require.NoError(t, err)
+
+				if record.Header().Rrtype == question.Qtype {
+					resp.Answer = append(resp.Answer, record)
+				}
+			}
+
+			if err := w.WriteMsg(resp); err != nil {
+				t.Log(err)
+			}
+		}),
+	}
+
+	go func() {
+		if err := srv.ActivateAndServe(); err != nil {
+			t.Log(err)
+		}
+	}()
+
+	<-started
+
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+
+	return packetConn.LocalAddr().String()
+}
@@ -0,0 +1,268 @@
+/*
+Package reflector implements a "what is my IP" HTTP service, which responds the
+IP address of the caller.
+
+The responses are in the same formats that the providers of whereami consume.
+So the own instance can be added to the provider list via SetURL.
+
+	GET /          → 203.0.113.1                       (plain text)
+	GET /json      → {"ip":"203.0.113.1"}              (ipify.org style)
+	GET /ipinfo    → {"ip":"203.0.113.1", ...}         (ipinfo.io style)
+
+The format can also be specified with the "format" query. Such as "/?format=json"
+which is the ipify.org compatible endpoint.
+
+The "Forwarded" (RFC 7239) and "X-Forwarded-For" headers are honored only if the
+request came from one of the trusted proxies.
+*/
+package reflector
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Response formats.
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatIPInfo = "ipinfo"
+)
+
+// Readme is the value of the "readme" field in the ipinfo.io style response.
+var Readme = "https://github.com/KEINOS/whereami"
+
+// ============================================================================
+//  Type: Handler
+// ============================================================================
+
+// Handler is an http.Handler which responds the IP address of the caller.
+type Handler struct {
+	trusted []*net.IPNet
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// New returns a new Handler. The trustedProxies are the IP addresses or CIDRs
+// of the reverse proxies allowed to tell the client address via "Forwarded" or
+// "X-Forwarded-For" headers.
+func New(trustedProxies ...string) (*Handler, error) {
+	handler := new(Handler)
+
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		ipNet, err := parseCIDR(proxy)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid trusted proxy")
+		}
+
+		handler.trusted = append(handler.trusted, ipNet)
+	}
+
+	return handler, nil
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Handler
+// ----------------------------------------------------------------------------
+
+// ClientIP returns the IP address of the caller of the request.
+//
+// If the request came from a trusted proxy, the address is taken from the
+// "Forwarded" header, or "X-Forwarded-For" if not set. Hops are read from right
+// to left and the first address which is not a trusted proxy is returned.
+func (h *Handler) ClientIP(req *http.Request) net.IP {
+	remoteIP := parseHost(req.RemoteAddr)
+	if remoteIP == nil || !h.isTrusted(remoteIP) {
+		return remoteIP
+	}
+
+	hops := forwardedFor(req.Header)
+	if len(hops) == 0 {
+		hops = xForwardedFor(req.Header)
+	}
+
+	clientIP := remoteIP
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hopIP := parseHost(hops[i])
+		if hopIP == nil {
+			// Obfuscated or malformed identifier. Can not go further.
+			break
+		}
+
+		clientIP = hopIP
+
+		if !h.isTrusted(hopIP) {
+			break
+		}
+	}
+
+	return clientIP
+}
+
+// ServeHTTP is an implementation of http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		switch strings.TrimSuffix(req.URL.Path, "/") {
+		case "", "/text":
+			format = FormatText
+		case "/json":
+			format = FormatJSON
+		case "/ipinfo":
+			format = FormatIPInfo
+		default:
+			http.NotFound(w, req)
+
+			return
+		}
+	}
+
+	clientIP := h.ClientIP(req)
+	if clientIP == nil {
+		http.Error(w, "failed to detect the client address", http.StatusInternalServerError)
+
+		return
+	}
+
+	// The response depends on the caller. Do not let the proxies cache it.
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch format {
+	case FormatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(clientIP.String()))
+	case FormatJSON:
+		writeJSON(w, map[string]string{"ip": clientIP.String()})
+	case FormatIPInfo:
+		writeJSON(w, map[string]string{"ip": clientIP.String(), "readme": Readme})
+	default:
+		http.Error(w, "unknown format: "+format, http.StatusBadRequest)
+	}
+}
+
+// Returns true if the given ip is one of the trusted proxies.
+func (h *Handler) isTrusted(ip net.IP) bool {
+	for _, ipNet := range h.trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Returns the "for" parameters of the "Forwarded" header (RFC 7239) in order.
+func forwardedFor(header http.Header) []string {
+	var hops []string
+
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val := splitPair(pair)
+				if strings.EqualFold(key, "for") {
+					hops = append(hops, strings.Trim(val, `"`))
+				}
+			}
+		}
+	}
+
+	return hops
+}
+
+// Returns the addresses of the "X-Forwarded-For" header in order.
+func xForwardedFor(header http.Header) []string {
+	var hops []string
+
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	return hops
+}
+
+// Parses the IP address from the "host", "host:port", "[host]:port" or "[host]"
+// notation. It returns nil if the host is not an IP address.
+func parseHost(hostport string) net.IP {
+	host := strings.TrimSpace(hostport)
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	// Remove the zone of IPv6 link-local address. Such as "fe80::1%eth0"
+	if i := strings.LastIndex(host, "%"); i > 0 {
+		host = host[:i]
+	}
+
+	return net.ParseIP(host)
+}
+
+// Parses the CIDR notation. Single IP address is treated as /32 or /128.
+func parseCIDR(cidr string) (*net.IPNet, error) {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, errors.Errorf("malformed IP address: %v", cidr)
+		}
+
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 8 * net.IPv4len
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(cidr)
+
+	return ipNet, errors.Wrap(err, "malformed CIDR")
+}
+
+// Splits "key=value" pair.
+func splitPair(pair string) (string, string) {
+	i := strings.Index(pair, "=")
+	if i < 0 {
+		return strings.TrimSpace(pair), ""
+	}
+
+	return strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+}
+
+// Writes the value as JSON.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	}
+}
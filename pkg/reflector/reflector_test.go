@@ -0,0 +1,180 @@
+package reflector_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/provider/providers/inetcluecom"
+	"github.com/KEINOS/whereami/pkg/provider/providers/ipifyorg"
+	"github.com/KEINOS/whereami/pkg/provider/providers/ipinfoio"
+	"github.com/KEINOS/whereami/pkg/reflector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_as_provider_endpoint(t *testing.T) {
+	t.Parallel()
+
+	handler, err := reflector.New()
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	// The existing providers should be able to consume the responses.
+	for _, test := range []struct {
+		prov provider.Provider
+		path string
+	}{
+		{prov: ipifyorg.New(), path: "/?format=json"},
+		{prov: ipinfoio.New(), path: "/ipinfo"},
+		{prov: inetcluecom.New(), path: "/"},
+	} {
+		test.prov.SetURL(srv.URL + test.path)
+
+		ip, err := test.prov.GetIP()
+
+		require.NoError(t, err, "path: %v", test.path)
+		assert.Equal(t, "127.0.0.1", ip.String(), "path: %v", test.path)
+	}
+}
+
+func TestHandler_formats(t *testing.T) {
+	t.Parallel()
+
+	handler, err := reflector.New()
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		target      string
+		expectBody  string
+		expectType  string
+		expectCode  int
+		description string
+	}{
+		{"/", "203.0.113.1", "text/plain; charset=utf-8", http.StatusOK, "root should be plain text"},
+		{"/text", "203.0.113.1", "text/plain; charset=utf-8", http.StatusOK, "text format"},
+		{"/json", `{"ip":"203.0.113.1"}` + "\n", "application/json; charset=utf-8", http.StatusOK, "ipify style"},
+		{"/?format=json", `{"ip":"203.0.113.1"}` + "\n", "application/json; charset=utf-8", http.StatusOK, "ipify query"},
+		{
+			"/ipinfo", `{"ip":"203.0.113.1","readme":"https://github.com/KEINOS/whereami"}` + "\n",
+			"application/json; charset=utf-8", http.StatusOK, "ipinfo style",
+		},
+		{"/?format=unknown", "unknown format: unknown\n", "text/plain; charset=utf-8", http.StatusBadRequest, "bad format"},
+		{"/unknown", "404 page not found\n", "text/plain; charset=utf-8", http.StatusNotFound, "unknown path"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, test.target, nil)
+		req.RemoteAddr = "203.0.113.1:12345"
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		res := rec.Result()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		assert.Equal(t, test.expectCode, res.StatusCode, test.description)
+		assert.Equal(t, test.expectType, res.Header.Get("Content-Type"), test.description)
+		assert.Equal(t, test.expectBody, string(body), test.description)
+	}
+}
+
+func TestHandler_method_not_allowed(t *testing.T) {
+	t.Parallel()
+
+	handler, err := reflector.New()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD", rec.Header().Get("Allow"))
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	handler, err := reflector.New("10.0.0.0/8", "192.0.2.1", "::1")
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		header     http.Header
+		remoteAddr string
+		expect     string
+		reason     string
+	}{
+		{
+			remoteAddr: "203.0.113.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1"}},
+			expect:     "203.0.113.1",
+			reason:     "headers from untrusted peer must be ignored",
+		},
+		{
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1, 10.1.1.1"}},
+			expect:     "198.51.100.1",
+			reason:     "trusted hops should be skipped from right to left",
+		},
+		{
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"203.0.113.9, 198.51.100.1"}},
+			expect:     "198.51.100.1",
+			reason:     "spoofed left most address must not be used",
+		},
+		{
+			remoteAddr: "192.0.2.1:1234",
+			header:     http.Header{"Forwarded": {`for=198.51.100.2;proto=https, for="[2001:db8::17]:4711"`}},
+			expect:     "2001:db8::17",
+			reason:     "Forwarded header with IPv6 and port",
+		},
+		{
+			remoteAddr: "[::1]:1234",
+			header: http.Header{
+				"Forwarded":       {"for=198.51.100.3"},
+				"X-Forwarded-For": {"198.51.100.4"},
+			},
+			expect: "198.51.100.3",
+			reason: "Forwarded header should have priority",
+		},
+		{
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"Forwarded": {"for=_hidden, for=10.0.0.2"}},
+			expect:     "10.0.0.2",
+			reason:     "obfuscated identifier should stop the walk",
+		},
+		{
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{},
+			expect:     "10.0.0.1",
+			reason:     "no header from trusted proxy",
+		},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		req.Header = test.header
+
+		actual := handler.ClientIP(req)
+
+		require.NotNil(t, actual, test.reason)
+		assert.Equal(t, test.expect, actual.String(), test.reason)
+	}
+}
+
+func TestNew_invalid_trusted_proxy(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{"foo", "10.0.0.0/33", "10.0.0.256"} {
+		handler, err := reflector.New(input)
+
+		require.Error(t, err, "input: %v", input)
+		assert.Nil(t, handler, "returned handler should be nil on error")
+		assert.Contains(t, err.Error(), "invalid trusted proxy")
+	}
+}
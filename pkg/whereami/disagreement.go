@@ -0,0 +1,224 @@
+package whereami
+
+import (
+	"crypto/x509"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Prefix lengths to regard the IP addresses as in the same network, when the
+// ASN is unknown.
+const (
+	prefixLenIPv4 = 16
+	prefixLenIPv6 = 32
+)
+
+// ============================================================================
+//  Type: DisagreementError
+// ============================================================================
+
+// DisagreementError is the error returned by Resolve when the providers did not
+// reach the quorum. It holds every answer of the providers and the likely
+// causes of the disagreement. It matches ErrNoConsensus with errors.Is.
+//
+//	var errDisagree *whereami.DisagreementError
+//	if errors.As(err, &errDisagree) {
+//		for _, vote := range errDisagree.Result.Votes() { ... }
+//	}
+type DisagreementError struct {
+	// Result is the result of Resolve. Use Result.Answers for each provider,
+	// IP address and error tuple. And Result.Votes for the answers grouped by
+	// the IP address.
+	Result *Result
+	// Causes are the likely causes of the disagreement.
+	Causes []Cause
+}
+
+// Returns a new DisagreementError of the result with its causes analyzed.
+func newDisagreementError(result *Result) *DisagreementError {
+	return &DisagreementError{
+		Result: result,
+		Causes: analyzeCauses(result),
+	}
+}
+
+// Error implements the error interface.
+func (e *DisagreementError) Error() string {
+	return "all returned IP addresses are different from each other"
+}
+
+// Is returns true if the target is ErrNoConsensus.
+func (e *DisagreementError) Is(target error) bool {
+	return target == ErrNoConsensus //nolint:errorlint,goerr113 // compare the sentinel as is
+}
+
+// ============================================================================
+//  Type: Cause
+// ============================================================================
+
+// Cause is a likely cause of the disagreement between the providers.
+type Cause string
+
+// Causes of the disagreement.
+const (
+	// CauseMixedFamily means that some providers answered IPv4 and the others
+	// IPv6, since the providers support different IP versions.
+	CauseMixedFamily Cause = "IPv4/IPv6 mixing"
+	// CauseSplitTunnelVPN means that the IP addresses belong to different
+	// networks. Such as the VPN routes only the traffic to some destinations.
+	CauseSplitTunnelVPN Cause = "split-tunnel VPN"
+	// CausePolicyRouting means that the IP addresses belong to the same network.
+	// Such as the router balances the traffic between the uplinks or the NAT
+	// pool per destination.
+	CausePolicyRouting Cause = "policy routing per destination"
+	// CauseTransparentProxy means that the plain HTTP providers and the HTTPS
+	// ones disagree, or the TLS certificate was not trusted. Such as a proxy
+	// intercepts the traffic.
+	CauseTransparentProxy Cause = "transparent proxy"
+)
+
+// Description returns the explanation of the cause.
+func (c Cause) Description() string {
+	switch c {
+	case CauseMixedFamily:
+		return "some providers answered in IPv4 and the others in IPv6. Both are your IP addresses."
+	case CauseSplitTunnelVPN:
+		return "the IP addresses belong to different networks. " +
+			"A VPN may route only the traffic to some destinations (split-tunnel)."
+	case CausePolicyRouting:
+		return "the IP addresses belong to the same network. " +
+			"The router may choose the uplink or the NAT address per destination."
+	case CauseTransparentProxy:
+		return "plain HTTP and HTTPS providers disagree or a TLS certificate was not trusted. " +
+			"A proxy may intercept the traffic."
+	}
+
+	return string(c)
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Returns the likely causes of the disagreement of the result.
+func analyzeCauses(result *Result) []Cause {
+	var causes []Cause
+
+	votes := result.Votes()
+
+	if hasMixedFamily(votes) {
+		causes = append(causes, CauseMixedFamily)
+	}
+
+	// Compare the IP addresses in the same family
+	sameNetwork, diffNetwork := false, false
+
+	for i := range votes {
+		for j := i + 1; j < len(votes); j++ {
+			if isIPv4(votes[i].IP) != isIPv4(votes[j].IP) {
+				continue
+			}
+
+			if isSameNetwork(votes[i], votes[j]) {
+				sameNetwork = true
+			} else {
+				diffNetwork = true
+			}
+		}
+	}
+
+	if diffNetwork {
+		causes = append(causes, CauseSplitTunnelVPN)
+	}
+
+	if sameNetwork {
+		causes = append(causes, CausePolicyRouting)
+	}
+
+	if hasTransparentProxy(result.Answers, votes) {
+		causes = append(causes, CauseTransparentProxy)
+	}
+
+	return causes
+}
+
+// Returns true if the votes have both IPv4 and IPv6 addresses.
+func hasMixedFamily(votes []Vote) bool {
+	hasIPv4, hasIPv6 := false, false
+
+	for _, vote := range votes {
+		if isIPv4(vote.IP) {
+			hasIPv4 = true
+		} else {
+			hasIPv6 = true
+		}
+	}
+
+	return hasIPv4 && hasIPv6
+}
+
+// Returns true if the answers have a TLS certificate error, or any vote is only
+// from the plain HTTP providers while another is only from HTTPS ones.
+func hasTransparentProxy(answers []Answer, votes []Vote) bool {
+	for _, answer := range answers {
+		if isCertificateError(answer.Err) {
+			return true
+		}
+	}
+
+	onlyHTTP, onlyHTTPS := false, false
+
+	for _, vote := range votes {
+		numHTTP := 0
+
+		for _, name := range vote.Providers {
+			if strings.HasPrefix(strings.ToLower(name), "http://") {
+				numHTTP++
+			}
+		}
+
+		switch numHTTP {
+		case len(vote.Providers):
+			onlyHTTP = true
+		case 0:
+			onlyHTTPS = true
+		}
+	}
+
+	return onlyHTTP && onlyHTTPS
+}
+
+// Returns true if the err is of the verification of the TLS certificate.
+func isCertificateError(err error) bool {
+	var (
+		errAuthority x509.UnknownAuthorityError
+		errHostname  x509.HostnameError
+		errInvalid   x509.CertificateInvalidError
+	)
+
+	return errors.As(err, &errAuthority) || errors.As(err, &errHostname) || errors.As(err, &errInvalid)
+}
+
+// Returns true if the IP address is IPv4 (including IPv4-mapped IPv6).
+func isIPv4(ip net.IP) bool {
+	return ip.To4() != nil
+}
+
+// Returns true if the votes are likely in the same network. The ASN is compared
+// if known by both. Otherwise the prefixes of the IP addresses are compared.
+func isSameNetwork(voteA, voteB Vote) bool {
+	if voteA.ASN != 0 && voteB.ASN != 0 {
+		return voteA.ASN == voteB.ASN
+	}
+
+	bits, prefixLen := 128, prefixLenIPv6
+	if isIPv4(voteA.IP) {
+		bits, prefixLen = 32, prefixLenIPv4
+	}
+
+	mask := net.CIDRMask(prefixLen, bits)
+
+	return voteA.IP.Mask(mask).Equal(voteB.IP.Mask(mask))
+}
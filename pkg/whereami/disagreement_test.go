@@ -0,0 +1,190 @@
+package whereami_test
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/whereami"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisagreementError(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name   string
+		provs  []provider.Provider
+		expect []whereami.Cause
+	}{
+		{
+			name: "split-tunnel VPN by ASN",
+			provs: []provider.Provider{
+				newDetails("https://a.example.com/", "123.123.123.123", 2516, "KDDI CORPORATION"),
+				newDetails("https://b.example.com/", "123.123.100.100", 9009, "M247 Ltd"),
+			},
+			expect: []whereami.Cause{whereami.CauseSplitTunnelVPN},
+		},
+		{
+			name: "policy routing by ASN",
+			provs: []provider.Provider{
+				newDetails("https://a.example.com/", "123.123.123.123", 2516, "KDDI CORPORATION"),
+				newDetails("https://b.example.com/", "111.111.111.111", 2516, "KDDI CORPORATION"),
+			},
+			expect: []whereami.Cause{whereami.CausePolicyRouting},
+		},
+		{
+			name: "unknown ASN compares the prefixes",
+			provs: []provider.Provider{
+				newDetails("https://a.example.com/", "123.123.123.123", 0, ""),
+				newDetails("https://b.example.com/", "123.123.1.1", 0, ""),
+				newDetails("https://c.example.com/", "111.111.111.111", 0, ""),
+			},
+			expect: []whereami.Cause{whereami.CauseSplitTunnelVPN, whereami.CausePolicyRouting},
+		},
+		{
+			name: "IPv4 and IPv6",
+			provs: []provider.Provider{
+				newDetails("https://a.example.com/", "123.123.123.123", 0, ""),
+				newDetails("https://b.example.com/", "2001:4860:4860::8888", 0, ""),
+			},
+			expect: []whereami.Cause{whereami.CauseMixedFamily},
+		},
+		{
+			name: "plain HTTP and HTTPS disagree",
+			provs: []provider.Provider{
+				newDetails("http://a.example.com/", "123.123.123.123", 0, ""),
+				newDetails("https://b.example.com/", "123.123.1.1", 0, ""),
+			},
+			expect: []whereami.Cause{whereami.CausePolicyRouting, whereami.CauseTransparentProxy},
+		},
+		{
+			name: "TLS certificate error",
+			provs: []provider.Provider{
+				newDetails("https://a.example.com/", "123.123.123.123", 0, ""),
+				&detailsProvider{
+					name: "https://b.example.com/",
+					err:  errors.Wrap(&url.Error{Op: "Get", URL: "https://b.example.com/", Err: x509.UnknownAuthorityError{}}, "failed"),
+				},
+			},
+			expect: []whereami.Cause{whereami.CauseTransparentProxy},
+		},
+		{
+			name: "not a TLS certificate error",
+			provs: []provider.Provider{
+				newDetails("https://a.example.com/", "123.123.123.123", 0, ""),
+				&detailsProvider{name: "https://b.example.com/", err: errors.New("certificate of the provider expired")},
+			},
+			expect: nil,
+		},
+	} {
+		resolver := whereami.New(
+			whereami.WithProviders(test.provs...),
+			whereami.WithQuorum(len(test.provs)),
+			whereami.WithLogger(nil),
+		)
+
+		_, err := resolver.Resolve(context.Background())
+
+		require.Error(t, err, test.name)
+		assert.Equal(t, "all returned IP addresses are different from each other", err.Error(), test.name)
+
+		var errDisagree *whereami.DisagreementError
+
+		require.True(t, errors.As(err, &errDisagree), "it should be a DisagreementError: %v", test.name)
+		assert.Equal(t, test.expect, errDisagree.Causes, test.name)
+		assert.Len(t, errDisagree.Result.Answers, len(test.provs), test.name)
+	}
+}
+
+func TestResult_Votes_annotated(t *testing.T) {
+	t.Parallel()
+
+	resolver := whereami.New(
+		whereami.WithProviders(
+			newDetails("https://a.example.com/", "123.123.123.123", 0, ""),
+			newDetails("https://b.example.com/", "123.123.123.123", 2516, "KDDI CORPORATION"),
+			newDetails("https://c.example.com/", "2001:4860:4860::8888", 0, ""),
+		),
+		whereami.WithQuorum(3),
+		whereami.WithLogger(nil),
+	)
+
+	_, err := resolver.Resolve(context.Background())
+
+	var errDisagree *whereami.DisagreementError
+
+	require.True(t, errors.As(err, &errDisagree))
+
+	votes := errDisagree.Result.Votes()
+
+	require.Len(t, votes, 2)
+
+	// The order of the votes is random since the providers are shuffled
+	if votes[0].IP.To4() == nil {
+		votes[0], votes[1] = votes[1], votes[0]
+	}
+
+	assert.Len(t, votes[0].Providers, 2)
+	assert.Equal(t, 2516, votes[0].ASN, "it should be annotated by the provider which knows")
+	assert.Equal(t, "KDDI CORPORATION", votes[0].Owner)
+	assert.Zero(t, votes[1].ASN)
+}
+
+func TestCause_Description(t *testing.T) {
+	t.Parallel()
+
+	for _, cause := range []whereami.Cause{
+		whereami.CauseMixedFamily,
+		whereami.CauseSplitTunnelVPN,
+		whereami.CausePolicyRouting,
+		whereami.CauseTransparentProxy,
+	} {
+		assert.NotEqual(t, string(cause), cause.Description())
+	}
+
+	assert.Equal(t, "unknown", whereami.Cause("unknown").Description())
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+// detailsProvider is a dummy provider which implements provider.DetailsProvider.
+type detailsProvider struct {
+	err     error
+	details provider.Details
+	name    string
+}
+
+func newDetails(name, ip string, asn int, owner string) *detailsProvider {
+	return &detailsProvider{
+		name:    name,
+		details: provider.Details{IP: net.ParseIP(ip), ASN: asn, Owner: owner},
+	}
+}
+
+func (d *detailsProvider) GetDetailsContext(ctx context.Context) (*provider.Details, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	details := d.details
+
+	return &details, nil
+}
+
+func (d *detailsProvider) GetIP() (net.IP, error) {
+	return d.details.IP, d.err
+}
+
+func (d *detailsProvider) Name() string {
+	return d.name
+}
+
+func (d *detailsProvider) SetURL(url string) {}
@@ -0,0 +1,210 @@
+package whereami
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/health"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Type: Policy
+// ============================================================================
+
+// Policy is the ordering policy of the providers to request.
+//
+// The policies other than PolicyRandom use the health of the providers set via
+// WithHealth. Without it, PolicyFastest and PolicyWeightedRandom behave the same
+// as PolicyRandom.
+type Policy string
+
+// Ordering policies.
+const (
+	// PolicyRandom requests the providers in random order. It is the default.
+	PolicyRandom Policy = "random"
+	// PolicyFastest requests the providers in the order of the expected latency.
+	// Which is the average latency divided by the success rate. The providers
+	// with no record come first to collect their records.
+	PolicyFastest Policy = "fastest"
+	// PolicyRoundRobin requests the least recently requested provider first. So
+	// the load is spread evenly between runs.
+	PolicyRoundRobin Policy = "round-robin"
+	// PolicyWeightedRandom requests the providers in random order, but the fast
+	// and reliable ones are more likely to come first. The slow or unreliable
+	// ones still have a chance to keep the sources of the quorum independent.
+	PolicyWeightedRandom Policy = "weighted-random"
+)
+
+// Minimum values to calculate the weight.
+const (
+	latencyMin     = time.Millisecond
+	successRateMin = 0.05
+)
+
+// Weight of the providers which failed but never succeeded. As if it took a
+// minute with the min success rate, so they come last in most cases.
+const weightNeverSucceeded = successRateMin / 60
+
+// ParsePolicy returns the Policy of the given name. Such as "fastest".
+func ParsePolicy(name string) (Policy, error) {
+	switch policy := Policy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case PolicyRandom, PolicyFastest, PolicyRoundRobin, PolicyWeightedRandom:
+		return policy, nil
+	}
+
+	return "", errors.Errorf("unknown ordering policy: %v (random, fastest, round-robin or weighted-random)", name)
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Resolver
+// ----------------------------------------------------------------------------
+
+// Returns a copy of the providers in the order of the policy.
+func (r *Resolver) order() []provider.Provider {
+	list := r.shuffle() // shuffle first to break the ties randomly
+
+	if r.health == nil && r.policy != PolicyRoundRobin {
+		return list
+	}
+
+	switch r.policy {
+	case PolicyFastest:
+		sort.SliceStable(list, func(i, j int) bool {
+			return r.expectedLatency(list[i]) < r.expectedLatency(list[j])
+		})
+	case PolicyRoundRobin:
+		if r.health == nil {
+			return r.rotate()
+		}
+
+		sort.SliceStable(list, func(i, j int) bool {
+			return lastRequested(r.health, list[i]).Before(lastRequested(r.health, list[j]))
+		})
+	case PolicyWeightedRandom:
+		list = r.weightedShuffle(list)
+	case PolicyRandom:
+		// already shuffled
+	}
+
+	return list
+}
+
+// Returns the expected latency of the provider. Zero if no record.
+func (r *Resolver) expectedLatency(prov provider.Provider) time.Duration {
+	stats, ok := r.health.Get(prov.Name())
+	if !ok || stats.Successes == 0 {
+		if ok && stats.Failures > 0 {
+			return time.Duration(math.MaxInt64) // never succeeded
+		}
+
+		return 0
+	}
+
+	return time.Duration(float64(stats.Latency) / stats.SuccessRate())
+}
+
+// Returns a copy of the providers rotated by one on each call. It is the round
+// robin without the health records.
+func (r *Resolver) rotate() []provider.Provider {
+	r.muRnd.Lock()
+	defer r.muRnd.Unlock()
+
+	list := make([]provider.Provider, 0, len(r.providers))
+
+	if len(r.providers) > 0 {
+		offset := r.cursor % len(r.providers)
+
+		list = append(list, r.providers[offset:]...)
+		list = append(list, r.providers[:offset]...)
+	}
+
+	r.cursor++
+
+	return list
+}
+
+// Returns the providers in the weighted random order. The weight is the success
+// rate per latency, and the providers with no record get the max weight. The
+// ones which never succeeded get the min weight, the same as PolicyFastest.
+//
+// It uses the algorithm of Efraimidis and Spirakis. Which sorts the items by
+// the key of rand^(1/weight), here in logarithm to keep the precision.
+func (r *Resolver) weightedShuffle(list []provider.Provider) []provider.Provider {
+	weights := make(map[string]float64, len(list))
+	maxWeight := 0.0
+
+	for _, prov := range list {
+		stats, ok := r.health.Get(prov.Name())
+		if !ok {
+			continue
+		}
+
+		// Its latency is zero since no success is recorded
+		if stats.Successes == 0 && stats.Failures > 0 {
+			weights[prov.Name()] = weightNeverSucceeded
+
+			continue
+		}
+
+		latency := stats.Latency
+		if latency < latencyMin {
+			latency = latencyMin
+		}
+
+		rate := stats.SuccessRate()
+		if rate < successRateMin {
+			rate = successRateMin
+		}
+
+		weights[prov.Name()] = rate / latency.Seconds()
+
+		if weights[prov.Name()] > maxWeight {
+			maxWeight = weights[prov.Name()]
+		}
+	}
+
+	if maxWeight == 0 {
+		maxWeight = 1
+	}
+
+	keys := make(map[string]float64, len(list))
+
+	r.muRnd.Lock()
+
+	for _, prov := range list {
+		weight, ok := weights[prov.Name()]
+		if !ok {
+			weight = maxWeight
+		}
+
+		// In (0, 1] since the log of 0 is -Inf which ties regardless of the weight
+		keys[prov.Name()] = math.Log(1-r.rnd.Float64()) / weight
+	}
+
+	r.muRnd.Unlock()
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return keys[list[i].Name()] > keys[list[j].Name()]
+	})
+
+	return list
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Returns the last time the provider was requested. Zero if no record.
+func lastRequested(tracker *health.Tracker, prov provider.Provider) time.Time {
+	stats, _ := tracker.Get(prov.Name())
+
+	if stats.LastErrorAt.After(stats.LastSuccessAt) {
+		return stats.LastErrorAt
+	}
+
+	return stats.LastSuccessAt
+}
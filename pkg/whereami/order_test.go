@@ -0,0 +1,186 @@
+package whereami_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/health"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/whereami"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		input  string
+		expect whereami.Policy
+	}{
+		{input: "random", expect: whereami.PolicyRandom},
+		{input: "Fastest", expect: whereami.PolicyFastest},
+		{input: "round-robin", expect: whereami.PolicyRoundRobin},
+		{input: " weighted-random ", expect: whereami.PolicyWeightedRandom},
+	} {
+		policy, err := whereami.ParsePolicy(test.input)
+
+		require.NoError(t, err)
+		assert.Equal(t, test.expect, policy)
+	}
+
+	_, err := whereami.ParsePolicy("slowest")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown ordering policy: slowest")
+}
+
+func TestWithPolicy_fastest(t *testing.T) {
+	t.Parallel()
+
+	provs := []provider.Provider{
+		newDummy(0, "123.123.123.123"), // slow
+		newDummy(1, "123.123.123.123"), // fast but unreliable
+		newDummy(2, "123.123.123.123"), // fast
+		newDummy(3, "123.123.123.123"), // no record
+		newDummy(4, "123.123.123.123"), // never succeeded
+	}
+
+	tracker := health.New()
+	tracker.Record(provs[0].Name(), 500*time.Millisecond, nil)
+	tracker.Record(provs[1].Name(), 100*time.Millisecond, nil)
+	tracker.Record(provs[1].Name(), 0, errors.New("forced error"))
+	tracker.Record(provs[1].Name(), 0, errors.New("forced error"))
+	tracker.Record(provs[2].Name(), 100*time.Millisecond, nil)
+	tracker.Record(provs[4].Name(), 0, errors.New("forced error"))
+
+	resolver := whereami.New(
+		whereami.WithProviders(provs...),
+		whereami.WithQuorum(len(provs)),
+		whereami.WithHealth(tracker),
+		whereami.WithPolicy(whereami.PolicyFastest),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+	require.NoError(t, err)
+
+	expect := []string{provs[3].Name(), provs[2].Name(), provs[1].Name(), provs[0].Name(), provs[4].Name()}
+	actual := make([]string, 0, len(result.Answers))
+
+	for _, answer := range result.Answers {
+		actual = append(actual, answer.Provider)
+	}
+
+	assert.Equal(t, expect, actual,
+		"unknown provider first to collect the record, then by the latency divided by the success rate")
+}
+
+func TestWithPolicy_round_robin(t *testing.T) {
+	t.Parallel()
+
+	provs := []provider.Provider{
+		newDummy(0, "123.123.123.123"),
+		newDummy(1, "123.123.123.123"),
+		newDummy(2, "123.123.123.123"),
+	}
+
+	for _, tracker := range []*health.Tracker{nil, health.New()} {
+		resolver := whereami.New(
+			whereami.WithProviders(provs...),
+			whereami.WithQuorum(1),
+			whereami.WithHealth(tracker),
+			whereami.WithPolicy(whereami.PolicyRoundRobin),
+			whereami.WithLogger(nil),
+		)
+
+		requested := make(map[string]int)
+
+		for i := 0; i < len(provs)*2; i++ {
+			result, err := resolver.Resolve(context.Background())
+			require.NoError(t, err)
+
+			requested[result.Answers[0].Provider]++
+
+			// Let the time of the records differ
+			if tracker != nil {
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		for _, prov := range provs {
+			assert.Equal(t, 2, requested[prov.Name()], "each provider should be requested in turn")
+		}
+	}
+}
+
+func TestWithPolicy_weighted_random(t *testing.T) {
+	t.Parallel()
+
+	const numTry = 500
+
+	fast := newDummy(0, "123.123.123.123")
+	slow := newDummy(1, "123.123.123.123")
+	numFastFirst := 0
+
+	for i := 0; i < numTry; i++ {
+		tracker := health.New()
+		tracker.Record(fast.Name(), 10*time.Millisecond, nil)
+		tracker.Record(slow.Name(), 100*time.Millisecond, nil)
+
+		resolver := whereami.New(
+			whereami.WithProviders(slow, fast),
+			whereami.WithQuorum(1),
+			whereami.WithHealth(tracker),
+			whereami.WithPolicy(whereami.PolicyWeightedRandom),
+			whereami.WithLogger(nil),
+		)
+
+		result, err := resolver.Resolve(context.Background())
+		require.NoError(t, err)
+
+		if result.Answers[0].Provider == fast.Name() {
+			numFastFirst++
+		}
+	}
+
+	// The expected ratio is 10:1
+	assert.Greater(t, numFastFirst, numTry*7/10, "the fast provider should be preferred")
+	assert.Less(t, numFastFirst, numTry, "the slow provider should still have a chance")
+}
+
+func TestWithPolicy_weighted_random_never_succeeded(t *testing.T) {
+	t.Parallel()
+
+	const numTry = 500
+
+	dead := newDummy(0, "")
+	dead.err = errors.New("forced error")
+	healthy := newDummy(1, "123.123.123.123")
+	numDeadFirst := 0
+
+	for i := 0; i < numTry; i++ {
+		tracker := health.New()
+		tracker.Record(dead.Name(), 0, errors.New("forced error"))
+		tracker.Record(healthy.Name(), 100*time.Millisecond, nil)
+
+		resolver := whereami.New(
+			whereami.WithProviders(dead, healthy),
+			whereami.WithQuorum(1),
+			whereami.WithHealth(tracker),
+			whereami.WithPolicy(whereami.PolicyWeightedRandom),
+			whereami.WithLogger(nil),
+		)
+
+		result, err := resolver.Resolve(context.Background())
+		require.NoError(t, err)
+
+		if result.Answers[0].Provider == dead.Name() {
+			numDeadFirst++
+		}
+	}
+
+	assert.Less(t, numDeadFirst, numTry/100, "the provider which never succeeded should come last")
+}
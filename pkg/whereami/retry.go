@@ -0,0 +1,211 @@
+package whereami
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/pkg/errors"
+)
+
+// Default values of the retry policy. See DefaultRetryPolicy.
+const (
+	RetryAttemptsDefault = 3
+	RetryDelayDefault    = 200 * time.Millisecond
+	RetryDelayMaxDefault = 2 * time.Second
+	RetryJitterDefault   = 0.5
+)
+
+// ============================================================================
+//  Type: RetryClass
+// ============================================================================
+
+// RetryClass is a class of the transient errors to retry.
+type RetryClass string
+
+// Classes of the transient errors. The rate limits, the other HTTP status
+// errors (such as 4xx) and the parse errors are never retried.
+const (
+	// RetryOnNetwork retries on the network errors. Such as the connection reset
+	// or refused, unexpected EOF and DNS failures.
+	RetryOnNetwork RetryClass = "network"
+	// RetryOnTimeout retries on the timeout of the request.
+	RetryOnTimeout RetryClass = "timeout"
+	// RetryOn5xx retries on the HTTP status 5xx. Except 503 with the time to
+	// retry, which is a rate limit.
+	RetryOn5xx RetryClass = "5xx"
+)
+
+// ParseRetryClasses returns the classes of the comma separated names. Such as
+// "network,timeout,5xx". An empty string returns no class.
+func ParseRetryClasses(names string) ([]RetryClass, error) {
+	var classes []RetryClass
+
+	for _, name := range strings.Split(names, ",") {
+		switch class := RetryClass(strings.ToLower(strings.TrimSpace(name))); class {
+		case "":
+			continue
+		case RetryOnNetwork, RetryOnTimeout, RetryOn5xx:
+			classes = append(classes, class)
+		default:
+			return nil, errors.Errorf("unknown retry class: %v (network, timeout or 5xx)", name)
+		}
+	}
+
+	return classes, nil
+}
+
+// Returns the class of the err and true if it is a transient error.
+func classifyRetry(err error) (RetryClass, bool) {
+	var (
+		errStatus *provider.HTTPStatusError
+		errOp     *net.OpError
+		errDNS    *net.DNSError
+	)
+
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.Is(err, provider.ErrParse):
+		return "", false
+	case errors.As(err, &errStatus):
+		if errStatus.StatusCode >= http.StatusInternalServerError && !errStatus.IsRateLimited() {
+			return RetryOn5xx, true
+		}
+
+		return "", false
+	case errors.Is(err, provider.ErrTimeout):
+		return RetryOnTimeout, true
+	case errors.As(err, &errOp), errors.As(err, &errDNS),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return RetryOnNetwork, true
+	}
+
+	return "", false
+}
+
+// ============================================================================
+//  Type: RetryPolicy
+// ============================================================================
+
+// RetryPolicy is the policy to retry the request to a provider on the transient
+// errors. The zero value does not retry.
+//
+// The delay before the n-th retry is BaseDelay * 2^(n-1) capped by MaxDelay.
+// Then up to Jitter ratio of it is randomly reduced to not retry at the same
+// time with the others.
+type RetryPolicy struct {
+	// On is the classes of the errors to retry.
+	On []RetryClass
+	// MaxAttempts is the max number of the requests per provider including the
+	// first one. 1 or less means no retry.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry.
+	BaseDelay time.Duration
+	// MaxDelay is the max delay between the retries. Zero means no limit.
+	MaxDelay time.Duration
+	// Jitter is the ratio of the delay to randomize between 0 and 1.
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the policy which retries on all the classes twice
+// with the default delays.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		On:          []RetryClass{RetryOnNetwork, RetryOnTimeout, RetryOn5xx},
+		MaxAttempts: RetryAttemptsDefault,
+		BaseDelay:   RetryDelayDefault,
+		MaxDelay:    RetryDelayMaxDefault,
+		Jitter:      RetryJitterDefault,
+	}
+}
+
+// Returns the class of the err and true if it should be retried by the policy.
+func (p RetryPolicy) shouldRetry(err error) (RetryClass, bool) {
+	class, ok := classifyRetry(err)
+	if !ok {
+		return "", false
+	}
+
+	for _, on := range p.On {
+		if on == class {
+			return class, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns the delay before the n-th retry. random is a random number between 0
+// and 1 to apply the jitter.
+func (p RetryPolicy) delay(retry int, random float64) time.Duration {
+	delay := p.BaseDelay
+
+	for i := 1; i < retry; i++ {
+		delay *= 2
+
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	jitter := p.Jitter
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter > 1 {
+		jitter = 1
+	}
+
+	return delay - time.Duration(float64(delay)*jitter*random)
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Resolver
+// ----------------------------------------------------------------------------
+
+// Requests the provider until it succeeds or the error is not to retry by the
+// policy. Each attempt is logged.
+func (r *Resolver) requestWithRetry(ctx context.Context, prov provider.Provider) Answer {
+	var answer Answer
+
+	for attempt := 1; ; attempt++ {
+		r.logger.Debug("request start", "provider", prov.Name(), "attempt", attempt)
+
+		answer = r.request(ctx, prov)
+		answer.Attempts = attempt
+
+		if answer.Err == nil || attempt >= r.retry.MaxAttempts || ctx.Err() != nil {
+			return answer
+		}
+
+		class, ok := r.retry.shouldRetry(answer.Err)
+		if !ok {
+			return answer
+		}
+
+		r.muRnd.Lock()
+		delay := r.retry.delay(attempt, r.rnd.Float64())
+		r.muRnd.Unlock()
+
+		r.logger.Info(fmt.Sprintf("%v: attempt %v/%v failed. retry in %v", prov.Name(), attempt,
+			r.retry.MaxAttempts, delay.Round(time.Millisecond)),
+			"provider", prov.Name(),
+			"attempt", attempt,
+			"class", string(class),
+			"delay", delay,
+			"error", strings.SplitN(answer.Err.Error(), "\n", 2)[0],
+		)
+
+		if !netutil.Sleep(ctx, delay) {
+			return answer
+		}
+	}
+}
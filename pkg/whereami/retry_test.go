@@ -0,0 +1,184 @@
+package whereami_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/provider/providers/ipifyorg"
+	"github.com/KEINOS/whereami/pkg/whereami"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryClasses(t *testing.T) {
+	t.Parallel()
+
+	classes, err := whereami.ParseRetryClasses(" Network, timeout,5xx ")
+
+	require.NoError(t, err)
+	assert.Equal(t, []whereami.RetryClass{whereami.RetryOnNetwork, whereami.RetryOnTimeout, whereami.RetryOn5xx}, classes)
+
+	classes, err = whereami.ParseRetryClasses("")
+
+	require.NoError(t, err)
+	assert.Empty(t, classes)
+
+	_, err = whereami.ParseRetryClasses("network,4xx")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown retry class: 4xx")
+}
+
+func TestWithRetry(t *testing.T) {
+	t.Parallel()
+
+	var count int32
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch atomic.AddInt32(&count, 1) {
+		case 1:
+			// Close to not let the HTTP client retry with the reused connection
+			w.Header().Set("Connection", "close")
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			// Close the connection without response
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+
+			conn.Close()
+		default:
+			fmt.Fprint(w, `{"ip": "123.123.123.123"}`)
+		}
+	}))
+	defer dummySrv.Close()
+
+	prov := ipifyorg.New()
+	prov.SetURL(dummySrv.URL)
+
+	policy := whereami.DefaultRetryPolicy()
+	policy.BaseDelay = 20 * time.Millisecond
+	policy.Jitter = 0
+
+	logger := info.New()
+	resolver := whereami.New(
+		whereami.WithProviders(prov),
+		whereami.WithRetry(policy),
+		whereami.WithLogger(logger),
+	)
+
+	timeStart := time.Now()
+	result, err := resolver.Resolve(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "123.123.123.123", result.IP.String())
+	require.Len(t, result.Answers, 1)
+	assert.Equal(t, 3, result.Answers[0].Attempts)
+	assert.GreaterOrEqual(t, int64(time.Since(timeStart)), int64(60*time.Millisecond),
+		"it should wait 20ms then 40ms before the retries")
+
+	logs := logger.Get()
+
+	assert.Contains(t, logs, "attempt 1/3 failed. retry in 20ms")
+	assert.Contains(t, logs, "class=5xx")
+	assert.Contains(t, logs, "attempt 2/3 failed. retry in 40ms")
+	assert.Contains(t, logs, "class=network")
+}
+
+func TestWithRetry_not_transient(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		handler http.HandlerFunc
+		name    string
+		on      []whereami.RetryClass
+	}{
+		{
+			name: "4xx",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+		},
+		{
+			name: "rate limited",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+		},
+		{
+			name: "parse error",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				fmt.Fprint(w, `{"ip": "not an IP"}`)
+			},
+		},
+		{
+			name: "class not selected",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			on: []whereami.RetryClass{whereami.RetryOnNetwork},
+		},
+	} {
+		var count int32
+
+		handler := test.handler
+		dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&count, 1)
+			handler(w, req)
+		}))
+
+		prov := ipifyorg.New()
+		prov.SetURL(dummySrv.URL)
+
+		policy := whereami.DefaultRetryPolicy()
+		policy.BaseDelay = time.Millisecond
+
+		if test.on != nil {
+			policy.On = test.on
+		}
+
+		result, err := whereami.New(
+			whereami.WithProviders(prov),
+			whereami.WithRetry(policy),
+			whereami.WithLogger(nil),
+		).Resolve(context.Background())
+
+		dummySrv.Close()
+
+		require.Error(t, err, test.name)
+		assert.Equal(t, 1, result.Answers[0].Attempts, test.name)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&count), "%v: it should not retry", test.name)
+	}
+}
+
+func TestWithRetry_canceled(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer dummySrv.Close()
+
+	prov := ipifyorg.New()
+	prov.SetURL(dummySrv.URL)
+
+	policy := whereami.DefaultRetryPolicy()
+	policy.BaseDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	result, err := whereami.New(
+		whereami.WithProviders(prov),
+		whereami.WithRetry(policy),
+		whereami.WithLogger(nil),
+	).Resolve(ctx)
+
+	require.Error(t, err)
+	assert.Equal(t, 1, result.Answers[0].Attempts, "it should stop waiting to retry on cancel")
+}
@@ -0,0 +1,238 @@
+package whereami
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/pkg/errors"
+)
+
+// Errors of the secure mode. See WithSecureOnly.
+var (
+	// ErrInsecure is the error when a plaintext request is about to be sent in
+	// the secure mode. Such as redirected to http://.
+	ErrInsecure = errors.New("plaintext HTTP is not allowed in the secure mode")
+	// ErrPinMismatch is the error when the public key of the certificate does
+	// not match any of the pins of the host.
+	ErrPinMismatch = errors.New("public key of the certificate does not match the pins")
+)
+
+// ============================================================================
+//  Type: SecurePolicy
+// ============================================================================
+
+// SecurePolicy is the policy to let only the providers over TLS vote. So that
+// the answers can not be forged on the path.
+//
+// The providers whose name (URL) does not start with "https://" are excluded,
+// or upgraded to HTTPS if Upgrade is true. The plaintext requests, such as the
+// redirects to http://, are refused.
+type SecurePolicy struct {
+	// Pins are the pins of the certificate per host of the providers. Each pin is
+	// the base64 encoded SHA-256 hash of the SubjectPublicKeyInfo. One of the
+	// certificates in the chain must match one of the pins of the host. The host
+	// without pins accepts any certificate verified by the root CAs.
+	Pins map[string][]string
+	// MinVersion is the minimum TLS version. Such as tls.VersionTLS13. Zero
+	// means tls.VersionTLS12.
+	MinVersion uint16
+	// Upgrade requests the plaintext providers over HTTPS instead of excluding
+	// them.
+	Upgrade bool
+}
+
+// Returns the providers allowed by the policy. The excluded ones are logged.
+func (r *Resolver) secureProviders(providers []provider.Provider) []provider.Provider {
+	if r.secure == nil {
+		return providers
+	}
+
+	list := make([]provider.Provider, 0, len(providers))
+
+	for _, prov := range providers {
+		if isHTTPS(prov.Name()) || r.secure.Upgrade {
+			list = append(list, prov)
+
+			continue
+		}
+
+		r.logger.Info("skipped: plaintext provider is not allowed in the secure mode", "provider", prov.Name())
+	}
+
+	return list
+}
+
+// Returns the HTTP client which enforces the policy over the client of the
+// resolver. It returns the client as is if the policy is not set. The client is
+// created once to reuse the connections.
+func (r *Resolver) secureClient() (*http.Client, error) {
+	if r.secure == nil {
+		return r.client, nil
+	}
+
+	r.onceSecure.Do(func() {
+		r.clientSecure, r.errSecure = r.newSecureClient()
+	})
+
+	return r.clientSecure, r.errSecure
+}
+
+// Returns a new HTTP client of the policy.
+func (r *Resolver) newSecureClient() (*http.Client, error) {
+	client := &http.Client{}
+	if r.client != nil {
+		*client = *r.client
+	}
+
+	roundTripper := client.Transport
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+
+	base, ok := roundTripper.(*http.Transport)
+	if !ok {
+		return nil, errors.Errorf("the secure mode requires *http.Transport as the transport of the client. given: %T",
+			roundTripper)
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base.TLSClientConfig != nil {
+		config = base.TLSClientConfig.Clone()
+	}
+
+	config.MinVersion = r.secure.MinVersion
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+
+	secure := &secureTransport{
+		base:    withTLSConfig(base, config),
+		pinned:  make(map[string]http.RoundTripper, len(r.secure.Pins)),
+		upgrade: r.secure.Upgrade,
+	}
+
+	// Transport per pinned host, since the host is unknown to the TLS config if
+	// it is an IP address
+	for host, pins := range r.secure.Pins {
+		configPinned := config.Clone()
+		configPinned.VerifyConnection = verifyPins(host, pins)
+
+		secure.pinned[strings.ToLower(host)] = withTLSConfig(base, configPinned)
+	}
+
+	client.Transport = secure
+
+	return client, nil
+}
+
+// ============================================================================
+//  Type: secureTransport
+// ============================================================================
+
+// secureTransport is the http.RoundTripper which refuses or upgrades the
+// plaintext requests.
+type secureTransport struct {
+	base    http.RoundTripper
+	pinned  map[string]http.RoundTripper // transports of the pinned hosts
+	upgrade bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *secureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch {
+	case req.URL.Scheme == "https":
+	case t.upgrade && req.URL.Scheme == "http":
+		req = upgrade(req)
+	default:
+		return nil, errors.Wrapf(ErrInsecure, "refused to request %v", req.URL.Redacted())
+	}
+
+	transport, ok := t.pinned[strings.ToLower(req.URL.Hostname())]
+	if !ok {
+		transport = t.base
+	}
+
+	return transport.RoundTrip(req) //nolint:wrapcheck // return the error of the transport as is
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// ParsePins returns the pins of the comma separated "host=pin" pairs. Such as
+// "ipinfo.io=<base64>,ipinfo.io=<base64>". See netutil.ParsePin for the pin.
+// An empty string returns no pin.
+func ParsePins(pairs string) (map[string][]string, error) {
+	pins := make(map[string][]string)
+
+	for _, pair := range strings.Split(pairs, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("malformed pin: %v (expected: host=base64 SHA-256 of the public key)", pair)
+		}
+
+		host := strings.ToLower(strings.TrimSpace(parts[0]))
+
+		pin, err := netutil.ParsePin(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pin of %v", host)
+		}
+
+		pins[host] = append(pins[host], pin)
+	}
+
+	return pins, nil
+}
+
+// Returns true if the name of the provider is a URL of HTTPS.
+func isHTTPS(name string) bool {
+	endpoint, err := url.Parse(name)
+
+	return err == nil && strings.EqualFold(endpoint.Scheme, "https")
+}
+
+// Returns a copy of the req with the scheme upgraded to HTTPS.
+func upgrade(req *http.Request) *http.Request {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "https"
+	req.Host = ""
+
+	// Drop the default port of HTTP
+	if req.URL.Port() == "80" {
+		req.URL.Host = req.URL.Hostname()
+		if strings.Contains(req.URL.Host, ":") {
+			req.URL.Host = "[" + req.URL.Host + "]"
+		}
+	}
+
+	return req
+}
+
+// Returns the function for tls.Config.VerifyConnection which checks the
+// verified chains against the pins of the host. See netutil.MatchPins.
+func verifyPins(host string, pins []string) func(state tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		if netutil.MatchPins(state.VerifiedChains, pins) {
+			return nil
+		}
+
+		return errors.Wrapf(ErrPinMismatch, "host %v", host)
+	}
+}
+
+// Returns a copy of the transport with the TLS config.
+func withTLSConfig(base *http.Transport, config *tls.Config) *http.Transport {
+	transport := base.Clone()
+	transport.TLSClientConfig = config
+
+	return transport
+}
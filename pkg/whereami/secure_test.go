@@ -0,0 +1,357 @@
+package whereami_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/provider/providers/ipifyorg"
+	"github.com/KEINOS/whereami/pkg/whereami"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePins(t *testing.T) {
+	t.Parallel()
+
+	pin1 := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+	pin2 := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", sha256.Size)))
+
+	pins, err := whereami.ParsePins(" IPInfo.io=" + pin1 + ", ipinfo.io=sha256/" + pin2 + ",,api64.ipify.org=" + pin1)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"ipinfo.io":       {pin1, pin2},
+		"api64.ipify.org": {pin1},
+	}, pins)
+
+	pins, err = whereami.ParsePins("")
+
+	require.NoError(t, err)
+	assert.Empty(t, pins)
+
+	for _, input := range []string{"ipinfo.io", "=" + pin1, "ipinfo.io=not-base64", "ipinfo.io=YWJj"} {
+		pins, err := whereami.ParsePins(input)
+
+		require.Error(t, err, "input: %v", input)
+		assert.Nil(t, pins)
+		assert.Contains(t, err.Error(), "malformed pin", "input: %v", input)
+	}
+}
+
+func TestWithSecureOnly_excludes_plaintext(t *testing.T) {
+	t.Parallel()
+
+	srvTLS := newIPServer(t, true, "123.123.123.123")
+	srvPlain := newIPServer(t, false, "10.10.10.10")
+
+	resolver := whereami.New(
+		whereami.WithProviders(newIPProvider(srvPlain.URL), newIPProvider(srvTLS.URL)),
+		whereami.WithQuorum(2),
+		whereami.WithHTTPClient(srvTLS.Client()),
+		whereami.WithSecureOnly(whereami.SecurePolicy{}),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "123.123.123.123", result.IP.String())
+	require.Len(t, result.Answers, 1, "the plaintext provider should not be requested")
+	assert.Equal(t, srvTLS.URL, result.Answers[0].Provider)
+	assert.Equal(t, 1, result.Quorum, "quorum should be limited by the providers allowed")
+}
+
+func TestWithSecureOnly_all_plaintext(t *testing.T) {
+	t.Parallel()
+
+	resolver := whereami.New(
+		whereami.WithProviders(newDummy(0, "123.123.123.123")),
+		whereami.WithSecureOnly(whereami.SecurePolicy{}),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, whereami.ErrNoProviders)
+}
+
+func TestWithSecureOnly_upgrade(t *testing.T) {
+	t.Parallel()
+
+	srvTLS := newIPServer(t, true, "123.123.123.123")
+
+	// Plaintext URL of the HTTPS server
+	urlPlain := "http://" + strings.TrimPrefix(srvTLS.URL, "https://")
+
+	resolver := whereami.New(
+		whereami.WithProviders(newIPProvider(urlPlain)),
+		whereami.WithHTTPClient(srvTLS.Client()),
+		whereami.WithSecureOnly(whereami.SecurePolicy{Upgrade: true}),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.NoError(t, err, "the request should be upgraded to HTTPS")
+	assert.Equal(t, "123.123.123.123", result.IP.String())
+}
+
+func TestWithSecureOnly_refuse_redirect_to_plaintext(t *testing.T) {
+	t.Parallel()
+
+	srvPlain := newIPServer(t, false, "10.10.10.10")
+	srvTLS := httptest.NewTLSServer(http.RedirectHandler(srvPlain.URL, http.StatusFound))
+
+	t.Cleanup(srvTLS.Close)
+
+	resolver := whereami.New(
+		whereami.WithProviders(newIPProvider(srvTLS.URL)),
+		whereami.WithHTTPClient(srvTLS.Client()),
+		whereami.WithSecureOnly(whereami.SecurePolicy{}),
+		whereami.WithLogger(nil),
+	)
+
+	_, err := resolver.Resolve(context.Background())
+
+	require.Error(t, err)
+
+	errAnswer := firstAnswerErr(t, err)
+
+	assert.ErrorIs(t, errAnswer, whereami.ErrInsecure)
+	assert.Contains(t, errAnswer.Error(), "refused to request "+srvPlain.URL)
+}
+
+func TestWithSecureOnly_pins(t *testing.T) {
+	t.Parallel()
+
+	srvTLS := newIPServer(t, true, "123.123.123.123")
+	cert := srvTLS.Certificate()
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	pinGood := base64.StdEncoding.EncodeToString(hash[:])
+	pinBad := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	resolve := func(pins ...string) error {
+		resolver := whereami.New(
+			whereami.WithProviders(newIPProvider(srvTLS.URL)),
+			whereami.WithHTTPClient(srvTLS.Client()),
+			whereami.WithSecureOnly(whereami.SecurePolicy{Pins: map[string][]string{"127.0.0.1": pins}}),
+			whereami.WithLogger(nil),
+		)
+
+		_, err := resolver.Resolve(context.Background())
+
+		return err
+	}
+
+	require.NoError(t, resolve(pinBad, pinGood), "one of the pins should match")
+
+	err := resolve(pinBad)
+
+	require.Error(t, err)
+	assert.ErrorIs(t, firstAnswerErr(t, err), whereami.ErrPinMismatch)
+}
+
+func TestWithSecureOnly_pins_not_in_chain(t *testing.T) {
+	t.Parallel()
+
+	// The leaf is signed by a CA the client trusts, but is not pinned. The
+	// pinned certificate is sent along, though not part of the chain. As a MITM
+	// with a trusted CA could do.
+	certCA, keyCA := newCert(t, nil, nil, true)
+	certLeaf, keyLeaf := newCert(t, certCA, keyCA, false)
+	certPinned, _ := newCert(t, nil, nil, true)
+
+	hash := sha256.Sum256(certPinned.RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(hash[:])
+
+	srvTLS := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"ip": "123.123.123.123"}`)
+	}))
+	srvTLS.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{certLeaf.Raw, certPinned.Raw},
+			PrivateKey:  keyLeaf,
+		}},
+		MinVersion: tls.VersionTLS12,
+	}
+	srvTLS.StartTLS()
+
+	t.Cleanup(srvTLS.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(certCA)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // test
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+
+	resolve := func(pins ...string) error {
+		resolver := whereami.New(
+			whereami.WithProviders(newIPProvider(srvTLS.URL)),
+			whereami.WithHTTPClient(&http.Client{Transport: transport}),
+			whereami.WithSecureOnly(whereami.SecurePolicy{Pins: map[string][]string{"127.0.0.1": pins}}),
+			whereami.WithLogger(nil),
+		)
+
+		_, err := resolver.Resolve(context.Background())
+
+		return err
+	}
+
+	err := resolve(pin)
+
+	require.Error(t, err, "the pinned certificate out of the chain should not match")
+	assert.ErrorIs(t, firstAnswerErr(t, err), whereami.ErrPinMismatch)
+
+	hash = sha256.Sum256(certCA.RawSubjectPublicKeyInfo)
+
+	require.NoError(t, resolve(base64.StdEncoding.EncodeToString(hash[:])), "the CA in the chain should match")
+}
+
+func TestWithSecureOnly_min_version(t *testing.T) {
+	t.Parallel()
+
+	srvTLS := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"ip": "123.123.123.123"}`)
+	}))
+	srvTLS.TLS = &tls.Config{MaxVersion: tls.VersionTLS12} //nolint:gosec // old version on purpose
+	srvTLS.StartTLS()
+
+	t.Cleanup(srvTLS.Close)
+
+	resolve := func(minVersion uint16) error {
+		resolver := whereami.New(
+			whereami.WithProviders(newIPProvider(srvTLS.URL)),
+			whereami.WithHTTPClient(srvTLS.Client()),
+			whereami.WithSecureOnly(whereami.SecurePolicy{MinVersion: minVersion}),
+			whereami.WithLogger(nil),
+		)
+
+		_, err := resolver.Resolve(context.Background())
+
+		return err
+	}
+
+	require.NoError(t, resolve(0), "TLS 1.2 should be allowed by default")
+	require.Error(t, resolve(tls.VersionTLS13), "TLS 1.2 should be refused if 1.3 is required")
+}
+
+func TestWithSecureOnly_unsupported_transport(t *testing.T) {
+	t.Parallel()
+
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}
+
+	resolver := whereami.New(
+		whereami.WithProviders(newIPProvider("https://dummy.com/")),
+		whereami.WithHTTPClient(client),
+		whereami.WithSecureOnly(whereami.SecurePolicy{}),
+		whereami.WithLogger(nil),
+	)
+
+	_, err := resolver.Resolve(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the secure mode requires *http.Transport")
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+// Returns a new certificate of 127.0.0.1 with its key. It is signed by the
+// parent, or self-signed if the parent is nil.
+func newCert(t *testing.T, parent *x509.Certificate, keyParent *ecdsa.PrivateKey, isCA bool,
+) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Dummy " + serial.String()}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	if parent == nil {
+		parent, keyParent = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, keyParent)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, key
+}
+
+// Returns the error of the first answer in the disagreement error.
+func firstAnswerErr(t *testing.T, err error) error {
+	t.Helper()
+
+	var errDisagree *whereami.DisagreementError
+
+	require.ErrorAs(t, err, &errDisagree)
+	require.NotEmpty(t, errDisagree.Result.Answers)
+
+	return errDisagree.Result.Answers[0].Err
+}
+
+// Returns the provider which requests the url.
+func newIPProvider(url string) provider.Provider {
+	prov := ipifyorg.New()
+	prov.SetURL(url)
+
+	return prov
+}
+
+// Starts the server which responds the ip in ipify.org style. It is closed on
+// cleanup.
+func newIPServer(t *testing.T, isTLS bool, ip string) *httptest.Server {
+	t.Helper()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, `{"ip": %q}`, ip)
+	})
+
+	srv := httptest.NewServer(handler)
+	if isTLS {
+		srv.Close()
+		srv = httptest.NewTLSServer(handler)
+	}
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
@@ -0,0 +1,564 @@
+/*
+Package whereami detects the current global/public IP address of the machine,
+by the consensus of multiple public IP address detection service providers.
+
+It is the library behind the "whereami" command, so that Go programs can
+embed it instead of running the binary.
+
+	resolver := whereami.New(
+		whereami.WithQuorum(2),
+		whereami.WithTimeout(5 * time.Second),
+	)
+
+	result, err := resolver.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(result.IP)
+*/
+package whereami
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/health"
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/ipaddr"
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/provider/base"
+	"github.com/pkg/errors"
+)
+
+const (
+	// QuorumDefault is the default number of providers that must return the
+	// same IP address to be trusted.
+	QuorumDefault = 3
+	// TimeoutDefault is the default timeout of each request to the provider.
+	TimeoutDefault = 10 * time.Second
+)
+
+// Errors of the resolver. Use errors.Is to check the kind of the error. The
+// errors of each provider in Result.Answers are of the provider package, such
+// as provider.ErrTimeout.
+var (
+	// ErrNoProviders is the error when there is no provider to request or the
+	// quorum is less than 1.
+	ErrNoProviders = errors.New("error: zero provider. you need at least one provider")
+	// ErrNoConsensus is the error when the providers did not reach the quorum.
+	// The returned error is *DisagreementError which holds the answers.
+	ErrNoConsensus = errors.New("no consensus reached between the providers")
+	// ErrNonGlobal is the error of the answer when the provider returned a
+	// non-global IP address. Such as private or CGNAT. See WithAllowPrivate.
+	ErrNonGlobal = errors.New("non-global IP address")
+)
+
+// ============================================================================
+//  Type: Resolver
+// ============================================================================
+
+// Resolver detects the global/public IP address via the providers.
+//
+// It requests the providers in the order of the policy (random by default) and
+// returns the first IP address returned by "quorum" number of providers. It is
+// safe for concurrent use.
+type Resolver struct {
+	client       *http.Client
+	clientSecure *http.Client // client of the secure policy. See secureClient
+	errSecure    error
+	health       *health.Tracker
+	logger       *info.Logger
+	rnd          *rand.Rand
+	policy       Policy
+	providers    []provider.Provider
+	retry        RetryPolicy
+	secure       *SecurePolicy
+	quorum       int
+	cursor       int // position of the round robin without the health records
+	timeout      time.Duration
+	muRnd        sync.Mutex
+	onceSecure   sync.Once
+	allowPrivate bool
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// New returns a new Resolver with the given options applied.
+//
+// By default it uses all the built-in providers (provider.GetAll), QuorumDefault
+// and TimeoutDefault. The logs are written to the default logger of the info
+// package. Use WithLogger to separate the logs per resolver.
+func New(opts ...Option) *Resolver {
+	resolver := &Resolver{
+		providers: provider.GetAll(),
+		quorum:    QuorumDefault,
+		timeout:   TimeoutDefault,
+		policy:    PolicyRandom,
+		logger:    info.Default(),
+		//nolint:gosec // Weak random is enough to shuffle the providers
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, opt := range opts {
+		opt(resolver)
+	}
+
+	return resolver
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Resolver
+// ----------------------------------------------------------------------------
+
+// Resolve requests the providers in the order of the policy until the quorum
+// number of providers return the same IP address.
+//
+// On error, the returned Result is still available (if not nil) to see what
+// each provider answered.
+func (r *Resolver) Resolve(ctx context.Context) (*Result, error) {
+	providers := r.secureProviders(r.available(r.order()))
+
+	if r.quorum < 1 || len(providers) == 0 {
+		return nil, errors.WithStack(ErrNoProviders)
+	}
+
+	quorum := r.quorum
+	if quorum > len(providers) {
+		quorum = len(providers)
+	}
+
+	client, err := r.secureClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if client != nil {
+		ctx = netutil.WithClient(ctx, client)
+	}
+
+	// Let the providers log to the logger of this resolver
+	ctx = info.NewContext(ctx, r.logger)
+
+	result := &Result{Quorum: quorum}
+	foundIP := make(map[string]int)
+
+	for _, prov := range providers {
+		answer := r.requestWithRetry(ctx, prov)
+
+		result.Answers = append(result.Answers, answer)
+
+		// Do not blame the provider if the caller gave up
+		if r.health != nil && ctx.Err() == nil {
+			r.health.Record(prov.Name(), answer.Duration, answer.Err)
+		}
+
+		r.holdRateLimited(prov, answer.Err)
+
+		if answer.Err != nil {
+			r.logger.Warn(fmt.Sprintf("%v: %v", prov.Name(), answer.Err.Error()),
+				"provider", prov.Name(),
+				"duration", answer.Duration,
+			)
+
+			if ctx.Err() != nil {
+				return result, errors.Wrap(ctx.Err(), "failed to resolve the global/public IP")
+			}
+
+			continue
+		}
+
+		r.logger.Info(fmt.Sprintf("Provider %v returned the global/public IP as: %v", prov.Name(), answer.IP),
+			"provider", prov.Name(),
+			"ip", answer.IP.String(),
+			"class", string(answer.Class),
+			"duration", answer.Duration,
+		)
+
+		// Vote per family if the provider showed both IPv4 and IPv6
+		for _, ip := range answer.votes() {
+			key := ip.String()
+
+			foundIP[key]++
+
+			r.logger.Debug("vote tally", "ip", key, "votes", foundIP[key], "quorum", quorum)
+
+			if foundIP[key] == quorum {
+				result.IP = ip
+
+				r.logger.Info("decision: quorum reached", "ip", key, "votes", foundIP[key], "quorum", quorum)
+
+				return result, nil // IP Found!
+			}
+		}
+	}
+
+	r.logger.Error("decision: no quorum reached", "answers", len(result.Answers), "quorum", quorum)
+
+	return result, newDisagreementError(result)
+}
+
+// Returns the providers whose circuit breaker is not open. If all of them are
+// open, it returns the given list as is since it is better than nothing.
+func (r *Resolver) available(providers []provider.Provider) []provider.Provider {
+	if r.health == nil {
+		return providers
+	}
+
+	list := make([]provider.Provider, 0, len(providers))
+
+	for _, prov := range providers {
+		if r.health.Allow(prov.Name()) {
+			list = append(list, prov)
+
+			continue
+		}
+
+		stats, _ := r.health.Get(prov.Name())
+
+		r.logger.Info("skipped: circuit breaker is open",
+			"provider", prov.Name(),
+			"until", stats.OpenUntil.Format(time.RFC3339),
+			"last_error", stats.LastError,
+		)
+	}
+
+	if len(list) == 0 {
+		return providers
+	}
+
+	return list
+}
+
+// Skips the provider until the time to retry if it is rate limiting. It only
+// logs if the health is not tracked.
+func (r *Resolver) holdRateLimited(prov provider.Provider, err error) {
+	retryAfter, ok := base.RetryAfterOf(err)
+	if !ok {
+		return
+	}
+
+	until := health.TimeNow().Add(retryAfter)
+
+	r.logger.Warn(fmt.Sprintf("%v: rate limited. retry after %v", prov.Name(), retryAfter),
+		"provider", prov.Name(),
+		"retry_after", retryAfter,
+		"until", until.Format(time.RFC3339),
+	)
+
+	if r.health != nil {
+		r.health.Hold(prov.Name(), until)
+	}
+}
+
+// Calls GetIP method of the provider and returns the answer. The ASN and the
+// owner are set if the provider knows.
+func (r *Resolver) request(ctx context.Context, prov provider.Provider) Answer {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	timeStart := time.Now()
+	details, err := provider.GetDetailsContext(ctx, prov)
+	answer := Answer{
+		Provider: prov.Name(),
+		Duration: time.Since(timeStart),
+	}
+
+	switch {
+	case err != nil:
+		if isTimeout(ctx, err) && !errors.Is(err, provider.ErrTimeout) {
+			err = base.Mark(err, provider.ErrTimeout)
+		}
+
+		answer.Err = errors.Wrapf(err, "provider %v returned an error", prov.Name())
+	case details == nil || details.IP == nil:
+		answer.Err = base.Mark(errors.Errorf("provider %v returned an empty IP address", prov.Name()), provider.ErrParse)
+	default:
+		answer.Class = ipaddr.Classify(details.IP)
+
+		if !answer.Class.IsGlobal() && !r.allowPrivate {
+			answer.Err = base.Mark(errors.Errorf("provider %v returned a non-global IP address: %v (%v)",
+				prov.Name(), details.IP, answer.Class), ErrNonGlobal)
+
+			break
+		}
+
+		answer.IP = details.IP
+		answer.IPs = r.globalIPs(prov, details.IPs)
+		answer.ASN = details.ASN
+		answer.Owner = details.Owner
+	}
+
+	return answer
+}
+
+// Returns the IP addresses of both families which are allowed to vote. It
+// returns nil if less than two are left, since the IP field is enough then.
+func (r *Resolver) globalIPs(prov provider.Provider, ips []net.IP) []net.IP {
+	list := make([]net.IP, 0, len(ips))
+
+	for _, ip := range ips {
+		if class := ipaddr.Classify(ip); class.IsGlobal() || r.allowPrivate {
+			list = append(list, ip)
+
+			continue
+		}
+
+		r.logger.Info("ignored: non-global IP address of the other family",
+			"provider", prov.Name(), "ip", ip.String(), "class", string(ipaddr.Classify(ip)))
+	}
+
+	if len(list) < 2 {
+		return nil
+	}
+
+	return list
+}
+
+// Returns true if the err is due to the timeout of the ctx or the network.
+func isTimeout(ctx context.Context, err error) bool {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var errNet net.Error
+
+	return errors.As(err, &errNet) && errNet.Timeout()
+}
+
+// Returns a copy of the providers in random order.
+func (r *Resolver) shuffle() []provider.Provider {
+	list := make([]provider.Provider, len(r.providers))
+	copy(list, r.providers)
+
+	r.muRnd.Lock()
+	defer r.muRnd.Unlock()
+
+	r.rnd.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+
+	return list
+}
+
+// ============================================================================
+//  Type: Option
+// ============================================================================
+
+// Option configures the Resolver.
+type Option func(*Resolver)
+
+// WithHTTPClient sets the HTTP client to request the providers. Such as the one
+// with custom transport or proxy settings. If nil, http.DefaultClient is used.
+func WithHTTPClient(client *http.Client) Option {
+	return func(r *Resolver) {
+		r.client = client
+	}
+}
+
+// WithAllowPrivate allows the non-global IP addresses to vote if true. Such as
+// the private, CGNAT, loopback or documentation addresses in the lab networks.
+// See ipaddr.Class for the classes.
+//
+// By default, they are rejected as ErrNonGlobal since a misconfigured proxy may
+// echo back the internal address.
+func WithAllowPrivate(allow bool) Option {
+	return func(r *Resolver) {
+		r.allowPrivate = allow
+	}
+}
+
+// WithHealth sets the tracker to record the health of the providers. The
+// providers with the open circuit breaker are skipped. If nil (default), the
+// health is not tracked.
+//
+// Note that the tracker is not saved by the resolver. Call Save method of the
+// tracker to persist it.
+func WithHealth(tracker *health.Tracker) Option {
+	return func(r *Resolver) {
+		r.health = tracker
+	}
+}
+
+// WithLogger sets the logger to log the details of the process, including the
+// responses of the providers. The default is info.Default. If nil, nothing is
+// logged.
+func WithLogger(logger *info.Logger) Option {
+	return func(r *Resolver) {
+		r.logger = logger
+	}
+}
+
+// WithPolicy sets the ordering policy of the providers to request. The default
+// is PolicyRandom. See Policy for the details.
+func WithPolicy(policy Policy) Option {
+	return func(r *Resolver) {
+		r.policy = policy
+	}
+}
+
+// WithProviders sets the providers to request. The default is provider.GetAll.
+func WithProviders(providers ...provider.Provider) Option {
+	return func(r *Resolver) {
+		r.providers = providers
+	}
+}
+
+// WithQuorum sets the number of providers that must return the same IP address.
+// If it exceeds the number of providers, all the providers must agree.
+func WithQuorum(quorum int) Option {
+	return func(r *Resolver) {
+		r.quorum = quorum
+	}
+}
+
+// WithRetry sets the policy to retry the request to a provider on the transient
+// errors. Such as DefaultRetryPolicy. The default is no retry.
+//
+// The timeout of WithTimeout applies to each attempt.
+func WithRetry(policy RetryPolicy) Option {
+	return func(r *Resolver) {
+		r.retry = policy
+	}
+}
+
+// WithSecureOnly lets only the providers over TLS vote with the policy. Such as
+// SecurePolicy{MinVersion: tls.VersionTLS13}. The plaintext providers are
+// excluded, or upgraded to HTTPS if the policy says so. See SecurePolicy.
+//
+// The transport of the client set by WithHTTPClient must be *http.Transport.
+func WithSecureOnly(policy SecurePolicy) Option {
+	return func(r *Resolver) {
+		r.secure = &policy
+	}
+}
+
+// WithTimeout sets the timeout of each request to the provider. Zero means no
+// timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(r *Resolver) {
+		r.timeout = timeout
+	}
+}
+
+// ============================================================================
+//  Type: Result
+// ============================================================================
+
+// Result is the outcome of Resolve.
+type Result struct {
+	// IP is the global/public IP address agreed by the quorum. nil on error.
+	IP net.IP `json:"ip"`
+	// Answers are the answers of the requested providers in requested order.
+	Answers []Answer `json:"answers"`
+	// Quorum is the number of providers needed to agree.
+	Quorum int `json:"quorum"`
+}
+
+// Votes returns the providers per IP address answered, in the order of the
+// first appearance. Each IP address is annotated with its ASN and owner if any
+// provider knows.
+func (res *Result) Votes() []Vote {
+	var votes []Vote
+
+	index := make(map[string]int)
+
+	for _, answer := range res.Answers {
+		for _, ip := range answer.votes() {
+			key := ip.String()
+
+			i, ok := index[key]
+			if !ok {
+				i = len(votes)
+				index[key] = i
+
+				votes = append(votes, Vote{IP: ip})
+			}
+
+			votes[i].Providers = append(votes[i].Providers, answer.Provider)
+
+			// Annotate with the first known ones. The details are of the IP field
+			if !ip.Equal(answer.IP) {
+				continue
+			}
+
+			if votes[i].ASN == 0 {
+				votes[i].ASN = answer.ASN
+			}
+
+			if votes[i].Owner == "" {
+				votes[i].Owner = answer.Owner
+			}
+		}
+	}
+
+	return votes
+}
+
+// ============================================================================
+//  Type: Answer
+// ============================================================================
+
+// Answer is the response of a provider.
+type Answer struct {
+	// Err is the error occurred during the request. nil on success.
+	Err error `json:"-"`
+	// Provider is the name of the provider.
+	Provider string `json:"provider"`
+	// IP is the IP address returned by the provider. nil on error.
+	IP net.IP `json:"ip,omitempty"`
+	// IPs are the global IP addresses of both families if the provider showed
+	// IPv4 and IPv6 at once. Each of them votes. Empty if only IP is shown.
+	IPs []net.IP `json:"ips,omitempty"`
+	// Owner is the owner of the IP address if the provider knows.
+	Owner string `json:"owner,omitempty"`
+	// Class is the class of the IP address returned. Such as "global" or
+	// "private". Set even if the address was rejected.
+	Class ipaddr.Class `json:"class,omitempty"`
+	// ASN is the autonomous system number of the IP address if the provider
+	// knows.
+	ASN int `json:"asn,omitempty"`
+	// Duration is the time took to get the answer in the last attempt.
+	Duration time.Duration `json:"duration"`
+	// Attempts is the number of the requests sent to the provider. More than 1
+	// if retried. See WithRetry.
+	Attempts int `json:"attempts"`
+}
+
+// Returns the IP addresses the answer votes for. One per family.
+func (a Answer) votes() []net.IP {
+	switch {
+	case len(a.IPs) > 0:
+		return a.IPs
+	case a.IP != nil:
+		return []net.IP{a.IP}
+	}
+
+	return nil
+}
+
+// ============================================================================
+//  Type: Vote
+// ============================================================================
+
+// Vote is the tally of an IP address.
+type Vote struct {
+	// IP is the IP address voted.
+	IP net.IP `json:"ip"`
+	// Owner is the owner of the IP address. Empty if unknown.
+	Owner string `json:"owner,omitempty"`
+	// Providers are the names of the providers that answered the IP.
+	Providers []string `json:"providers"`
+	// ASN is the autonomous system number of the IP address. Zero if unknown.
+	ASN int `json:"asn,omitempty"`
+}
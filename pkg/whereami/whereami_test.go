@@ -0,0 +1,513 @@
+package whereami_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/health"
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/ipaddr"
+	"github.com/KEINOS/whereami/pkg/provider"
+	"github.com/KEINOS/whereami/pkg/provider/providers/ipifyorg"
+	"github.com/KEINOS/whereami/pkg/whereami"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Example() {
+	// Dummy provider which returns "123.123.123.123". See the bottom of this file.
+	dummyProvs := []provider.Provider{
+		newDummy(0, "123.123.123.123"),
+		newDummy(1, "123.123.123.123"),
+		newDummy(2, "123.123.123.123"),
+	}
+
+	resolver := whereami.New(
+		whereami.WithProviders(dummyProvs...), // Use default providers if not set
+		whereami.WithQuorum(2),                // Needs 2 providers to agree
+		whereami.WithTimeout(5*time.Second),   // Timeout of each request
+		whereami.WithLogger(nil),              // Disable logging
+	)
+
+	result, err := resolver.Resolve(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("IP:", result.IP)
+	fmt.Println("Number of requests:", len(result.Answers))
+
+	// Output:
+	// IP: 123.123.123.123
+	// Number of requests: 2
+}
+
+func TestResolve_shuffled(t *testing.T) {
+	t.Parallel()
+
+	var dummyProvs []provider.Provider
+
+	for i := 0; i < 5; i++ {
+		dummyProvs = append(dummyProvs, newDummy(i, "123.123.123.123"))
+	}
+
+	resolver := whereami.New(
+		whereami.WithProviders(dummyProvs...),
+		whereami.WithQuorum(len(dummyProvs)),
+		whereami.WithLogger(nil),
+	)
+
+	// Try several times since the shuffled order can be the same by chance.
+	resultOK := false
+
+	for try := 0; try < 10 && !resultOK; try++ {
+		result, err := resolver.Resolve(context.Background())
+		require.NoError(t, err)
+		require.Len(t, result.Answers, len(dummyProvs))
+
+		for index, answer := range result.Answers {
+			if answer.Provider != dummyProvs[index].Name() {
+				resultOK = true
+			}
+		}
+	}
+
+	require.True(t, resultOK, "the providers should be requested in random order")
+
+	// The original list must not be modified
+	for index, prov := range dummyProvs {
+		require.Equal(t, "http://dummy.com/"+strconv.Itoa(index), prov.Name(),
+			"it should not shuffle the given list")
+	}
+}
+
+func TestResolve_quorum_exceeds_providers(t *testing.T) {
+	t.Parallel()
+
+	resolver := whereami.New(
+		whereami.WithProviders(newDummy(0, "123.123.123.123")),
+		whereami.WithQuorum(3),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Quorum, "quorum should be the number of providers if exceeds")
+	assert.Equal(t, "123.123.123.123", result.IP.String())
+}
+
+func TestResolve_zero_provider(t *testing.T) {
+	t.Parallel()
+
+	for _, opt := range []whereami.Option{
+		whereami.WithQuorum(0),
+		whereami.WithProviders(),
+	} {
+		resolver := whereami.New(opt, whereami.WithLogger(nil))
+
+		result, err := resolver.Resolve(context.Background())
+
+		require.Error(t, err)
+		require.Nil(t, result)
+		assert.Contains(t, err.Error(), "you need at least one provider")
+		assert.ErrorIs(t, err, whereami.ErrNoProviders)
+	}
+}
+
+func TestResolve_non_global(t *testing.T) {
+	t.Parallel()
+
+	for _, ip := range []string{"10.0.0.1", "100.64.1.1", "127.0.0.1", "192.0.2.1", "fd00::1"} {
+		resolver := whereami.New(
+			whereami.WithProviders(newDummy(0, ip), newDummy(1, ip)),
+			whereami.WithQuorum(2),
+			whereami.WithLogger(nil),
+		)
+
+		result, err := resolver.Resolve(context.Background())
+
+		require.Error(t, err, "non-global IP address should not be trusted: %v", ip)
+		assert.ErrorIs(t, err, whereami.ErrNoConsensus)
+		require.Len(t, result.Answers, 2)
+		assert.Empty(t, result.Votes(), "non-global IP address should not vote")
+
+		for _, answer := range result.Answers {
+			assert.ErrorIs(t, answer.Err, whereami.ErrNonGlobal)
+			assert.Contains(t, answer.Err.Error(), "returned a non-global IP address: "+ip)
+			assert.False(t, answer.Class.IsGlobal())
+		}
+	}
+}
+
+func TestResolve_both_families(t *testing.T) {
+	t.Parallel()
+
+	// The page of the first provider shows both IPv4 and IPv6
+	dualStack := newDetails("http://dual.example.com/", "123.123.123.123", 0, "")
+	dualStack.details.IPs = []net.IP{net.ParseIP("123.123.123.123"), net.ParseIP("2001:4860:4860::8888")}
+
+	resolver := whereami.New(
+		whereami.WithProviders(dualStack, newDummy(1, "2001:4860:4860::8888")),
+		whereami.WithQuorum(2),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.NoError(t, err, "the IPv6 of the dual stack page should vote")
+	assert.Equal(t, "2001:4860:4860::8888", result.IP.String())
+	require.Len(t, result.Answers, 2)
+
+	for _, vote := range result.Votes() {
+		if vote.IP.To4() != nil {
+			assert.Equal(t, []string{"http://dual.example.com/"}, vote.Providers)
+
+			continue
+		}
+
+		assert.ElementsMatch(t, []string{"http://dual.example.com/", "http://dummy.com/1"}, vote.Providers)
+	}
+
+	for _, answer := range result.Answers {
+		if answer.Provider == "http://dual.example.com/" {
+			assert.Equal(t, "123.123.123.123", answer.IP.String())
+			assert.Len(t, answer.IPs, 2)
+		}
+	}
+}
+
+func TestResolve_both_families_non_global(t *testing.T) {
+	t.Parallel()
+
+	dualStack := newDetails("http://dual.example.com/", "123.123.123.123", 0, "")
+	dualStack.details.IPs = []net.IP{net.ParseIP("123.123.123.123"), net.ParseIP("fd00::1")}
+
+	resolver := whereami.New(
+		whereami.WithProviders(dualStack),
+		whereami.WithQuorum(1),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "123.123.123.123", result.IP.String())
+	assert.Empty(t, result.Answers[0].IPs, "non-global IP of the other family should not vote")
+}
+
+func TestWithAllowPrivate(t *testing.T) {
+	t.Parallel()
+
+	resolver := whereami.New(
+		whereami.WithProviders(newDummy(0, "10.0.0.1"), newDummy(1, "10.0.0.1")),
+		whereami.WithQuorum(2),
+		whereami.WithAllowPrivate(true),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", result.IP.String())
+	assert.Equal(t, ipaddr.ClassPrivate, result.Answers[0].Class, "it should be labeled")
+}
+
+func TestResolve_disagreement(t *testing.T) {
+	t.Parallel()
+
+	logger := info.New()
+
+	resolver := whereami.New(
+		whereami.WithProviders(
+			newDummy(0, "123.123.123.123"),
+			newDummy(1, "123.123.123.124"),
+			newDummy(2, ""),
+			&dummyProvider{id: 3, err: errors.New("forced error")},
+		),
+		whereami.WithQuorum(2),
+		whereami.WithLogger(logger),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "all returned IP addresses are different from each other")
+	assert.ErrorIs(t, err, whereami.ErrNoConsensus)
+
+	require.NotNil(t, result, "result should be available on disagreement")
+	assert.Nil(t, result.IP)
+	assert.Len(t, result.Answers, 4)
+	assert.Len(t, result.Votes(), 2, "two different IP addresses should be voted")
+
+	numErr := 0
+
+	for _, answer := range result.Answers {
+		if answer.Err != nil {
+			numErr++
+		}
+
+		if answer.Provider == newDummy(2, "").Name() {
+			assert.ErrorIs(t, answer.Err, provider.ErrParse, "empty IP should be a parse error")
+		}
+	}
+
+	assert.Equal(t, 2, numErr, "empty IP and the error should be an error answer")
+	assert.Contains(t, logger.Get(), "returned an empty IP address")
+	assert.Contains(t, logger.Get(), "forced error")
+	assert.Contains(t, logger.Get(), "ERROR: decision: no quorum reached answers=4 quorum=2")
+}
+
+func TestResolve_timeout(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done() // Never respond until the client gives up
+	}))
+	defer dummySrv.Close()
+
+	prov := ipifyorg.New()
+	prov.SetURL(dummySrv.URL)
+
+	resolver := whereami.New(
+		whereami.WithProviders(prov),
+		whereami.WithTimeout(10*time.Millisecond),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.Error(t, err)
+	require.Len(t, result.Answers, 1)
+	assert.ErrorIs(t, result.Answers[0].Err, context.DeadlineExceeded)
+	assert.ErrorIs(t, result.Answers[0].Err, provider.ErrTimeout)
+	assert.Less(t, int64(result.Answers[0].Duration), int64(time.Second), "it should not wait the slow provider")
+}
+
+func TestResolve_canceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resolver := whereami.New(
+		whereami.WithProviders(&dummyProvider{id: 0, delay: time.Second}, &dummyProvider{id: 1, delay: time.Second}),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(ctx)
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, result.Answers, 1, "it should stop requesting on cancel")
+}
+
+func TestWithHealth(t *testing.T) {
+	t.Parallel()
+
+	broken := &dummyProvider{id: 0, err: errors.New("forced error")}
+	healthy := newDummy(1, "123.123.123.123")
+
+	tracker := health.New()
+	tracker.Threshold = 1
+
+	logger := info.New()
+	resolver := whereami.New(
+		whereami.WithProviders(broken, healthy),
+		whereami.WithQuorum(1),
+		whereami.WithHealth(tracker),
+		whereami.WithLogger(logger),
+	)
+
+	// Resolve until the broken one is requested and its circuit is opened
+	for try := 0; try < 20 && tracker.Allow(broken.Name()); try++ {
+		_, err := resolver.Resolve(context.Background())
+		require.NoError(t, err)
+	}
+
+	require.False(t, tracker.Allow(broken.Name()), "the circuit of the broken provider should be opened")
+
+	for i := 0; i < 5; i++ {
+		result, err := resolver.Resolve(context.Background())
+
+		require.NoError(t, err)
+		require.Len(t, result.Answers, 1)
+		assert.Equal(t, healthy.Name(), result.Answers[0].Provider, "the broken provider should be skipped")
+	}
+
+	stats, ok := tracker.Get(healthy.Name())
+
+	require.True(t, ok)
+	assert.GreaterOrEqual(t, stats.Successes, 5, "the health should be recorded")
+	assert.Contains(t, logger.Get(), "skipped: circuit breaker is open provider="+broken.Name())
+}
+
+func TestWithHealth_rate_limited(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, "<html><body>Too Many Requests</body></html>")
+	}))
+	defer dummySrv.Close()
+
+	prov := ipifyorg.New()
+	prov.SetURL(dummySrv.URL)
+
+	tracker := health.New()
+	logger := info.New()
+	resolver := whereami.New(
+		whereami.WithProviders(prov),
+		whereami.WithHealth(tracker),
+		whereami.WithLogger(logger),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.Error(t, err)
+	require.Len(t, result.Answers, 1)
+	assert.ErrorIs(t, result.Answers[0].Err, provider.ErrRateLimited)
+	assert.Contains(t, result.Answers[0].Err.Error(), "HTML page of 43 bytes omitted", "HTML should not be in the logs")
+	assert.Contains(t, logger.Get(), "rate limited. retry after 2m0s")
+
+	require.False(t, tracker.Allow(prov.Name()), "it should skip the provider until the time to retry")
+}
+
+func TestWithHealth_all_open(t *testing.T) {
+	t.Parallel()
+
+	prov := newDummy(0, "123.123.123.123")
+
+	tracker := health.New()
+	tracker.Threshold = 1
+	tracker.Record(prov.Name(), time.Second, errors.New("forced error"))
+
+	require.False(t, tracker.Allow(prov.Name()))
+
+	resolver := whereami.New(
+		whereami.WithProviders(prov),
+		whereami.WithHealth(tracker),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.NoError(t, err, "all the providers should be requested if all are open")
+	assert.Equal(t, "123.123.123.123", result.IP.String())
+	assert.True(t, tracker.Allow(prov.Name()), "success should close the circuit")
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, `{"ip": "%v"}`, req.Header.Get("X-Dummy-IP"))
+	}))
+	defer dummySrv.Close()
+
+	prov := ipifyorg.New()
+	prov.SetURL(dummySrv.URL)
+
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Dummy-IP", "123.123.123.125")
+
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}
+
+	resolver := whereami.New(
+		whereami.WithProviders(prov),
+		whereami.WithHTTPClient(client),
+		whereami.WithLogger(nil),
+	)
+
+	result, err := resolver.Resolve(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "123.123.123.125", result.IP.String(), "it should request via the given client")
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+// dummyProvider is a dummy provider which implements provider.Provider.
+type dummyProvider struct {
+	err   error
+	ip    net.IP
+	id    int
+	delay time.Duration
+}
+
+func newDummy(id int, ip string) *dummyProvider {
+	return &dummyProvider{id: id, ip: net.ParseIP(ip)}
+}
+
+func (d *dummyProvider) GetIP() (net.IP, error) {
+	time.Sleep(d.delay)
+
+	return d.ip, d.err
+}
+
+func (d *dummyProvider) Name() string {
+	return "http://dummy.com/" + strconv.Itoa(d.id)
+}
+
+func (d *dummyProvider) SetURL(url string) {}
+
+// roundTripperFunc is a function which implements http.RoundTripper.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestWithLogger_separated_logs(t *testing.T) {
+	t.Parallel()
+
+	dummySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"ip": "123.123.123.123"}`)
+	}))
+	defer dummySrv.Close()
+
+	loggerA := info.New()
+	loggerB := info.New()
+
+	for _, logger := range []*info.Logger{loggerA, loggerB} {
+		prov := ipifyorg.New()
+		prov.SetURL(dummySrv.URL)
+
+		resolver := whereami.New(
+			whereami.WithProviders(prov),
+			whereami.WithLogger(logger),
+		)
+
+		_, err := resolver.Resolve(context.Background())
+		require.NoError(t, err)
+	}
+
+	for _, logger := range []*info.Logger{loggerA, loggerB} {
+		logs := logger.Get()
+
+		assert.Equal(t, 1, strings.Count(logs, "Response info:"),
+			"the response of the provider should be logged to the logger of the resolver")
+		assert.Equal(t, 1, strings.Count(logs, "returned the global/public IP as: 123.123.123.123"),
+			"each resolver should have its own log")
+		assert.Contains(t, logs, "DEBUG: request start provider="+dummySrv.URL)
+		assert.Contains(t, logs, "DEBUG: response status url="+dummySrv.URL+" status=200 duration=")
+		assert.Contains(t, logs, "DEBUG: vote tally ip=123.123.123.123 votes=1 quorum=1")
+		assert.Contains(t, logs, "decision: quorum reached ip=123.123.123.123 votes=1 quorum=1")
+	}
+}
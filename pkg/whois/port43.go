@@ -0,0 +1,220 @@
+package whois
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Default port number of WHOIS.
+	portWhoisDefault = "43"
+	// Max number of the referrals to follow.
+	referralMax = 3
+)
+
+// ============================================================================
+//  Methods for Client
+// ============================================================================
+
+// Returns the info from the WhoisServer, following the referrals to the server
+// of the registry.
+func (c *Client) lookupWhois(ctx context.Context, ip net.IP) (*Info, error) {
+	server := withPort(c.WhoisServer)
+	visited := map[string]bool{}
+
+	for i := 0; i <= referralMax; i++ {
+		visited[server] = true
+
+		text, err := c.queryWhois(ctx, server, ip.String())
+		if err != nil {
+			return nil, err
+		}
+
+		refer := parseReferral(text)
+		if refer != "" && !visited[refer] && i < referralMax {
+			info.FromContext(ctx).Debug("following the WHOIS referral", "from", server, "to", refer)
+
+			server = refer
+
+			continue
+		}
+
+		result := parseWhois(text)
+		if len(result.CIDRs) == 0 && result.Name == "" {
+			return nil, errors.Errorf("no network of %v found in the response of %v", ip, server)
+		}
+
+		result.IP = ip.String()
+		result.Registry = registryOf(server)
+		result.Server = server
+		result.Source = SourceWHOIS
+
+		return result, nil
+	}
+
+	return nil, errors.Errorf("too many WHOIS referrals from %v", c.WhoisServer)
+}
+
+// Returns the response of the WHOIS server to the query.
+func (c *Client) queryWhois(ctx context.Context, server, query string) (string, error) {
+	dial := c.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	conn, err := dial(ctx, "tcp", server)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to connect to the WHOIS server")
+	}
+
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return "", errors.Wrap(err, "failed to set the deadline")
+		}
+	}
+
+	if _, err := io.WriteString(conn, query+"\r\n"); err != nil {
+		return "", errors.Wrapf(err, "failed to send the query to %v", server)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(conn, bodySizeMax))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read the response of %v", server)
+	}
+
+	return string(data), nil
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Returns the WHOIS fields of the text. The keys are lowercased. If the key
+// repeats, the last one wins, since the more specific network comes later.
+// Such as the response of ARIN.
+func parseFields(text string) map[string]string {
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Such as "% Abuse contact for '...' is 'abuse@example.net'" of RIPE NCC
+		if strings.HasPrefix(line, "% Abuse contact for ") {
+			if parts := strings.Split(line, "'"); len(parts) >= 4 {
+				fields["% abuse"] = parts[3]
+			}
+
+			continue
+		}
+
+		if strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		idx := strings.Index(line, ":")
+		if idx < 1 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(line[:idx]))
+		value := strings.TrimSpace(line[idx+1:])
+
+		if value != "" {
+			fields[key] = value
+		}
+	}
+
+	return fields
+}
+
+// Returns the referral server in the text with the port number. Empty if none.
+func parseReferral(text string) string {
+	fields := parseFields(text)
+
+	for _, key := range []string{"refer", "referralserver", "whois"} {
+		refer := fields[key]
+		if refer == "" {
+			continue
+		}
+
+		// Such as "whois://whois.ripe.net" or "rwhois://rwhois.example.net:4321"
+		if idx := strings.Index(refer, "://"); idx >= 0 {
+			if refer[:idx] != "whois" {
+				continue
+			}
+
+			refer = refer[idx+3:]
+		}
+
+		return withPort(strings.TrimSuffix(refer, "/"))
+	}
+
+	return ""
+}
+
+// Returns the info parsed from the WHOIS response of the registries.
+func parseWhois(text string) *Info {
+	fields := parseFields(text)
+	result := new(Info)
+
+	pick := func(keys ...string) string {
+		for _, key := range keys {
+			if value := fields[key]; value != "" {
+				return value
+			}
+		}
+
+		return ""
+	}
+
+	block := pick("cidr", "inetnum", "inet6num", "netrange")
+
+	for _, cidr := range strings.Split(block, ",") {
+		cidr = strings.TrimSpace(cidr)
+
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			result.CIDRs = append(result.CIDRs, network.String())
+		}
+	}
+
+	// Such as "123.123.0.0 - 123.123.255.255"
+	if rng := pick("netrange", "inetnum"); strings.Contains(rng, "-") {
+		parts := strings.SplitN(rng, "-", 2)
+		first := net.ParseIP(strings.TrimSpace(parts[0]))
+		last := net.ParseIP(strings.TrimSpace(parts[1]))
+
+		if first != nil && last != nil {
+			result.Range = first.String() + " - " + last.String()
+
+			if result.CIDRs == nil {
+				result.CIDRs = rangeToCIDRs(first, last)
+			}
+		}
+	}
+
+	result.Name = pick("netname")
+	result.Handle = pick("nethandle", "nic-hdl")
+	result.Org = pick("orgname", "org-name", "owner", "organization", "descr")
+	result.Country = pick("country")
+	result.Abuse = pick("orgabuseemail", "abuse-mailbox", "% abuse", "e-mail")
+
+	return result
+}
+
+// Returns the address with the default port number of WHOIS if omitted.
+func withPort(address string) string {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return net.JoinHostPort(strings.Trim(address, "[]"), portWhoisDefault)
+	}
+
+	return address
+}
@@ -0,0 +1,331 @@
+package whois
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/KEINOS/whereami/pkg/netutil"
+	"github.com/pkg/errors"
+)
+
+// ============================================================================
+//  Types of RDAP
+// ============================================================================
+
+// bootstrap is the bootstrap registry of RDAP. Each service is a pair of the
+// prefixes and the base URLs of the servers.
+//
+// See: https://datatracker.ietf.org/doc/html/rfc9224
+type bootstrap struct {
+	Services [][][]string `json:"services"`
+}
+
+// rdapNetwork is the IP network object of RDAP.
+//
+// See: https://datatracker.ietf.org/doc/html/rfc9083#section-5.4
+type rdapNetwork struct {
+	ObjectClassName string       `json:"objectClassName"`
+	Handle          string       `json:"handle"`
+	StartAddress    string       `json:"startAddress"`
+	EndAddress      string       `json:"endAddress"`
+	Name            string       `json:"name"`
+	Country         string       `json:"country"`
+	Port43          string       `json:"port43"`
+	CIDRs           []rdapCIDR   `json:"cidr0_cidrs"`
+	Entities        []rdapEntity `json:"entities"`
+}
+
+// rdapCIDR is the CIDR of the network in the "cidr0" extension.
+type rdapCIDR struct {
+	V4Prefix string `json:"v4prefix"`
+	V6Prefix string `json:"v6prefix"`
+	Length   int    `json:"length"`
+}
+
+// rdapEntity is the entity object of RDAP. Such as the registrant or the abuse
+// contact.
+type rdapEntity struct {
+	Roles      []string      `json:"roles"`
+	VCardArray []interface{} `json:"vcardArray"`
+	Entities   []rdapEntity  `json:"entities"`
+}
+
+// Returns the value of the property of the vCard (jCard). Such as "fn" or
+// "email".
+func (e *rdapEntity) vcard(property string) string {
+	if len(e.VCardArray) < 2 {
+		return ""
+	}
+
+	props, ok := e.VCardArray[1].([]interface{})
+	if !ok {
+		return ""
+	}
+
+	for _, prop := range props {
+		// [name, parameters, type, value]
+		fields, ok := prop.([]interface{})
+		if !ok || len(fields) < 4 || fields[0] != property {
+			continue
+		}
+
+		if value, ok := fields[3].(string); ok {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// Returns the value of the vCard property of the first entity with the role,
+// searching the nested entities as well.
+func findEntity(entities []rdapEntity, role, property string) string {
+	for i := range entities {
+		for _, r := range entities[i].Roles {
+			if r == role {
+				if value := entities[i].vcard(property); value != "" {
+					return value
+				}
+			}
+		}
+	}
+
+	for i := range entities {
+		if value := findEntity(entities[i].Entities, role, property); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// ============================================================================
+//  Methods for Client
+// ============================================================================
+
+// Returns the info from the RDAP servers. The servers are tried in order until
+// one answers.
+func (c *Client) lookupRDAP(ctx context.Context, ip net.IP) (*Info, error) {
+	servers := c.RDAPServers
+
+	if len(servers) == 0 {
+		found, err := c.findRDAPServers(ctx, ip)
+		if err != nil {
+			return nil, err
+		}
+
+		servers = found
+	}
+
+	var errLast error
+
+	for _, server := range servers {
+		result, err := queryRDAP(ctx, server, ip)
+		if err == nil {
+			return result, nil
+		}
+
+		info.FromContext(ctx).Debug("RDAP query failed", "server", server, "error", err.Error())
+
+		errLast = err
+	}
+
+	if errLast == nil {
+		return nil, errors.Errorf("no RDAP server found for %v", ip)
+	}
+
+	return nil, errLast
+}
+
+// Returns the base URLs of the RDAP servers of the ip in the bootstrap
+// registries. The HTTPS ones come first.
+func (c *Client) findRDAPServers(ctx context.Context, ip net.IP) ([]string, error) {
+	var (
+		servers []string
+		lenBest = -1
+		errLast error
+	)
+
+	for _, urlBootstrap := range c.BootstrapURLs {
+		registry := new(bootstrap)
+
+		if err := getJSON(ctx, urlBootstrap, registry); err != nil {
+			errLast = errors.Wrap(err, "failed to get the RDAP bootstrap registry")
+
+			continue
+		}
+
+		for _, service := range registry.Services {
+			if len(service) < 2 {
+				continue
+			}
+
+			for _, prefix := range service[0] {
+				_, network, err := net.ParseCIDR(prefix)
+				if err != nil || !network.Contains(ip) {
+					continue
+				}
+
+				// The most specific prefix wins
+				if length, _ := network.Mask.Size(); length > lenBest {
+					lenBest = length
+					servers = sortHTTPSFirst(service[1])
+				}
+			}
+		}
+	}
+
+	if servers == nil {
+		if errLast != nil {
+			return nil, errLast
+		}
+
+		return nil, errors.Errorf("no RDAP server found for %v in the bootstrap registries", ip)
+	}
+
+	return servers, nil
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Decodes the JSON response of the url into v.
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	resp, err := netutil.HTTPGetContext(ctx, url)
+	if err != nil {
+		return errors.Wrap(err, "failed to GET "+url)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status from %v: %v", url, resp.Status)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, bodySizeMax)).Decode(v); err != nil {
+		return errors.Wrap(err, "failed to decode the response of "+url)
+	}
+
+	return nil
+}
+
+// Returns the info of the ip from the RDAP server of the base URL.
+func queryRDAP(ctx context.Context, server string, ip net.IP) (*Info, error) {
+	urlQuery := strings.TrimSuffix(server, "/") + "/ip/" + ip.String()
+	network := new(rdapNetwork)
+
+	if err := getJSON(ctx, urlQuery, network); err != nil {
+		return nil, err
+	}
+
+	if network.ObjectClassName != "ip network" {
+		return nil, errors.Errorf("unexpected RDAP object from %v: %q", urlQuery, network.ObjectClassName)
+	}
+
+	result := &Info{
+		IP:      ip.String(),
+		Name:    network.Name,
+		Handle:  network.Handle,
+		Country: network.Country,
+		Org:     findEntity(network.Entities, "registrant", "fn"),
+		Abuse:   findEntity(network.Entities, "abuse", "email"),
+		Server:  urlQuery,
+		Source:  SourceRDAP,
+	}
+
+	for _, cidr := range network.CIDRs {
+		prefix := cidr.V4Prefix + cidr.V6Prefix
+		if prefix != "" {
+			result.CIDRs = append(result.CIDRs, prefix+"/"+strconv.Itoa(cidr.Length))
+		}
+	}
+
+	if network.StartAddress != "" && network.EndAddress != "" {
+		result.Range = network.StartAddress + " - " + network.EndAddress
+
+		if result.CIDRs == nil {
+			result.CIDRs = rangeToCIDRs(net.ParseIP(network.StartAddress), net.ParseIP(network.EndAddress))
+		}
+	}
+
+	if network.Port43 != "" {
+		result.Registry = registryOf(network.Port43)
+	} else {
+		result.Registry = registryOf(server)
+	}
+
+	return result, nil
+}
+
+// Returns the CIDRs which cover the range from the first to the last address.
+// Nil if the range is invalid.
+func rangeToCIDRs(first, last net.IP) []string {
+	bits := 128
+
+	if first.To4() != nil && last.To4() != nil {
+		first, last, bits = first.To4(), last.To4(), 32
+	}
+
+	if first == nil || last == nil || len(first) != len(last) {
+		return nil
+	}
+
+	start := new(big.Int).SetBytes(first)
+	end := new(big.Int).SetBytes(last)
+	one := big.NewInt(1)
+
+	var cidrs []string
+
+	for start.Cmp(end) <= 0 {
+		// The largest block aligned to the start which does not exceed the end
+		size := bits
+		for size > 0 {
+			block := new(big.Int).Lsh(one, uint(bits-size+1))
+			if new(big.Int).Mod(start, block).Sign() != 0 {
+				break
+			}
+
+			if new(big.Int).Add(start, new(big.Int).Sub(block, one)).Cmp(end) > 0 {
+				break
+			}
+
+			size--
+		}
+
+		ip := make(net.IP, len(first))
+		start.FillBytes(ip)
+
+		cidrs = append(cidrs, ip.String()+"/"+strconv.Itoa(size))
+
+		start.Add(start, new(big.Int).Lsh(one, uint(bits-size)))
+	}
+
+	return cidrs
+}
+
+// Returns the URLs with the HTTPS ones first.
+func sortHTTPSFirst(urls []string) []string {
+	sorted := make([]string, 0, len(urls))
+
+	for _, u := range urls {
+		if strings.HasPrefix(u, "https://") {
+			sorted = append(sorted, u)
+		}
+	}
+
+	for _, u := range urls {
+		if !strings.HasPrefix(u, "https://") {
+			sorted = append(sorted, u)
+		}
+	}
+
+	return sorted
+}
@@ -0,0 +1,217 @@
+/*
+Package whois looks up the owner of an IP address and its network block. Such
+as the CIDR, the name of the network, the organization, the abuse contact and
+the registry.
+
+It queries RDAP (RFC 9082, 9083) first, finding the server of the registry from
+the IANA bootstrap registries (RFC 9224). If it fails, it falls back to the
+WHOIS protocol on port 43 (RFC 3912), following the referrals.
+
+	info, err := whois.New().Lookup(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(info.CIDRs) // [8.8.8.0/24]
+
+The bootstrap URLs and the servers are configurable to test against the local
+stand-ins.
+*/
+package whois
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/KEINOS/whereami/pkg/info"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Default timeout of the whole lookup including the fallback.
+	timeoutDefault = 10 * time.Second
+	// Max size of the responses to read.
+	bodySizeMax = 1 << 20
+)
+
+// Sources of the Info.
+const (
+	SourceRDAP  = "rdap"
+	SourceWHOIS = "whois"
+)
+
+// BootstrapURLsDefault are the IANA bootstrap registries of RDAP for IPv4 and
+// IPv6.
+var BootstrapURLsDefault = []string{
+	"https://data.iana.org/rdap/ipv4.json",
+	"https://data.iana.org/rdap/ipv6.json",
+}
+
+// WhoisServerDefault is the WHOIS server to query first. It refers to the one
+// of the registry.
+const WhoisServerDefault = "whois.iana.org:43"
+
+// registries are the names of the regional internet registries by the domain
+// of their servers.
+var registries = map[string]string{
+	"afrinic.net": "AFRINIC",
+	"apnic.net":   "APNIC",
+	"arin.net":    "ARIN",
+	"lacnic.net":  "LACNIC",
+	"ripe.net":    "RIPE NCC",
+	"iana.org":    "IANA",
+}
+
+// ============================================================================
+//  Type: Info
+// ============================================================================
+
+// Info is the owner of an IP address and its network block. The fields unknown
+// to the registry are empty.
+type Info struct {
+	// IP is the IP address looked up.
+	IP string `json:"ip"`
+	// CIDRs are the network block allocated in CIDR notation. Such as
+	// "123.123.0.0/16". Usually one, but a range may need multiple.
+	CIDRs []string `json:"cidrs,omitempty"`
+	// Range is the network block as the first and the last address. Such as
+	// "123.123.0.0 - 123.123.255.255".
+	Range string `json:"range,omitempty"`
+	// Name is the name of the network. Such as "EXAMPLE-NET".
+	Name string `json:"name,omitempty"`
+	// Handle is the ID of the network in the registry.
+	Handle string `json:"handle,omitempty"`
+	// Org is the organization which the network is registered to.
+	Org string `json:"org,omitempty"`
+	// Country is the country code of the network. Such as "JP".
+	Country string `json:"country,omitempty"`
+	// Abuse is the email address to report the abuse.
+	Abuse string `json:"abuse,omitempty"`
+	// Registry is the registry of the network. Such as "APNIC" or the host of
+	// the server if unknown.
+	Registry string `json:"registry,omitempty"`
+	// Server is the URL of RDAP or the address of WHOIS which answered.
+	Server string `json:"server,omitempty"`
+	// Source is how the info is obtained. SourceRDAP or SourceWHOIS.
+	Source string `json:"source"`
+}
+
+// ============================================================================
+//  Type: Client
+// ============================================================================
+
+// Client looks up the owner of the IP addresses.
+type Client struct {
+	// BootstrapURLs are the URLs of the RDAP bootstrap registries to find the
+	// server of the IP address. Ignored if RDAPServers is set.
+	BootstrapURLs []string
+	// RDAPServers are the base URLs of the RDAP servers to query in order,
+	// instead of the ones in the bootstrap registries. Such as
+	// "https://rdap.apnic.net/".
+	RDAPServers []string
+	// WhoisServer is the address of the WHOIS server to fall back. If the port
+	// number is omitted, 43 is used. If empty, it does not fall back.
+	WhoisServer string
+	// Dial connects to the WHOIS servers. Such as the one via a proxy. If nil,
+	// it connects directly.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// Timeout is the time to wait for the whole lookup.
+	Timeout time.Duration
+}
+
+// ----------------------------------------------------------------------------
+//  Constructor
+// ----------------------------------------------------------------------------
+
+// New returns a new Client with the default registries and the timeout.
+func New() *Client {
+	return &Client{
+		BootstrapURLs: BootstrapURLsDefault,
+		WhoisServer:   WhoisServerDefault,
+		Timeout:       timeoutDefault,
+	}
+}
+
+// ----------------------------------------------------------------------------
+//  Methods for Client
+// ----------------------------------------------------------------------------
+
+// Lookup is the same as LookupContext with the background context.
+func (c *Client) Lookup(ip net.IP) (*Info, error) {
+	return c.LookupContext(context.Background(), ip)
+}
+
+// LookupContext returns the owner of the ip. It queries RDAP first and falls
+// back to WHOIS if failed.
+//
+// The HTTP client stored in the ctx via netutil.WithClient is used for RDAP.
+func (c *Client) LookupContext(ctx context.Context, ip net.IP) (*Info, error) {
+	if ip == nil {
+		return nil, errors.New("IP address to look up is nil")
+	}
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	if len(c.RDAPServers) == 0 && len(c.BootstrapURLs) == 0 {
+		return c.lookupWhoisOnly(ctx, ip)
+	}
+
+	result, errRDAP := c.lookupRDAP(ctx, ip)
+	if errRDAP == nil {
+		return result, nil
+	}
+
+	if c.WhoisServer == "" {
+		return nil, errRDAP
+	}
+
+	info.FromContext(ctx).Debug("falling back to WHOIS", "ip", ip.String(), "error", errRDAP.Error())
+
+	result, err := c.lookupWhois(ctx, ip)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fall back to WHOIS (%v)", errRDAP)
+	}
+
+	return result, nil
+}
+
+// Returns the info from WHOIS. It is used if RDAP is disabled.
+func (c *Client) lookupWhoisOnly(ctx context.Context, ip net.IP) (*Info, error) {
+	if c.WhoisServer == "" {
+		return nil, errors.New("neither RDAP nor WHOIS server is set")
+	}
+
+	return c.lookupWhois(ctx, ip)
+}
+
+// ============================================================================
+//  Functions
+// ============================================================================
+
+// Returns the name of the registry of the host. Such as "APNIC" for
+// "rdap.apnic.net". If unknown, the host itself.
+func registryOf(host string) string {
+	if parsed, err := url.Parse(host); err == nil && parsed.Host != "" {
+		host = parsed.Hostname()
+	} else if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for domain, name := range registries {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return name
+		}
+	}
+
+	return host
+}
@@ -0,0 +1,298 @@
+package whois_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KEINOS/whereami/pkg/whois"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RDAP response of APNIC for 123.123.123.123 (trimmed).
+const rdapGolden = `{
+  "objectClassName": "ip network",
+  "handle": "123.123.0.0 - 123.123.255.255",
+  "startAddress": "123.123.0.0",
+  "endAddress": "123.123.255.255",
+  "name": "EXAMPLE-NET",
+  "country": "JP",
+  "port43": "whois.apnic.net",
+  "cidr0_cidrs": [{"v4prefix": "123.123.0.0", "length": 16}],
+  "entities": [
+    {
+      "roles": ["registrant"],
+      "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example Networks"]]],
+      "entities": [
+        {
+          "roles": ["abuse"],
+          "vcardArray": ["vcard", [["fn", {}, "text", "ABUSE"], ["email", {}, "text", "abuse@example.net"]]]
+        }
+      ]
+    }
+  ]
+}`
+
+// WHOIS responses of IANA and RIPE NCC (trimmed).
+const (
+	whoisIANA = `%% IANA WHOIS server
+refer:        %v
+
+inetnum:      123.0.0.0 - 123.255.255.255
+organisation: APNIC
+`
+	whoisRIPE = `% This is the RIPE Database query service.
+% Abuse contact for '123.123.0.0 - 123.123.127.255' is 'abuse@example.org'
+
+inetnum:        123.123.0.0 - 123.123.127.255
+netname:        EXAMPLE-ORG-NET
+descr:          Example Org
+country:        DE
+`
+)
+
+func TestLookup_rdap(t *testing.T) {
+	t.Parallel()
+
+	rdap := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/rdap/ip/123.123.123.123" {
+			http.NotFound(w, req)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/rdap+json")
+		fmt.Fprint(w, rdapGolden)
+	}))
+	defer rdap.Close()
+
+	// The first one fails
+	client := whois.New()
+	client.RDAPServers = []string{rdap.URL + "/not-found/", rdap.URL + "/rdap/"}
+	client.WhoisServer = ""
+
+	result, err := client.Lookup(net.ParseIP("123.123.123.123"))
+
+	require.NoError(t, err)
+	assert.Equal(t, &whois.Info{
+		IP:       "123.123.123.123",
+		CIDRs:    []string{"123.123.0.0/16"},
+		Range:    "123.123.0.0 - 123.123.255.255",
+		Name:     "EXAMPLE-NET",
+		Handle:   "123.123.0.0 - 123.123.255.255",
+		Org:      "Example Networks",
+		Country:  "JP",
+		Abuse:    "abuse@example.net",
+		Registry: "APNIC",
+		Server:   rdap.URL + "/rdap/ip/123.123.123.123",
+		Source:   whois.SourceRDAP,
+	}, result)
+}
+
+func TestLookup_rdap_bootstrap(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+
+	defer srv.Close()
+
+	mux.HandleFunc("/ipv6.json", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, `{"services": [
+			[["2001:db8::/32"], ["%[1]v/less-specific/"]],
+			[["2001:db8:1::/48"], ["%[1]v/rdap/"]]
+		]}`, srv.URL)
+	})
+	mux.HandleFunc("/rdap/ip/2001:db8:1::25", func(w http.ResponseWriter, req *http.Request) {
+		// No cidr0 extension
+		fmt.Fprint(w, `{
+			"objectClassName": "ip network",
+			"startAddress": "2001:db8:1::",
+			"endAddress": "2001:db8:1:ffff:ffff:ffff:ffff:ffff",
+			"name": "EXAMPLE-V6"
+		}`)
+	})
+
+	client := whois.New()
+	client.BootstrapURLs = []string{srv.URL + "/ipv4.json", srv.URL + "/ipv6.json"}
+
+	result, err := client.Lookup(net.ParseIP("2001:db8:1::25"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2001:db8:1::/48"}, result.CIDRs, "CIDR should be calculated from the range")
+	assert.Equal(t, "EXAMPLE-V6", result.Name)
+	assert.Equal(t, "127.0.0.1", result.Registry, "unknown registry should be the host of the server")
+	assert.Equal(t, whois.SourceRDAP, result.Source)
+}
+
+func TestLookup_fallback_to_whois(t *testing.T) {
+	t.Parallel()
+
+	rdap := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer rdap.Close()
+
+	serverRIPE := startDummyWhois(t, func(query string) string { return whoisRIPE })
+	serverIANA := startDummyWhois(t, func(query string) string {
+		return fmt.Sprintf(whoisIANA, serverRIPE)
+	})
+
+	client := whois.New()
+	client.RDAPServers = []string{rdap.URL}
+	client.WhoisServer = serverIANA
+
+	result, err := client.Lookup(net.ParseIP("123.123.1.1"))
+
+	require.NoError(t, err)
+	assert.Equal(t, &whois.Info{
+		IP:       "123.123.1.1",
+		CIDRs:    []string{"123.123.0.0/17"},
+		Range:    "123.123.0.0 - 123.123.127.255",
+		Name:     "EXAMPLE-ORG-NET",
+		Org:      "Example Org",
+		Country:  "DE",
+		Abuse:    "abuse@example.org",
+		Registry: "127.0.0.1",
+		Server:   serverRIPE,
+		Source:   whois.SourceWHOIS,
+	}, result)
+}
+
+func TestLookup_whois_arin(t *testing.T) {
+	t.Parallel()
+
+	server := startDummyWhois(t, func(query string) string {
+		// The more specific network comes later
+		return `NetRange:       8.0.0.0 - 8.127.255.255
+CIDR:           8.0.0.0/9
+NetName:        LVLT-ORG-8-8
+OrgName:        Level 3 Parent, LLC
+
+NetRange:       8.8.8.0 - 8.8.8.255
+CIDR:           8.8.8.0/24
+NetName:        GOGL
+NetHandle:      NET-8-8-8-0-2
+Organization:   Google LLC (GOGL)
+OrgName:        Google LLC
+OrgAbuseEmail:  network-abuse@google.com
+ReferralServer: rwhois://rwhois.example.net:4321
+`
+	})
+
+	client := whois.New()
+	client.BootstrapURLs = nil
+	client.WhoisServer = server
+
+	result, err := client.Lookup(net.ParseIP("8.8.8.8"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"8.8.8.0/24"}, result.CIDRs)
+	assert.Equal(t, "8.8.8.0 - 8.8.8.255", result.Range)
+	assert.Equal(t, "GOGL", result.Name)
+	assert.Equal(t, "NET-8-8-8-0-2", result.Handle)
+	assert.Equal(t, "Google LLC", result.Org)
+	assert.Equal(t, "network-abuse@google.com", result.Abuse)
+}
+
+func TestLookup_whois_range(t *testing.T) {
+	t.Parallel()
+
+	server := startDummyWhois(t, func(query string) string {
+		return "inetnum: 123.123.0.0 - 123.123.2.255\nnetname: NOT-ALIGNED\n"
+	})
+
+	result, err := (&whois.Client{WhoisServer: server}).Lookup(net.ParseIP("123.123.1.1"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"123.123.0.0/23", "123.123.2.0/24"}, result.CIDRs,
+		"the range not aligned to a CIDR should be split")
+}
+
+func TestLookup_error(t *testing.T) {
+	t.Parallel()
+
+	rdap := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"objectClassName": "error"}`)
+	}))
+	defer rdap.Close()
+
+	serverEmpty := startDummyWhois(t, func(query string) string { return "% no entries found\n" })
+
+	for _, test := range []struct {
+		client *whois.Client
+		expect string
+	}{
+		{
+			client: &whois.Client{RDAPServers: []string{rdap.URL}},
+			expect: `unexpected RDAP object from ` + rdap.URL + `/ip/123.123.123.123: "error"`,
+		},
+		{
+			client: &whois.Client{RDAPServers: []string{rdap.URL}, WhoisServer: serverEmpty},
+			expect: "failed to fall back to WHOIS (unexpected RDAP object",
+		},
+		{
+			client: &whois.Client{WhoisServer: serverEmpty},
+			expect: "no network of 123.123.123.123 found in the response of " + serverEmpty,
+		},
+		{
+			client: &whois.Client{BootstrapURLs: []string{rdap.URL + "/bootstrap.json"}},
+			expect: "no RDAP server found for 123.123.123.123 in the bootstrap registries",
+		},
+		{
+			client: &whois.Client{},
+			expect: "neither RDAP nor WHOIS server is set",
+		},
+	} {
+		result, err := test.client.Lookup(net.ParseIP("123.123.123.123"))
+
+		require.Error(t, err)
+		assert.Nil(t, result)
+		assert.Contains(t, err.Error(), test.expect)
+	}
+
+	result, err := whois.New().Lookup(nil)
+
+	require.Error(t, err)
+	assert.Nil(t, result)
+}
+
+// ============================================================================
+//  Helper Functions
+// ============================================================================
+
+// Starts a WHOIS server stand-in which responds the result of respond to the
+// query and returns its address.
+func startDummyWhois(t *testing.T, respond func(query string) string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return // closed
+			}
+
+			query, err := bufio.NewReader(conn).ReadString('\n')
+			if err == nil {
+				fmt.Fprint(conn, respond(strings.TrimSpace(query)))
+			}
+
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}